@@ -5,9 +5,13 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"os"
 	"time"
 
+	"github.com/google/uuid"
+
 	"github.com/nicolasmmb/go-rinha-backend-2025/internal/config/env"
+	"github.com/nicolasmmb/go-rinha-backend-2025/internal/core"
 	"github.com/nicolasmmb/go-rinha-backend-2025/internal/database"
 	"github.com/nicolasmmb/go-rinha-backend-2025/internal/repository/redis"
 	"github.com/nicolasmmb/go-rinha-backend-2025/internal/router"
@@ -28,33 +32,126 @@ func main() {
 	}
 	env.ShowEnvValues()
 
-	rds, err := database.ConnectToRedisClient(env.Values.REDIS_ADDR)
+	defer database.CloseRedisClient()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hostname, _ := os.Hostname()
+	instanceID := hostname + "-" + uuid.NewString()
+
+	//Initialize Repositories (Redis, memória ou SQLite, conforme REPOSITORY_BACKEND)
+	st, err := newStorage(ctx, instanceID)
 	if err != nil {
-		log.Fatalf("Erro ao obter o cliente Redis: %v", err)
+		log.Fatalf("Erro ao configurar os repositórios: %v", err)
 	}
-	if err := database.WarmUpDB(rds); err != nil {
-		log.Fatalf("Erro ao aquecer o banco de dados: %v", err)
+	defer st.close()
+
+	//Initialize Processor Registry
+	processors, err := env.Processors()
+	if err != nil {
+		log.Fatalf("Erro ao carregar os processadores de pagamento: %v", err)
+	}
+	processorRegistry, err := service.NewProcessorRegistry(processors)
+	if err != nil {
+		log.Fatalf("Erro ao configurar os processadores de pagamento: %v", err)
 	}
-	defer database.CloseRedisClient()
 
-	ctx := context.Background()
+	//Initialize Health Monitor
+	healthMonitor := service.NewHealthMonitor(
+		processorRegistry.HealthURLs(),
+		time.Duration(env.Values.HEALTH_CHECK_INTERVAL_MS)*time.Millisecond,
+		st.leader,
+		st.health,
+	)
+	go healthMonitor.Run(ctx)
 
-	//Initialize Payment Repository and Service
-	paymentRepository := redis.NewPaymentsRepository(rds)
-	//Initialize Payment Service
-	paymentService := service.NewPaymentService(
-		paymentRepository,
-		env.Values.PAYMENT_PROCESSOR_URL_DEFAULT,
-		env.Values.PAYMENT_PROCESSOR_URL_FALLBACK,
-		env.Values.PAYMENT_CHAN_SIZE,
+	//Initialize Routing Strategy
+	routingStrategy, err := service.NewRoutingStrategy(env.Values.ROUTING_STRATEGY, service.RoutingOptions{
+		SplitPrimaryPercent: env.Values.ROUTING_SPLIT_DEFAULT_PERCENT,
+	})
+	if err != nil {
+		log.Fatalf("Erro ao configurar a estratégia de roteamento: %v", err)
+	}
+
+	//Initialize Circuit Breakers
+	circuitBreakers := service.NewCircuitBreakers(
+		processorRegistry.Names(),
+		service.BreakerConfig{
+			FailureThreshold: env.Values.BREAKER_FAILURE_THRESHOLD,
+			OpenDuration:     time.Duration(env.Values.BREAKER_OPEN_MS) * time.Millisecond,
+			HalfOpenProbes:   env.Values.BREAKER_HALF_OPEN_PROBES,
+		},
 	)
+
+	//Initialize Payment Queue
+	var paymentQueue core.PaymentQueueInterface
+	switch env.Values.QUEUE_BACKEND {
+	case "channel":
+		paymentQueue = service.NewChannelQueue(env.Values.PAYMENT_CHAN_SIZE)
+	case "stream":
+		if st.rds == nil {
+			log.Fatalf("QUEUE_BACKEND=stream requer REPOSITORY_BACKEND=redis")
+		}
+		paymentQueue, err = redis.NewStreamQueue(ctx, st.rds, st.ns, instanceID, env.Values.PAYMENT_CHAN_SIZE, time.Duration(env.Values.QUEUE_CLAIM_IDLE_MS)*time.Millisecond)
+		if err != nil {
+			log.Fatalf("Erro ao criar a fila de pagamentos no Redis: %v", err)
+		}
+	default:
+		log.Fatalf("QUEUE_BACKEND inválido: %q", env.Values.QUEUE_BACKEND)
+	}
+
+	//Initialize Outbox (pagamentos processados que o repositório recusou gravar)
+	outbox := service.NewOutbox(st.payments, st.outboxSpill, env.Values.OUTBOX_CAPACITY)
+	if loaded, err := outbox.Load(ctx); err != nil {
+		log.Printf("Erro ao retomar os pagamentos do outbox: %v", err)
+	} else if loaded > 0 {
+		log.Printf("♻️  %d pagamentos do outbox retomados do último desligamento", loaded)
+	}
+	go outbox.Run(ctx)
+
+	//Initialize Payment Service
+	paymentService := service.NewPaymentService(service.PaymentServiceOptions{
+		PaymentRepository:        st.payments,
+		ReconciliationRepository: st.reconciliation,
+		DeadLetterRepository:     st.deadLetters,
+		Processors:               processorRegistry,
+		Health:                   healthMonitor,
+		Routing:                  routingStrategy,
+		Breakers:                 circuitBreakers,
+		Outbox:                   outbox,
+		QueueSpillRepository:     st.queueSpill,
+		ClaimRepository:          st.claims,
+		IdempotencyRepository:    st.idempotency,
+		ResetNotifier:            st.resets,
+		InstanceID:               instanceID,
+		ClaimTTL:                 time.Duration(env.Values.CLAIM_TTL_MS) * time.Millisecond,
+		CompletedClaimTTL:        time.Duration(env.Values.CLAIM_COMPLETED_TTL_MS) * time.Millisecond,
+		IdempotencyWindow:        time.Duration(env.Values.IDEMPOTENCY_WINDOW_MS) * time.Millisecond,
+		Queue:                    paymentQueue,
+		SummaryCompatMode:        env.Values.SUMMARY_COMPAT_MODE,
+		MaxSummaryWindow:         time.Duration(env.Values.SUMMARY_MAX_WINDOW_MS) * time.Millisecond,
+	})
 	//Initialize Payment Worker
+	resumed, err := paymentService.ResumeSpilled(ctx)
+	if err != nil {
+		log.Printf("Erro ao retomar os pagamentos guardados no último desligamento: %v", err)
+	}
+	if resumed > 0 {
+		log.Printf("♻️  %d pagamentos retomados do último desligamento", resumed)
+	}
+
+	go paymentService.RunResetListener(ctx)
+
 	savePaymentWorker := worker.NewSavePaymentWorker(paymentService, env.Values.WORKER_POOL)
-	go savePaymentWorker.RunPaymentProcessor(ctx)
+	savePaymentWorker.RunPaymentProcessor(ctx)
+	//Initialize Reconcile Worker
+	reconcileWorker := worker.NewReconcileWorker(paymentService, st.leader, time.Duration(env.Values.RECONCILE_INTERVAL_MS)*time.Millisecond)
+	go reconcileWorker.RunReconciler(ctx)
 
 	// Initialize Router and Payment Handler
 	paymentHandler := router.NewPaymentHandler(paymentService)
-	paymentRoutes := router.Routes(paymentHandler)
+	paymentRoutes := router.Routes(paymentHandler, env.Values.ADMIN_TOKEN)
 
 	SERVER_HOST := env.Values.SERVER_ADDR + ":" + fmt.Sprint(env.Values.SERVER_PORT)
 	server := &http.Server{
@@ -67,5 +164,23 @@ func main() {
 	}
 
 	log.Printf("Servidor iniciado em %s", SERVER_HOST)
-	libs.GracefulShutdown(server, time.Second*10)
+	libs.GracefulShutdown(server, time.Second*10, func() {
+		// Para de aceitar pagamentos, deixa os workers esvaziarem a fila até o prazo e guarda o que sobrar.
+		drainCtx, cancelDrain := context.WithTimeout(context.Background(), time.Duration(env.Values.QUEUE_DRAIN_TIMEOUT_MS)*time.Millisecond)
+		defer cancelDrain()
+
+		pending := paymentService.StopAccepting(drainCtx)
+		if !savePaymentWorker.Drain(drainCtx) {
+			log.Println("⚠️  Prazo para esvaziar a fila de pagamentos esgotado")
+		}
+
+		report := paymentService.PersistPending(context.Background(), pending)
+		log.Printf("📦 Fila de pagamentos: %d drenados, %d guardados, %d perdidos", report.Drained, report.Persisted, report.Lost)
+
+		// Última tentativa de gravar o outbox; o que sobrar vai para o arquivo.
+		spilled, lost := outbox.Close(drainCtx)
+		if spilled > 0 || lost > 0 {
+			log.Printf("📤 Outbox: %d pagamentos guardados em arquivo, %d perdidos", spilled, lost)
+		}
+	}, cancel)
 }
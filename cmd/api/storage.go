@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+
+	goredis "github.com/redis/go-redis/v9"
+
+	"github.com/nicolasmmb/go-rinha-backend-2025/internal/config/env"
+	"github.com/nicolasmmb/go-rinha-backend-2025/internal/core"
+	"github.com/nicolasmmb/go-rinha-backend-2025/internal/database"
+	"github.com/nicolasmmb/go-rinha-backend-2025/internal/repository/file"
+	"github.com/nicolasmmb/go-rinha-backend-2025/internal/repository/memory"
+	"github.com/nicolasmmb/go-rinha-backend-2025/internal/repository/redis"
+	"github.com/nicolasmmb/go-rinha-backend-2025/internal/repository/sqlite"
+)
+
+// storage reúne os repositórios e a eleição de líder do backend escolhido em REPOSITORY_BACKEND.
+type storage struct {
+	// Cliente do Valkey e o prefixo das chaves da aplicação; nil quando o backend não usa Redis.
+	rds *goredis.Client
+	ns  redis.Namespace
+	// Banco do backend sqlite; nil nos demais.
+	sqlDB *sql.DB
+
+	payments       core.PaymentRepositoryInterface
+	reconciliation core.ReconciliationRepositoryInterface
+	deadLetters    core.DeadLetterRepositoryInterface
+	health         core.HealthRepositoryInterface
+	queueSpill     core.QueueSpillRepositoryInterface
+	claims         core.ClaimRepositoryInterface
+	idempotency    core.IdempotencyRepositoryInterface
+	leader         core.LeaderElectorInterface
+	// Anuncia os resets às outras instâncias; nil nos backends de uma instância só.
+	resets core.ResetNotifierInterface
+
+	// Arquivo do outbox; nil sem OUTBOX_SPILL_FILE. Nunca fica no Valkey, que é justamente o que falhou.
+	outboxSpill core.QueueSpillRepositoryInterface
+}
+
+func newStorage(ctx context.Context, instanceID string) (*storage, error) {
+	st := &storage{}
+
+	switch env.Values.REPOSITORY_BACKEND {
+	case "redis":
+		ns, err := redis.NewNamespace(env.Values.REDIS_KEY_PREFIX)
+		if err != nil {
+			return nil, err
+		}
+		rds, err := database.ConnectToRedisClient(env.Values.REDIS_ADDR)
+		if err != nil {
+			return nil, fmt.Errorf("erro ao obter o cliente Redis: %w", err)
+		}
+		if err := database.WarmUpDB(rds, string(ns)); err != nil {
+			return nil, fmt.Errorf("erro ao aquecer o banco de dados: %w", err)
+		}
+		if err := redis.MigrateToNamespace(ctx, rds, ns); err != nil {
+			return nil, fmt.Errorf("erro ao mover as chaves sem prefixo para o namespace: %w", err)
+		}
+		if err := redis.MigrateMoneyToCents(ctx, rds, ns); err != nil {
+			return nil, fmt.Errorf("erro ao migrar os valores dos pagamentos para centavos: %w", err)
+		}
+
+		paymentsOpts := redis.PaymentsRepositoryOptions{
+			SummaryMode: env.Values.SUMMARY_MODE,
+			BucketSize:  time.Duration(env.Values.SUMMARY_BUCKET_MS) * time.Millisecond,
+			ChunkSize:   env.Values.SUMMARY_CHUNK_SIZE,
+			ShardSize:   time.Duration(env.Values.PAYMENTS_SHARD_MS) * time.Millisecond,
+			Retention:   time.Duration(env.Values.PAYMENTS_RETENTION_MS) * time.Millisecond,
+		}
+		st.payments, err = redis.NewPaymentsRepository(rds, ns, paymentsOpts)
+		if err != nil {
+			return nil, fmt.Errorf("erro ao configurar o repositório de pagamentos: %w", err)
+		}
+		if err := redis.MigrateToTimeShards(ctx, rds, ns, paymentsOpts); err != nil {
+			return nil, fmt.Errorf("erro ao dividir os pagamentos em shards de tempo: %w", err)
+		}
+		if err := redis.RebuildBuckets(ctx, rds, ns, paymentsOpts); err != nil {
+			return nil, fmt.Errorf("erro ao reconstruir os contadores dos buckets: %w", err)
+		}
+
+		// O Valkey recusa escritas quando a memória acaba; o watchdog avisa antes disso.
+		memoryWatchdog := database.NewMemoryWatchdog(
+			rds,
+			time.Duration(env.Values.MEMORY_WATCHDOG_INTERVAL_MS)*time.Millisecond,
+			env.Values.MEMORY_WARN_PERCENT,
+		)
+		go memoryWatchdog.Run(ctx)
+
+		// Apenas o líder consulta o health-check dos processadores e reconcilia pagamentos.
+		leaderElection := database.NewLeaderElection(
+			rds,
+			ns.Key(database.RD_KEY_HEALTH_LEADER),
+			instanceID,
+			time.Duration(env.Values.LEADER_LEASE_MS)*time.Millisecond,
+		)
+		go leaderElection.Run(ctx)
+
+		st.rds = rds
+		st.ns = ns
+		st.reconciliation = redis.NewReconciliationRepository(rds, ns)
+		st.deadLetters = redis.NewDeadLetterRepository(rds, ns)
+		st.health = redis.NewHealthRepository(rds, ns)
+		st.queueSpill = redis.NewQueueSpillRepository(rds, ns)
+		st.claims = redis.NewClaimRepository(rds, ns)
+		st.idempotency = redis.NewIdempotencyRepository(rds, ns)
+		st.resets = redis.NewResetNotifier(rds, ns)
+		st.leader = leaderElection
+
+	case "memory", "sqlite":
+		if env.Values.REPOSITORY_BACKEND == "sqlite" {
+			db, err := sqlite.Open(ctx, env.Values.SQLITE_PATH)
+			if err != nil {
+				return nil, err
+			}
+			log.Printf("🗄️  Pagamentos guardados no SQLite em %s", env.Values.SQLITE_PATH)
+
+			st.sqlDB = db
+			st.payments = sqlite.NewPaymentsRepository(db)
+		} else {
+			log.Println("⚠️  REPOSITORY_BACKEND=memory: os pagamentos ficam apenas nesta instância e se perdem ao reiniciar")
+			st.payments = memory.NewPaymentsRepository()
+		}
+
+		// Sem Valkey há uma única instância: o restante do estado fica em memória.
+		st.reconciliation = memory.NewReconciliationRepository()
+		st.deadLetters = memory.NewDeadLetterRepository()
+		st.health = memory.NewHealthRepository()
+		st.queueSpill = memory.NewQueueSpillRepository()
+		st.claims = memory.NewClaimRepository()
+		st.idempotency = memory.NewIdempotencyRepository()
+		st.leader = database.StandaloneLeader{}
+
+	default:
+		return nil, fmt.Errorf("REPOSITORY_BACKEND inválido: %q", env.Values.REPOSITORY_BACKEND)
+	}
+
+	// Pagamentos que sobraram na fila no último desligamento.
+	if env.Values.QUEUE_SPILL_FILE != "" {
+		st.queueSpill = file.NewQueueSpillRepository(env.Values.QUEUE_SPILL_FILE)
+	}
+	if env.Values.OUTBOX_SPILL_FILE != "" {
+		st.outboxSpill = file.NewQueueSpillRepository(env.Values.OUTBOX_SPILL_FILE)
+	}
+
+	return st, nil
+}
+
+func (st *storage) close() {
+	if st.sqlDB != nil {
+		if err := st.sqlDB.Close(); err != nil {
+			log.Printf("Erro ao fechar o SQLite: %v", err)
+		}
+	}
+}
@@ -14,13 +14,46 @@ import (
 type values struct {
 	SERVER_ADDR                    string
 	SERVER_PORT                    int
-	REDIS_ADDR                     string
-	PAYMENT_PROCESSOR_URL_DEFAULT  string
-	PAYMENT_PROCESSOR_URL_FALLBACK string
-	HEALTH_URL_DEFAULT             string
-	HEALTH_URL_FALLBACK            string
+	REDIS_ADDR                     string `default:""`
+	REDIS_KEY_PREFIX               string `default:"rinha:"`
+	ADMIN_TOKEN                    string `default:"" secret:"true"`
+	REPOSITORY_BACKEND             string `default:"redis"`
+	SQLITE_PATH                    string `default:"payments.db"`
+	PAYMENT_PROCESSORS             string `default:""`
+	PAYMENT_PROCESSOR_URL_DEFAULT  string `default:""`
+	PAYMENT_PROCESSOR_URL_FALLBACK string `default:""`
+	HEALTH_URL_DEFAULT             string `default:""`
+	HEALTH_URL_FALLBACK            string `default:""`
 	WORKER_POOL                    int
 	PAYMENT_CHAN_SIZE              int
+	HEALTH_CHECK_INTERVAL_MS       int     `default:"5000"`
+	LEADER_LEASE_MS                int     `default:"3000"`
+	ROUTING_STRATEGY               string  `default:"health-aware"`
+	ROUTING_SPLIT_DEFAULT_PERCENT  int     `default:"80"`
+	PROCESSOR_FEE_DEFAULT          float64 `default:"0.05"`
+	PROCESSOR_FEE_FALLBACK         float64 `default:"0.15"`
+	BREAKER_FAILURE_THRESHOLD      int     `default:"5"`
+	BREAKER_OPEN_MS                int     `default:"2000"`
+	BREAKER_HALF_OPEN_PROBES       int     `default:"1"`
+	SUMMARY_COMPAT_MODE            bool    `default:"false"`
+	SUMMARY_MODE                   string  `default:"exact"`
+	SUMMARY_BUCKET_MS              int     `default:"1000"`
+	SUMMARY_CHUNK_SIZE             int     `default:"1000"`
+	SUMMARY_MAX_WINDOW_MS          int     `default:"0"`
+	PAYMENTS_SHARD_MS              int     `default:"3600000"`
+	PAYMENTS_RETENTION_MS          int     `default:"86400000"`
+	MEMORY_WATCHDOG_INTERVAL_MS    int     `default:"10000"`
+	MEMORY_WARN_PERCENT            int     `default:"80"`
+	RECONCILE_INTERVAL_MS          int     `default:"5000"`
+	CLAIM_TTL_MS                   int     `default:"30000"`
+	CLAIM_COMPLETED_TTL_MS         int     `default:"86400000"`
+	IDEMPOTENCY_WINDOW_MS          int     `default:"0"`
+	QUEUE_BACKEND                  string  `default:"channel"`
+	QUEUE_CLAIM_IDLE_MS            int     `default:"30000"`
+	QUEUE_DRAIN_TIMEOUT_MS         int     `default:"5000"`
+	QUEUE_SPILL_FILE               string  `default:""`
+	OUTBOX_CAPACITY                int     `default:"10000"`
+	OUTBOX_SPILL_FILE              string  `default:""`
 }
 
 var Values = &values{}
@@ -44,10 +77,15 @@ func Load() error {
 		envVarName := fieldType.Name // O nome do campo da struct é o nome da variável de ambiente.
 
 		// Busca o valor da variável de ambiente.
+		// Campos com a tag `default` são opcionais e usam o valor da tag quando ausentes.
 		envVarValue, ok := os.LookupEnv(envVarName)
 		if !ok {
-			missingVars = append(missingVars, envVarName)
-			continue
+			defaultValue, hasDefault := fieldType.Tag.Lookup("default")
+			if !hasDefault {
+				missingVars = append(missingVars, envVarName)
+				continue
+			}
+			envVarValue = defaultValue
 		}
 
 		// Faz o parse do valor da variável de ambiente para o tipo correto do campo.
@@ -118,6 +156,11 @@ func ShowEnvValues() {
 	// Itera e imprime os valores formatados.
 	for i := 0; i < v.NumField(); i++ {
 		field := v.Field(i)
+		// Segredos configurados não aparecem no log.
+		if t.Field(i).Tag.Get("secret") == "true" && !field.IsZero() {
+			log.Printf(format, t.Field(i).Name, "********")
+			continue
+		}
 		log.Printf(format, t.Field(i).Name, field.Interface())
 	}
 }
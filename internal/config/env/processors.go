@@ -0,0 +1,71 @@
+package env
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/nicolasmmb/go-rinha-backend-2025/internal/domain"
+)
+
+// processorConfig é o formato de cada item de PAYMENT_PROCESSORS.
+type processorConfig struct {
+	Name       string  `json:"name"`
+	PaymentURL string  `json:"paymentUrl"`
+	HealthURL  string  `json:"healthUrl"`
+	Fee        float64 `json:"fee"`
+	Priority   int     `json:"priority"`
+	TimeoutMs  int     `json:"timeoutMs"`
+}
+
+// Timeout padrão de cada chamada a um processador, quando não configurado.
+const DEFAULT_PROCESSOR_TIMEOUT_MS = 5000
+
+// Processors retorna os processadores configurados em PAYMENT_PROCESSORS (uma lista JSON).
+// Sem essa variável, os processadores default e fallback são montados a partir das variáveis legadas.
+func Processors() ([]domain.Processor, error) {
+	if Values.PAYMENT_PROCESSORS == "" {
+		return []domain.Processor{
+			{
+				Name:       domain.PROCESSOR_DEFAULT,
+				PaymentURL: Values.PAYMENT_PROCESSOR_URL_DEFAULT,
+				HealthURL:  Values.HEALTH_URL_DEFAULT,
+				Fee:        Values.PROCESSOR_FEE_DEFAULT,
+				Priority:   1,
+				Timeout:    DEFAULT_PROCESSOR_TIMEOUT_MS * time.Millisecond,
+			},
+			{
+				Name:       domain.PROCESSOR_FALLBACK,
+				PaymentURL: Values.PAYMENT_PROCESSOR_URL_FALLBACK,
+				HealthURL:  Values.HEALTH_URL_FALLBACK,
+				Fee:        Values.PROCESSOR_FEE_FALLBACK,
+				Priority:   2,
+				Timeout:    DEFAULT_PROCESSOR_TIMEOUT_MS * time.Millisecond,
+			},
+		}, nil
+	}
+
+	var configs []processorConfig
+	if err := json.Unmarshal([]byte(Values.PAYMENT_PROCESSORS), &configs); err != nil {
+		return nil, fmt.Errorf("PAYMENT_PROCESSORS inválido: %w", err)
+	}
+
+	processors := make([]domain.Processor, 0, len(configs))
+	for _, c := range configs {
+		timeoutMs := c.TimeoutMs
+		if timeoutMs <= 0 {
+			timeoutMs = DEFAULT_PROCESSOR_TIMEOUT_MS
+		}
+
+		processors = append(processors, domain.Processor{
+			Name:       c.Name,
+			PaymentURL: c.PaymentURL,
+			HealthURL:  c.HealthURL,
+			Fee:        c.Fee,
+			Priority:   c.Priority,
+			Timeout:    time.Duration(timeoutMs) * time.Millisecond,
+		})
+	}
+
+	return processors, nil
+}
@@ -0,0 +1,48 @@
+package core
+
+import (
+	"context"
+	"errors"
+
+	"github.com/nicolasmmb/go-rinha-backend-2025/internal/domain"
+)
+
+var (
+	ErrQueueFull   = errors.New("O Galo tá cansado")
+	ErrQueueClosed = errors.New("fila de pagamentos fechada")
+)
+
+// QueueMessage é um pagamento retirado da fila, que deve ser confirmado com Ack após tratado.
+type QueueMessage struct {
+	ID      string
+	Payment domain.Payment
+}
+
+type PaymentQueueInterface interface {
+	// Enqueue adiciona o pagamento na fila, retornando ErrQueueFull se ela estiver cheia.
+	Enqueue(ctx context.Context, payment *domain.Payment) error
+	// Dequeue bloqueia até existir um pagamento na fila ou o contexto ser cancelado.
+	Dequeue(ctx context.Context) (*QueueMessage, error)
+	// Ack confirma que o pagamento foi tratado e não precisa ser entregue novamente.
+	Ack(ctx context.Context, msg *QueueMessage) error
+	Len(ctx context.Context) (int64, error)
+	// Close para de aceitar pagamentos; o que já está na fila continua sendo entregue
+	// e Dequeue retorna ErrQueueClosed quando não houver mais nada.
+	Close()
+	// TakeRemaining retira os pagamentos ainda não entregues que seriam perdidos num restart.
+	// Filas duráveis não perdem pagamentos e retornam nil.
+	TakeRemaining() []domain.Payment
+	// Purge descarta os pagamentos que aguardam na fila e retorna quantos foram descartados.
+	Purge(ctx context.Context) (int64, error)
+	// Redelivers informa se a fila entrega de novo as mensagens sem Ack, como o stream compartilhado do Valkey.
+	// Numa fila em memória o Ack não faz nada, e a mensagem descartada por um worker se perde.
+	Redelivers() bool
+}
+
+// QueueSpillRepositoryInterface guarda os pagamentos que sobraram na fila no desligamento,
+// para que sejam retomados no próximo boot.
+type QueueSpillRepositoryInterface interface {
+	SaveSpilled(ctx context.Context, payments []domain.Payment) error
+	// TakeSpilled retorna e remove os pagamentos guardados.
+	TakeSpilled(ctx context.Context) ([]domain.Payment, error)
+}
@@ -0,0 +1,25 @@
+package core
+
+import "github.com/nicolasmmb/go-rinha-backend-2025/internal/domain"
+
+// ProcessorCandidate é um processador disponível para o roteamento, com o último health conhecido.
+// Os candidatos são entregues às estratégias em ordem de prioridade.
+type ProcessorCandidate struct {
+	Name        string
+	Fee         float64
+	Health      domain.ProcessorHealth
+	HealthKnown bool
+}
+
+// IsFailing informa se o processador é sabidamente falho.
+func (c ProcessorCandidate) IsFailing() bool {
+	return c.HealthKnown && c.Health.Failing
+}
+
+// RoutingStrategy decide para quais processadores um pagamento é enviado.
+type RoutingStrategy interface {
+	// Name identifica a estratégia e é registrado em cada pagamento roteado por ela.
+	Name() string
+	// Plan retorna os processadores, sem repetição, na ordem em que devem ser tentados.
+	Plan(payment *domain.Payment, candidates []ProcessorCandidate) []string
+}
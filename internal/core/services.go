@@ -8,7 +8,87 @@ import (
 )
 
 type PaymentRepositoryInterface interface {
-	SavePayment(ctx context.Context, payment *domain.Payment) error
+	// SavePayment grava o pagamento e, na mesma operação atômica, leva seu registro para succeeded.
+	// Retorna created=false, sem alterar nada, quando o correlationId já estava gravado para o processador.
+	// Se o registro não puder ir para succeeded, o pagamento é gravado mesmo assim e o registro fica como estava.
+	SavePayment(ctx context.Context, payment *domain.Payment) (created bool, err error)
+	// TransitionPayment leva o registro do pagamento para o status informado, acrescentando a transição ao histórico.
+	// Transições que a máquina de estados não permite retornam *domain.TransitionError, sem alterar o registro.
+	TransitionPayment(ctx context.Context, payment *domain.Payment, status domain.PaymentStatus) error
+	// GetPaymentRecord retorna domain.ErrPaymentNotFound para correlationIds desconhecidos.
+	GetPaymentRecord(ctx context.Context, correlationId string) (*domain.PaymentRecord, error)
 	GetSummaryByProcessor(ctx context.Context, typeOfProcessor string, from, to time.Time) (*domain.SummaryItem, error)
+	// GetRoutingStats retorna, por estratégia de roteamento, o total processado em cada processador.
+	GetRoutingStats(ctx context.Context) (map[string]map[string]domain.SummaryItem, error)
 	ResetState(ctx context.Context) error
 }
+
+// BatchSummaryRepositoryInterface é implementada, opcionalmente, pelos repositórios de pagamento
+// que calculam o resumo de vários processadores numa única consulta.
+type BatchSummaryRepositoryInterface interface {
+	GetSummaryByProcessors(ctx context.Context, processors []string, from, to time.Time) (domain.Summary, error)
+}
+
+type ReconciliationRepositoryInterface interface {
+	ParkPayment(ctx context.Context, parked *domain.ParkedPayment) error
+	ListParkedPayments(ctx context.Context) ([]domain.ParkedPayment, error)
+	RemoveParkedPayment(ctx context.Context, correlationId string) error
+	PurgeParkedPayments(ctx context.Context) (int64, error)
+}
+
+type DeadLetterRepositoryInterface interface {
+	// SaveDeadLetter cria ou substitui a entrada do pagamento na dead-letter.
+	SaveDeadLetter(ctx context.Context, deadLetter *domain.DeadLetter) error
+	GetDeadLetter(ctx context.Context, correlationId string) (*domain.DeadLetter, error)
+	// ListDeadLetters retorna as entradas da mais antiga para a mais recente e o total de entradas.
+	ListDeadLetters(ctx context.Context, offset, limit int) ([]domain.DeadLetter, int64, error)
+	DeleteDeadLetter(ctx context.Context, correlationId string) error
+	PurgeDeadLetters(ctx context.Context) (int64, error)
+}
+
+// ClaimRepositoryInterface reserva cada correlationId para um único worker, entre todas as instâncias,
+// para que o mesmo pagamento não seja enviado duas vezes aos processadores.
+type ClaimRepositoryInterface interface {
+	// ClaimPayment reserva o correlationId para owner por ttl. Se outro dono já o reservou ou ele já foi concluído,
+	// retorna a reserva existente com Acquired=false e conta a tentativa duplicada.
+	ClaimPayment(ctx context.Context, correlationId, owner string, ttl time.Duration) (*domain.PaymentClaim, error)
+	// CompleteClaim marca o correlationId como concluído por ttl, mesmo que a reserva de owner já tenha expirado.
+	CompleteClaim(ctx context.Context, correlationId, owner string, ttl time.Duration) error
+	// ReleaseClaim libera a reserva para que o pagamento possa ser processado de novo, se ela ainda for de owner.
+	ReleaseClaim(ctx context.Context, correlationId, owner string) error
+	GetClaimStats(ctx context.Context) (domain.ClaimStats, error)
+	// ResetClaims apaga as reservas e as contagens de duplicatas.
+	ResetClaims(ctx context.Context) error
+}
+
+// IdempotencyRepositoryInterface guarda as respostas de POST /payments por Idempotency-Key, compartilhadas entre as instâncias.
+type IdempotencyRepositoryInterface interface {
+	// ReserveIdempotencyKey reserva a chave para a requisição com requestHash por ttl, se ela ainda não existir.
+	// Retorna nil quando reservou, ou o registro existente, pendente ou com a resposta gravada.
+	ReserveIdempotencyKey(ctx context.Context, key, requestHash string, ttl time.Duration) (*domain.IdempotencyRecord, error)
+	// SaveIdempotentResponse grava o registro com a resposta, substituindo a reserva, por ttl.
+	SaveIdempotentResponse(ctx context.Context, key string, record *domain.IdempotencyRecord, ttl time.Duration) error
+	// ReleaseIdempotencyKey apaga a reserva, para que a próxima requisição com a chave seja tratada de novo.
+	ReleaseIdempotencyKey(ctx context.Context, key string) error
+	ResetIdempotencyKeys(ctx context.Context) error
+}
+
+type HealthRepositoryInterface interface {
+	SaveHealth(ctx context.Context, statuses map[string]domain.ProcessorHealth) error
+	GetHealth(ctx context.Context) (map[string]domain.ProcessorHealth, error)
+	SubscribeHealth(ctx context.Context) <-chan map[string]domain.ProcessorHealth
+}
+
+// ResetNotifierInterface avisa as outras instâncias de um reset, para que descartem o estado que só elas têm
+// (fila em memória, outbox e circuit breakers). origin identifica a instância que fez o reset.
+type ResetNotifierInterface interface {
+	PublishReset(ctx context.Context, origin string) error
+	SubscribeReset(ctx context.Context) <-chan string
+}
+
+type LeaderElectorInterface interface {
+	IsLeader() bool
+	// Known é fechado quando o resultado da primeira disputa pela liderança é conhecido;
+	// antes disso IsLeader retorna false mesmo que esta instância venha a ser a líder.
+	Known() <-chan struct{}
+}
@@ -0,0 +1,133 @@
+package database
+
+import (
+	"context"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Chave do lease de liderança do monitor de health dos processadores.
+const RD_KEY_HEALTH_LEADER = "health:leader"
+
+// Renova o lease apenas se ele ainda pertence a esta instância.
+var renewLeaseScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+// Libera o lease apenas se ele ainda pertence a esta instância.
+var releaseLeaseScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// LeaderElection elege uma única instância líder através de um lease no Redis (SET NX PX).
+// O líder renova o lease a cada terço do período; se ele morrer, o lease expira
+// e outra instância assume em no máximo um período de lease.
+type LeaderElection struct {
+	client *redis.Client
+	key    string
+	owner  string
+	lease  time.Duration
+
+	leader atomic.Bool
+
+	// Fechado depois da primeira disputa pelo lease.
+	known     chan struct{}
+	knownOnce sync.Once
+}
+
+func NewLeaderElection(client *redis.Client, key, owner string, lease time.Duration) *LeaderElection {
+	return &LeaderElection{client: client, key: key, owner: owner, lease: lease, known: make(chan struct{})}
+}
+
+func (le *LeaderElection) IsLeader() bool {
+	return le.leader.Load()
+}
+
+func (le *LeaderElection) Known() <-chan struct{} {
+	return le.known
+}
+
+// Run tenta obter ou renovar o lease até o contexto ser cancelado, liberando-o ao final.
+func (le *LeaderElection) Run(ctx context.Context) {
+	ticker := time.NewTicker(le.lease / 3)
+	defer ticker.Stop()
+
+	for {
+		le.tick(ctx)
+
+		select {
+		case <-ctx.Done():
+			le.release()
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (le *LeaderElection) tick(ctx context.Context) {
+	var isLeader bool
+
+	if le.leader.Load() {
+		renewed, err := renewLeaseScript.Run(ctx, le.client, []string{le.key}, le.owner, le.lease.Milliseconds()).Int()
+		if err != nil {
+			log.Printf("❌ falha ao renovar o lease de liderança: %v", err)
+		}
+		isLeader = err == nil && renewed == 1
+	} else {
+		acquired, err := le.client.SetNX(ctx, le.key, le.owner, le.lease).Result()
+		if err != nil {
+			log.Printf("❌ falha ao disputar o lease de liderança: %v", err)
+		}
+		isLeader = err == nil && acquired
+	}
+
+	if le.leader.Swap(isLeader) != isLeader {
+		if isLeader {
+			log.Printf("👑 Instância %s assumiu a liderança", le.owner)
+		} else {
+			log.Printf("🔌 Instância %s perdeu a liderança", le.owner)
+		}
+	}
+	le.knownOnce.Do(func() { close(le.known) })
+}
+
+func (le *LeaderElection) release() {
+	if !le.leader.Swap(false) {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	if err := releaseLeaseScript.Run(ctx, le.client, []string{le.key}, le.owner).Err(); err != nil {
+		log.Printf("Erro ao liberar o lease de liderança: %v", err)
+	}
+}
+
+// StandaloneLeader é usado quando só existe uma instância e não há Redis para a eleição: ela é sempre a líder.
+type StandaloneLeader struct{}
+
+func (StandaloneLeader) IsLeader() bool {
+	return true
+}
+
+// Canal já fechado: a instância única sabe desde o início que é a líder.
+var standaloneKnown = func() chan struct{} {
+	known := make(chan struct{})
+	close(known)
+	return known
+}()
+
+func (StandaloneLeader) Known() <-chan struct{} {
+	return standaloneKnown
+}
@@ -0,0 +1,107 @@
+package database
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Única política de eviction que nunca descarta chaves: com ela o Valkey recusa as escritas
+// quando a memória acaba, e a falha chega à aplicação em vez de um pagamento sumir em silêncio.
+const RD_SAFE_EVICTION_POLICY = "noeviction"
+
+// MemoryWatchdog acompanha a memória do Valkey e avisa quando ela se aproxima do limite (maxmemory)
+// ou quando a política de eviction permite que chaves de pagamentos sejam descartadas.
+type MemoryWatchdog struct {
+	client    *redis.Client
+	interval  time.Duration
+	warnRatio float64
+
+	warnedPolicy bool
+	warnedLimit  bool
+	nearLimit    bool
+}
+
+// NewMemoryWatchdog cria o watchdog; warnPercent é o uso, em percentual do maxmemory, a partir do qual ele avisa.
+func NewMemoryWatchdog(client *redis.Client, interval time.Duration, warnPercent int) *MemoryWatchdog {
+	return &MemoryWatchdog{client: client, interval: interval, warnRatio: float64(warnPercent) / 100}
+}
+
+// Run verifica a memória a cada intervalo até o contexto ser cancelado.
+func (mw *MemoryWatchdog) Run(ctx context.Context) {
+	ticker := time.NewTicker(mw.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := mw.check(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("❌ falha ao consultar a memória do Valkey: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (mw *MemoryWatchdog) check(ctx context.Context) error {
+	info, err := mw.client.Info(ctx, "memory").Result()
+	if err != nil {
+		return err
+	}
+	stats := parseInfo(info)
+
+	policy := stats["maxmemory_policy"]
+	if policy != RD_SAFE_EVICTION_POLICY && !mw.warnedPolicy {
+		log.Printf("⚠️  O Valkey usa a política de eviction %q e pode descartar pagamentos quando a memória acabar; use %q", policy, RD_SAFE_EVICTION_POLICY)
+		mw.warnedPolicy = true
+	}
+
+	used, err := strconv.ParseInt(stats["used_memory"], 10, 64)
+	if err != nil {
+		return fmt.Errorf("used_memory inválido: %q", stats["used_memory"])
+	}
+	limit, _ := strconv.ParseInt(stats["maxmemory"], 10, 64)
+	if limit <= 0 {
+		// Sem maxmemory o Valkey cresce até o limite do container, que o encerra sem aviso.
+		if !mw.warnedLimit {
+			log.Printf("⚠️  O Valkey não tem maxmemory configurado (usando %s)", formatBytes(used))
+			mw.warnedLimit = true
+		}
+		return nil
+	}
+
+	ratio := float64(used) / float64(limit)
+	switch {
+	case ratio >= mw.warnRatio:
+		log.Printf("⚠️  Memória do Valkey em %.0f%% do limite (%s de %s)", ratio*100, formatBytes(used), formatBytes(limit))
+		mw.nearLimit = true
+	case mw.nearLimit:
+		log.Printf("✅ Memória do Valkey de volta a %.0f%% do limite (%s de %s)", ratio*100, formatBytes(used), formatBytes(limit))
+		mw.nearLimit = false
+	}
+	return nil
+}
+
+// parseInfo lê os campos "chave:valor" da resposta do INFO.
+func parseInfo(info string) map[string]string {
+	stats := map[string]string{}
+	scanner := bufio.NewScanner(strings.NewReader(info))
+	for scanner.Scan() {
+		if key, value, ok := strings.Cut(strings.TrimSpace(scanner.Text()), ":"); ok && !strings.HasPrefix(key, "#") {
+			stats[key] = value
+		}
+	}
+	return stats
+}
+
+func formatBytes(n int64) string {
+	return fmt.Sprintf("%.1fMB", float64(n)/(1024*1024))
+}
@@ -50,7 +50,8 @@ func ConnectToRedisClient(addr string) (*redis.Client, error) {
 	return client, err
 }
 
-func WarmUpDB(client *redis.Client) error {
+// WarmUpDB cria chaves temporárias, com o prefixo das chaves da aplicação, para aquecer as conexões do pool.
+func WarmUpDB(client *redis.Client, prefix string) error {
 	log.Println("🔥 Iniciando o aquecimento do banco de dados...")
 
 	ctx := context.Background()
@@ -58,7 +59,7 @@ func WarmUpDB(client *redis.Client) error {
 
 	// Pré-alocando 100 chaves
 	for i := 0; i < 100; i++ {
-		key := fmt.Sprintf("warmup:%d", i)
+		key := fmt.Sprintf("%swarmup:%d", prefix, i)
 		pipe.Set(ctx, key, "true", 1*time.Minute)
 	}
 
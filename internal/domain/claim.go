@@ -0,0 +1,27 @@
+package domain
+
+// ClaimStatus é a situação da reserva de um correlationId para envio aos processadores.
+type ClaimStatus string
+
+const (
+	// Um worker está enviando o pagamento aos processadores.
+	CLAIM_STATUS_CLAIMED ClaimStatus = "claimed"
+	// O pagamento já foi aceito por um processador e não deve ser enviado de novo.
+	CLAIM_STATUS_COMPLETED ClaimStatus = "completed"
+)
+
+// PaymentClaim é a reserva de um correlationId por um único worker, entre todas as instâncias.
+type PaymentClaim struct {
+	CorrelationId string
+	Status        ClaimStatus
+	// Worker que reservou ou concluiu o pagamento.
+	Owner string
+	// Acquired informa se a reserva ficou com quem a pediu.
+	Acquired bool
+}
+
+// ClaimStats conta as tentativas de processar um correlationId que outro worker já havia reservado ou concluído.
+type ClaimStats struct {
+	Claimed   int64 `json:"claimed"`
+	Completed int64 `json:"completed"`
+}
@@ -0,0 +1,24 @@
+package domain
+
+import (
+	"errors"
+	"time"
+)
+
+var ErrDeadLetterNotFound = errors.New("pagamento não encontrado na dead-letter")
+
+// PaymentAttempt é uma tentativa malsucedida de enviar um pagamento a um processador.
+type PaymentAttempt struct {
+	Processor string    `json:"processor"`
+	Error     string    `json:"error"`
+	At        time.Time `json:"at"`
+}
+
+// DeadLetter é um pagamento que falhou em todos os processadores.
+type DeadLetter struct {
+	Payment       Payment          `json:"payment"`
+	Reason        string           `json:"reason"`
+	Attempts      []PaymentAttempt `json:"attempts"`
+	FirstFailedAt time.Time        `json:"firstFailedAt"`
+	LastFailedAt  time.Time        `json:"lastFailedAt"`
+}
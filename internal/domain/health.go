@@ -0,0 +1,10 @@
+package domain
+
+import "time"
+
+// ProcessorHealth é o último estado conhecido de um processador, compartilhado entre as instâncias.
+type ProcessorHealth struct {
+	Failing         bool      `json:"failing"`
+	MinResponseTime int       `json:"minResponseTime"`
+	CheckedAt       time.Time `json:"checkedAt"`
+}
@@ -0,0 +1,18 @@
+package domain
+
+// IdempotencyRecord guarda a primeira requisição feita com uma Idempotency-Key e, depois de respondida, a sua resposta.
+type IdempotencyRecord struct {
+	// Hash do corpo da requisição, para recusar a mesma chave com outro corpo.
+	RequestHash string `json:"requestHash"`
+	// Resposta original; nil enquanto a primeira requisição ainda está sendo tratada.
+	Response *IdempotentResponse `json:"response,omitempty"`
+}
+
+// IdempotentResponse é a resposta repetida para as requisições com a mesma Idempotency-Key.
+type IdempotentResponse struct {
+	Status      int    `json:"status"`
+	ContentType string `json:"contentType,omitempty"`
+	// Corpo curto da resposta, em texto para não crescer com base64; vazio quando o original era grande demais.
+	// O campo "body", em base64, das respostas gravadas antes é ignorado.
+	Body string `json:"text,omitempty"`
+}
@@ -0,0 +1,134 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+)
+
+var ErrInvalidTransition = errors.New("transição de status inválida")
+
+// PaymentStatus é a situação de um pagamento aceito pela API.
+type PaymentStatus string
+
+const (
+	// Pagamento ainda sem registro; só é usado como origem da primeira transição.
+	PAYMENT_STATUS_NONE PaymentStatus = ""
+	// Aguardando um worker na fila.
+	PAYMENT_STATUS_QUEUED PaymentStatus = "queued"
+	// Com um worker, sendo enviado aos processadores.
+	PAYMENT_STATUS_PROCESSING PaymentStatus = "processing"
+	// Confirmado por um processador e gravado no resumo.
+	PAYMENT_STATUS_SUCCEEDED PaymentStatus = "succeeded"
+	// Recusado por todos os processadores, a caminho da dead-letter.
+	PAYMENT_STATUS_FAILED PaymentStatus = "failed"
+	// Resultado ambíguo, aguardando a reconciliação.
+	PAYMENT_STATUS_PARKED PaymentStatus = "parked"
+	// Falhou em todos os processadores e está na dead-letter.
+	PAYMENT_STATUS_DEAD_LETTERED PaymentStatus = "dead-lettered"
+	// Recusado na admissão (fila cheia ou fechada); o cliente precisa enviá-lo de novo.
+	PAYMENT_STATUS_REJECTED PaymentStatus = "rejected"
+)
+
+// paymentTransitions lista, para cada status, os status para os quais o pagamento pode ir.
+var paymentTransitions = map[PaymentStatus][]PaymentStatus{
+	PAYMENT_STATUS_NONE: {PAYMENT_STATUS_QUEUED},
+	// Um pagamento enfileirado pode ser enfileirado de novo (reenvio pelo cliente ou retomada após o desligamento).
+	// Se o envio para a fila falhar, ele volta para onde estava: recusado, estacionado ou na dead-letter.
+	PAYMENT_STATUS_QUEUED: {
+		PAYMENT_STATUS_QUEUED, PAYMENT_STATUS_PROCESSING,
+		PAYMENT_STATUS_REJECTED, PAYMENT_STATUS_PARKED, PAYMENT_STATUS_DEAD_LETTERED,
+	},
+	// Filas duráveis entregam de novo o pagamento de um worker que caiu no meio do processamento.
+	PAYMENT_STATUS_PROCESSING: {
+		PAYMENT_STATUS_PROCESSING, PAYMENT_STATUS_SUCCEEDED, PAYMENT_STATUS_FAILED, PAYMENT_STATUS_PARKED,
+	},
+	// Se a dead-letter não puder ser gravada, a fila entrega o pagamento de novo.
+	PAYMENT_STATUS_FAILED:        {PAYMENT_STATUS_PROCESSING, PAYMENT_STATUS_DEAD_LETTERED},
+	PAYMENT_STATUS_PARKED:        {PAYMENT_STATUS_SUCCEEDED, PAYMENT_STATUS_QUEUED},
+	PAYMENT_STATUS_DEAD_LETTERED: {PAYMENT_STATUS_QUEUED},
+	PAYMENT_STATUS_REJECTED:      {PAYMENT_STATUS_QUEUED},
+	PAYMENT_STATUS_SUCCEEDED:     {},
+}
+
+// CanTransitionTo informa se um pagamento com o status s pode passar para next.
+func (s PaymentStatus) CanTransitionTo(next PaymentStatus) bool {
+	return slices.Contains(paymentTransitions[s], next)
+}
+
+// PreviousStatuses retorna os status a partir dos quais um pagamento pode passar para next.
+func PreviousStatuses(next PaymentStatus) []PaymentStatus {
+	var previous []PaymentStatus
+	for status, targets := range paymentTransitions {
+		if slices.Contains(targets, next) {
+			previous = append(previous, status)
+		}
+	}
+	return previous
+}
+
+// TransitionError é a recusa de uma transição que a máquina de estados não permite.
+type TransitionError struct {
+	CorrelationId string
+	From          PaymentStatus
+	To            PaymentStatus
+}
+
+func (e *TransitionError) Error() string {
+	from := e.From
+	if from == PAYMENT_STATUS_NONE {
+		from = "(sem registro)"
+	}
+	return fmt.Sprintf("pagamento %s: %s → %s: %s", e.CorrelationId, from, e.To, ErrInvalidTransition)
+}
+
+func (e *TransitionError) Unwrap() error {
+	return ErrInvalidTransition
+}
+
+// PaymentTransition é a entrada do pagamento em um status.
+type PaymentTransition struct {
+	Status PaymentStatus `json:"status"`
+	At     time.Time     `json:"at"`
+}
+
+// ApplyTransition leva o registro atual (nil se o pagamento ainda não tem registro) para o status next,
+// com os dados do pagamento e o histórico de transições acrescido da nova (veja AppendTransition).
+func ApplyTransition(current *PaymentRecord, payment *Payment, next PaymentStatus, at time.Time) (*PaymentRecord, error) {
+	from := PAYMENT_STATUS_NONE
+	if current != nil {
+		from = current.Status
+	}
+	if !from.CanTransitionTo(next) {
+		return nil, &TransitionError{CorrelationId: payment.CorrelationId, From: from, To: next}
+	}
+
+	record := NewPaymentRecord(payment, next)
+	record.UpdatedAt = at
+	if current != nil {
+		record.Transitions = slices.Clone(current.Transitions)
+	}
+	record.Transitions = AppendTransition(record.Transitions, PaymentTransition{Status: next, At: at})
+
+	return record, nil
+}
+
+// Maior número de transições guardadas no histórico de um pagamento; as mais antigas saem primeiro.
+const MAX_PAYMENT_TRANSITIONS = 20
+
+// AppendTransition acrescenta a transição ao histórico. Uma transição para o status em que o pagamento já está
+// (a reentrega a um worker, por exemplo) só atualiza o instante da última, e só as MAX_PAYMENT_TRANSITIONS
+// mais recentes são mantidas.
+func AppendTransition(history []PaymentTransition, transition PaymentTransition) []PaymentTransition {
+	if n := len(history); n > 0 && history[n-1].Status == transition.Status {
+		history[n-1].At = transition.At
+		return history
+	}
+
+	history = append(history, transition)
+	if len(history) > MAX_PAYMENT_TRANSITIONS {
+		history = history[len(history)-MAX_PAYMENT_TRANSITIONS:]
+	}
+	return history
+}
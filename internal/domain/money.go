@@ -0,0 +1,100 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Casas decimais representadas por Money.
+const MONEY_DECIMALS = 2
+
+var (
+	ErrMoneyNotNumber = errors.New("must be a number")
+	ErrMoneyPrecision = fmt.Errorf("must have at most %d decimal places", MONEY_DECIMALS)
+	ErrMoneyOverflow  = errors.New("must be a finite number")
+)
+
+// Money é um valor monetário exato, guardado em centavos para que somas não acumulem erros de ponto flutuante.
+// No JSON ele é escrito como número decimal (ex.: 19.90), numericamente igual ao que o cliente enviou.
+type Money int64
+
+var centsPerUnit = big.NewRat(100, 1)
+
+// ParseMoney converte um número decimal (ex.: "19.9" ou "1.99e1") em Money sem passar por float64.
+// Valores com mais de duas casas decimais ou que não cabem em int64 centavos são recusados.
+func ParseMoney(value string) (Money, error) {
+	// big.Rat também aceita frações ("1/2") e prefixos ("0x10"), que não são números decimais válidos.
+	if strings.IndexFunc(value, func(r rune) bool {
+		return !strings.ContainsRune("0123456789.eE+-", r)
+	}) >= 0 {
+		return 0, ErrMoneyNotNumber
+	}
+
+	rat, ok := new(big.Rat).SetString(value)
+	if !ok {
+		return 0, ErrMoneyNotNumber
+	}
+
+	cents := rat.Mul(rat, centsPerUnit)
+	if !cents.IsInt() {
+		return 0, ErrMoneyPrecision
+	}
+	if !cents.Num().IsInt64() {
+		return 0, ErrMoneyOverflow
+	}
+
+	return Money(cents.Num().Int64()), nil
+}
+
+// MoneyFromFloat converte um float64 arredondando para o centavo mais próximo.
+// Serve apenas para dados legados gravados como float.
+func MoneyFromFloat(value float64) Money {
+	return Money(math.Round(value * 100))
+}
+
+func (m Money) Cents() int64 {
+	return int64(m)
+}
+
+func (m Money) Float64() float64 {
+	return float64(m) / 100
+}
+
+func (m Money) String() string {
+	cents := int64(m)
+	sign := ""
+	if cents < 0 {
+		sign = "-"
+		cents = -cents
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, cents/100, cents%100)
+}
+
+func (m Money) MarshalJSON() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+func (m *Money) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+	parsed, err := ParseMoney(string(data))
+	if err != nil {
+		return fmt.Errorf("valor monetário inválido %s: %w", data, err)
+	}
+	*m = parsed
+	return nil
+}
+
+// ParseMoneyCents lê um valor gravado em centavos, como no Valkey.
+func ParseMoneyCents(value string) (Money, error) {
+	cents, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	return Money(cents), nil
+}
@@ -0,0 +1,16 @@
+package domain
+
+import "time"
+
+// ParkedPayment é um pagamento cujo resultado não pôde ser confirmado em algum processador.
+// Ele fica estacionado até a reconciliação descobrir onde (e se) foi processado.
+type ParkedPayment struct {
+	Payment Payment `json:"payment"`
+	// Processadores que podem ter ficado com o pagamento.
+	Processors []string  `json:"processors"`
+	ParkedAt   time.Time `json:"parkedAt"`
+	Attempts   int       `json:"attempts"`
+	LastError  string    `json:"lastError"`
+	// Worker que estacionou o pagamento e ainda tem a sua reserva.
+	ClaimOwner string `json:"claimOwner,omitempty"`
+}
@@ -6,11 +6,19 @@ import (
 	"github.com/google/uuid"
 )
 
+const (
+	PROCESSOR_DEFAULT  = "default"
+	PROCESSOR_FALLBACK = "fallback"
+)
+
 type Payment struct {
 	CorrelationId string // Tem que ser um UUID valido no momento sem validação
-	Amount        float64
+	Amount        Money
 	Processor     string // "default" ou "fallback"
+	Strategy      string // Estratégia de roteamento que escolheu o processador
 	RequestedAt   time.Time
+	Attempts      int    // Envios a processadores até agora
+	LastError     string // Erro do último envio malsucedido
 }
 
 func (p *Payment) ValidateCorrelationId() bool {
@@ -0,0 +1,13 @@
+package domain
+
+import "time"
+
+// Processor é um processador de pagamentos configurado.
+type Processor struct {
+	Name       string        `json:"name"`
+	PaymentURL string        `json:"paymentUrl"`
+	HealthURL  string        `json:"healthUrl"`
+	Fee        float64       `json:"fee"`
+	Priority   int           `json:"priority"` // Menor valor = maior prioridade
+	Timeout    time.Duration `json:"-"`
+}
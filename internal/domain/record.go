@@ -0,0 +1,44 @@
+package domain
+
+import (
+	"errors"
+	"time"
+)
+
+var ErrPaymentNotFound = errors.New("pagamento não encontrado")
+
+// PaymentRecord é o registro completo de um pagamento, consultado por GET /payments/{correlationId}.
+type PaymentRecord struct {
+	CorrelationId string        `json:"correlationId"`
+	Amount        Money         `json:"amount"`
+	Status        PaymentStatus `json:"status"`
+	Processor     string        `json:"processor,omitempty"`
+	Strategy      string        `json:"strategy,omitempty"`
+	// Instante enviado ao processador; vazio enquanto o pagamento não foi processado.
+	RequestedAt *time.Time `json:"requestedAt,omitempty"`
+	// Número de envios a processadores.
+	Attempts  int       `json:"attempts"`
+	LastError string    `json:"lastError,omitempty"`
+	UpdatedAt time.Time `json:"updatedAt"`
+	// Cada status pelo qual o pagamento passou, com o instante da transição.
+	Transitions []PaymentTransition `json:"transitions,omitempty"`
+}
+
+// NewPaymentRecord monta o registro do pagamento com o status informado.
+func NewPaymentRecord(payment *Payment, status PaymentStatus) *PaymentRecord {
+	record := &PaymentRecord{
+		CorrelationId: payment.CorrelationId,
+		Amount:        payment.Amount,
+		Status:        status,
+		Processor:     payment.Processor,
+		Strategy:      payment.Strategy,
+		Attempts:      payment.Attempts,
+		LastError:     payment.LastError,
+		UpdatedAt:     time.Now(),
+	}
+	if !payment.RequestedAt.IsZero() {
+		requestedAt := payment.RequestedAt
+		record.RequestedAt = &requestedAt
+	}
+	return record
+}
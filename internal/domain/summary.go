@@ -1,11 +1,9 @@
 package domain
 
 type SummaryItem struct {
-	TotalRequests int64   `json:"totalRequests"`
-	TotalAmount   float64 `json:"totalAmount"`
+	TotalRequests int64 `json:"totalRequests"`
+	TotalAmount   Money `json:"totalAmount"`
 }
 
-type Summary struct {
-	Default  SummaryItem `json:"default"`
-	Fallback SummaryItem `json:"fallback"`
-}
+// Summary possui uma entrada por processador, indexada pelo nome do processador.
+type Summary map[string]SummaryItem
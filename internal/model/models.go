@@ -1,16 +1,20 @@
 package model
 
-import "time"
+import (
+	"time"
+
+	"github.com/nicolasmmb/go-rinha-backend-2025/internal/domain"
+)
 
 type PaymentRequest struct {
-	CorrelationID string  `json:"correlationId"`
-	Amount        float64 `json:"amount"`
+	CorrelationID string       `json:"correlationId"`
+	Amount        domain.Money `json:"amount"`
 }
 
 type ProcessorPaymentRequest struct {
-	CorrelationID string    `json:"correlationId"`
-	Amount        float64   `json:"amount"`
-	RequestedAt   time.Time `json:"requestedAt"`
+	CorrelationID string       `json:"correlationId"`
+	Amount        domain.Money `json:"amount"`
+	RequestedAt   time.Time    `json:"requestedAt"`
 }
 
 type HealthStatus struct {
@@ -19,8 +23,8 @@ type HealthStatus struct {
 }
 
 type SummaryDetail struct {
-	TotalRequests int64   `json:"totalRequests"`
-	TotalAmount   float64 `json:"totalAmount"`
+	TotalRequests int64        `json:"totalRequests"`
+	TotalAmount   domain.Money `json:"totalAmount"`
 }
 
 type SummaryResponse struct {
@@ -0,0 +1,67 @@
+package file
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+
+	"github.com/nicolasmmb/go-rinha-backend-2025/internal/core"
+	"github.com/nicolasmmb/go-rinha-backend-2025/internal/domain"
+)
+
+type spillFileRepository struct {
+	path string
+}
+
+// NewQueueSpillRepository guarda os pagamentos que sobraram na fila em um arquivo local (JSON por linha).
+func NewQueueSpillRepository(path string) core.QueueSpillRepositoryInterface {
+	return &spillFileRepository{path: path}
+}
+
+func (r *spillFileRepository) SaveSpilled(_ context.Context, payments []domain.Payment) error {
+	f, err := os.OpenFile(r.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+
+	encoder := json.NewEncoder(f)
+	for i := range payments {
+		if err := encoder.Encode(&payments[i]); err != nil {
+			f.Close()
+			return err
+		}
+	}
+
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func (r *spillFileRepository) TakeSpilled(_ context.Context) ([]domain.Payment, error) {
+	f, err := os.Open(r.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var payments []domain.Payment
+	decoder := json.NewDecoder(f)
+	for decoder.More() {
+		var payment domain.Payment
+		if err := decoder.Decode(&payment); err != nil {
+			return nil, err
+		}
+		payments = append(payments, payment)
+	}
+
+	if err := os.Remove(r.path); err != nil {
+		return nil, err
+	}
+	return payments, nil
+}
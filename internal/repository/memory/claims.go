@@ -0,0 +1,113 @@
+package memory
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/nicolasmmb/go-rinha-backend-2025/internal/core"
+	"github.com/nicolasmmb/go-rinha-backend-2025/internal/domain"
+)
+
+type memoryClaim struct {
+	claim domain.PaymentClaim
+	// Instante em que a reserva expira; zero para não expirar.
+	expiresAt time.Time
+}
+
+func (c memoryClaim) expired(now time.Time) bool {
+	return !c.expiresAt.IsZero() && !now.Before(c.expiresAt)
+}
+
+// Intervalo mínimo entre as varreduras que removem os registros expirados (reservas e chaves de idempotência).
+const EXPIRED_SWEEP_INTERVAL = time.Minute
+
+type claimMemoryRepository struct {
+	mu     sync.Mutex
+	claims map[string]memoryClaim
+	stats  domain.ClaimStats
+	swept  time.Time
+}
+
+func NewClaimRepository() core.ClaimRepositoryInterface {
+	return &claimMemoryRepository{claims: map[string]memoryClaim{}}
+}
+
+func expiresAt(now time.Time, ttl time.Duration) time.Time {
+	if ttl <= 0 {
+		return time.Time{}
+	}
+	return now.Add(ttl)
+}
+
+func (r *claimMemoryRepository) ClaimPayment(_ context.Context, correlationId, owner string, ttl time.Duration) (*domain.PaymentClaim, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	current, ok := r.claims[correlationId]
+	if ok && !current.expired(now) && (current.claim.Status != domain.CLAIM_STATUS_CLAIMED || current.claim.Owner != owner) {
+		if current.claim.Status == domain.CLAIM_STATUS_COMPLETED {
+			r.stats.Completed++
+		} else {
+			r.stats.Claimed++
+		}
+		claim := current.claim
+		return &claim, nil
+	}
+
+	r.store(now, correlationId, domain.CLAIM_STATUS_CLAIMED, owner, ttl)
+	return &domain.PaymentClaim{CorrelationId: correlationId, Status: domain.CLAIM_STATUS_CLAIMED, Owner: owner, Acquired: true}, nil
+}
+
+// store grava a reserva e, de tempos em tempos, remove as expiradas, que no Valkey sairiam pelo TTL.
+func (r *claimMemoryRepository) store(now time.Time, correlationId string, status domain.ClaimStatus, owner string, ttl time.Duration) {
+	r.claims[correlationId] = memoryClaim{
+		claim:     domain.PaymentClaim{CorrelationId: correlationId, Status: status, Owner: owner},
+		expiresAt: expiresAt(now, ttl),
+	}
+
+	if now.Sub(r.swept) < EXPIRED_SWEEP_INTERVAL {
+		return
+	}
+	for id, claim := range r.claims {
+		if claim.expired(now) {
+			delete(r.claims, id)
+		}
+	}
+	r.swept = now
+}
+
+func (r *claimMemoryRepository) CompleteClaim(_ context.Context, correlationId, owner string, ttl time.Duration) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.store(time.Now(), correlationId, domain.CLAIM_STATUS_COMPLETED, owner, ttl)
+	return nil
+}
+
+func (r *claimMemoryRepository) ReleaseClaim(_ context.Context, correlationId, owner string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if current, ok := r.claims[correlationId]; ok && current.claim.Status == domain.CLAIM_STATUS_CLAIMED && current.claim.Owner == owner {
+		delete(r.claims, correlationId)
+	}
+	return nil
+}
+
+func (r *claimMemoryRepository) GetClaimStats(_ context.Context) (domain.ClaimStats, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.stats, nil
+}
+
+func (r *claimMemoryRepository) ResetClaims(_ context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.claims = map[string]memoryClaim{}
+	r.stats = domain.ClaimStats{}
+	return nil
+}
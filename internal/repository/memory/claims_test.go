@@ -0,0 +1,12 @@
+package memory_test
+
+import (
+	"testing"
+
+	"github.com/nicolasmmb/go-rinha-backend-2025/internal/repository/memory"
+	"github.com/nicolasmmb/go-rinha-backend-2025/internal/repository/repotest"
+)
+
+func TestClaimRepository(t *testing.T) {
+	repotest.TestClaimRepository(t, memory.NewClaimRepository())
+}
@@ -0,0 +1,93 @@
+package memory
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/nicolasmmb/go-rinha-backend-2025/internal/core"
+	"github.com/nicolasmmb/go-rinha-backend-2025/internal/domain"
+)
+
+type deadLetterMemoryRepository struct {
+	mu          sync.Mutex
+	deadLetters map[string]domain.DeadLetter
+}
+
+func NewDeadLetterRepository() core.DeadLetterRepositoryInterface {
+	return &deadLetterMemoryRepository{deadLetters: map[string]domain.DeadLetter{}}
+}
+
+func cloneDeadLetter(deadLetter domain.DeadLetter) domain.DeadLetter {
+	deadLetter.Attempts = slices.Clone(deadLetter.Attempts)
+	return deadLetter
+}
+
+func (r *deadLetterMemoryRepository) SaveDeadLetter(_ context.Context, deadLetter *domain.DeadLetter) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.deadLetters[deadLetter.Payment.CorrelationId] = cloneDeadLetter(*deadLetter)
+	return nil
+}
+
+func (r *deadLetterMemoryRepository) GetDeadLetter(_ context.Context, correlationId string) (*domain.DeadLetter, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	deadLetter, ok := r.deadLetters[correlationId]
+	if !ok {
+		return nil, domain.ErrDeadLetterNotFound
+	}
+
+	deadLetter = cloneDeadLetter(deadLetter)
+	return &deadLetter, nil
+}
+
+func (r *deadLetterMemoryRepository) ListDeadLetters(_ context.Context, offset, limit int) ([]domain.DeadLetter, int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// Mesma ordem do índice no Redis: primeira falha e, no empate, correlationId.
+	all := make([]domain.DeadLetter, 0, len(r.deadLetters))
+	for _, deadLetter := range r.deadLetters {
+		all = append(all, deadLetter)
+	}
+	slices.SortFunc(all, func(a, b domain.DeadLetter) int {
+		if c := a.FirstFailedAt.Compare(b.FirstFailedAt); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Payment.CorrelationId, b.Payment.CorrelationId)
+	})
+
+	total := int64(len(all))
+	if offset >= len(all) || limit <= 0 {
+		return []domain.DeadLetter{}, total, nil
+	}
+
+	page := all[offset:min(offset+limit, len(all))]
+	deadLetters := make([]domain.DeadLetter, 0, len(page))
+	for _, deadLetter := range page {
+		deadLetters = append(deadLetters, cloneDeadLetter(deadLetter))
+	}
+
+	return deadLetters, total, nil
+}
+
+func (r *deadLetterMemoryRepository) DeleteDeadLetter(_ context.Context, correlationId string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.deadLetters, correlationId)
+	return nil
+}
+
+func (r *deadLetterMemoryRepository) PurgeDeadLetters(_ context.Context) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	total := int64(len(r.deadLetters))
+	r.deadLetters = map[string]domain.DeadLetter{}
+	return total, nil
+}
@@ -0,0 +1,64 @@
+package memory
+
+import (
+	"context"
+	"maps"
+	"sync"
+
+	"github.com/nicolasmmb/go-rinha-backend-2025/internal/core"
+	"github.com/nicolasmmb/go-rinha-backend-2025/internal/domain"
+)
+
+type healthMemoryRepository struct {
+	mu          sync.Mutex
+	statuses    map[string]domain.ProcessorHealth
+	subscribers map[chan map[string]domain.ProcessorHealth]struct{}
+}
+
+func NewHealthRepository() core.HealthRepositoryInterface {
+	return &healthMemoryRepository{
+		statuses:    map[string]domain.ProcessorHealth{},
+		subscribers: map[chan map[string]domain.ProcessorHealth]struct{}{},
+	}
+}
+
+func (r *healthMemoryRepository) SaveHealth(_ context.Context, statuses map[string]domain.ProcessorHealth) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.statuses = maps.Clone(statuses)
+	for updates := range r.subscribers {
+		// Assinantes lentos perdem a atualização intermediária, mas recebem a próxima.
+		select {
+		case updates <- maps.Clone(statuses):
+		default:
+		}
+	}
+	return nil
+}
+
+func (r *healthMemoryRepository) GetHealth(_ context.Context) (map[string]domain.ProcessorHealth, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return maps.Clone(r.statuses), nil
+}
+
+func (r *healthMemoryRepository) SubscribeHealth(ctx context.Context) <-chan map[string]domain.ProcessorHealth {
+	updates := make(chan map[string]domain.ProcessorHealth, 1)
+
+	r.mu.Lock()
+	r.subscribers[updates] = struct{}{}
+	r.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+
+		r.mu.Lock()
+		delete(r.subscribers, updates)
+		r.mu.Unlock()
+		close(updates)
+	}()
+
+	return updates
+}
@@ -0,0 +1,86 @@
+package memory
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/nicolasmmb/go-rinha-backend-2025/internal/core"
+	"github.com/nicolasmmb/go-rinha-backend-2025/internal/domain"
+)
+
+type memoryIdempotencyRecord struct {
+	record    domain.IdempotencyRecord
+	expiresAt time.Time
+}
+
+type idempotencyMemoryRepository struct {
+	mu      sync.Mutex
+	records map[string]memoryIdempotencyRecord
+	swept   time.Time
+}
+
+func NewIdempotencyRepository() core.IdempotencyRepositoryInterface {
+	return &idempotencyMemoryRepository{records: map[string]memoryIdempotencyRecord{}}
+}
+
+// copyIdempotencyRecord evita que quem chama altere o registro guardado.
+func copyIdempotencyRecord(record domain.IdempotencyRecord) *domain.IdempotencyRecord {
+	if record.Response != nil {
+		response := *record.Response
+		record.Response = &response
+	}
+	return &record
+}
+
+func (r *idempotencyMemoryRepository) ReserveIdempotencyKey(_ context.Context, key, requestHash string, ttl time.Duration) (*domain.IdempotencyRecord, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	if current, ok := r.records[key]; ok && now.Before(current.expiresAt) {
+		return copyIdempotencyRecord(current.record), nil
+	}
+
+	r.store(now, key, domain.IdempotencyRecord{RequestHash: requestHash}, ttl)
+	return nil, nil
+}
+
+// store grava o registro e, de tempos em tempos, remove os expirados, que no Valkey sairiam pelo TTL.
+func (r *idempotencyMemoryRepository) store(now time.Time, key string, record domain.IdempotencyRecord, ttl time.Duration) {
+	r.records[key] = memoryIdempotencyRecord{record: *copyIdempotencyRecord(record), expiresAt: now.Add(ttl)}
+
+	if now.Sub(r.swept) < EXPIRED_SWEEP_INTERVAL {
+		return
+	}
+	for key, record := range r.records {
+		if !now.Before(record.expiresAt) {
+			delete(r.records, key)
+		}
+	}
+	r.swept = now
+}
+
+func (r *idempotencyMemoryRepository) SaveIdempotentResponse(_ context.Context, key string, record *domain.IdempotencyRecord, ttl time.Duration) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.store(time.Now(), key, *record, ttl)
+	return nil
+}
+
+func (r *idempotencyMemoryRepository) ReleaseIdempotencyKey(_ context.Context, key string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.records, key)
+	return nil
+}
+
+func (r *idempotencyMemoryRepository) ResetIdempotencyKeys(_ context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.records = map[string]memoryIdempotencyRecord{}
+	return nil
+}
@@ -0,0 +1,176 @@
+package memory
+
+import (
+	"context"
+	"log/slog"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nicolasmmb/go-rinha-backend-2025/internal/core"
+	"github.com/nicolasmmb/go-rinha-backend-2025/internal/domain"
+)
+
+// timelineEntry é um pagamento no índice de tempo de um processador.
+type timelineEntry struct {
+	at            int64
+	correlationId string
+}
+
+func compareEntries(a, b timelineEntry) int {
+	if a.at != b.at {
+		if a.at < b.at {
+			return -1
+		}
+		return 1
+	}
+	return strings.Compare(a.correlationId, b.correlationId)
+}
+
+// processorPayments guarda os pagamentos de um processador: o índice ordenado por tempo e os valores por correlationId.
+type processorPayments struct {
+	timeline []timelineEntry
+	payload  map[string]domain.Money
+}
+
+type paymentsMemoryRepository struct {
+	mu         sync.RWMutex
+	processors map[string]*processorPayments
+	routing    map[string]map[string]domain.SummaryItem
+	records    map[string]domain.PaymentRecord
+}
+
+// NewPaymentsRepository cria um repositório de pagamentos em memória, para execução em uma única instância.
+// Os dados se perdem quando o processo termina.
+func NewPaymentsRepository() core.PaymentRepositoryInterface {
+	return &paymentsMemoryRepository{
+		processors: map[string]*processorPayments{},
+		routing:    map[string]map[string]domain.SummaryItem{},
+		records:    map[string]domain.PaymentRecord{},
+	}
+}
+
+func (r *paymentsMemoryRepository) SavePayment(_ context.Context, payment *domain.Payment) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	payments, ok := r.processors[payment.Processor]
+	if !ok {
+		payments = &processorPayments{payload: map[string]domain.Money{}}
+		r.processors[payment.Processor] = payments
+	}
+
+	if _, exists := payments.payload[payment.CorrelationId]; exists {
+		return false, nil
+	}
+
+	entry := timelineEntry{at: payment.RequestedAt.UnixNano(), correlationId: payment.CorrelationId}
+	i, _ := slices.BinarySearchFunc(payments.timeline, entry, compareEntries)
+	payments.timeline = slices.Insert(payments.timeline, i, entry)
+	payments.payload[payment.CorrelationId] = payment.Amount
+
+	if payment.Strategy != "" {
+		byProcessor, ok := r.routing[payment.Strategy]
+		if !ok {
+			byProcessor = map[string]domain.SummaryItem{}
+			r.routing[payment.Strategy] = byProcessor
+		}
+		item := byProcessor[payment.Processor]
+		item.TotalRequests++
+		item.TotalAmount += payment.Amount
+		byProcessor[payment.Processor] = item
+	}
+
+	if err := r.transition(payment, domain.PAYMENT_STATUS_SUCCEEDED); err != nil {
+		slog.Warn("pagamento gravado sem atualizar o registro", "correlationId", payment.CorrelationId, "error", err.Error())
+	}
+
+	return true, nil
+}
+
+func (r *paymentsMemoryRepository) GetSummaryByProcessor(_ context.Context, typeOfProcessor string, from, to time.Time) (*domain.SummaryItem, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	result := &domain.SummaryItem{}
+	payments, ok := r.processors[typeOfProcessor]
+	if !ok {
+		return result, nil
+	}
+
+	// A janela é fechada nas duas pontas, como no ZRANGEBYSCORE.
+	start, _ := slices.BinarySearchFunc(payments.timeline, from.UnixNano(), func(e timelineEntry, at int64) int {
+		if e.at < at {
+			return -1
+		}
+		return 1
+	})
+	toNanos := to.UnixNano()
+	for _, entry := range payments.timeline[start:] {
+		if entry.at > toNanos {
+			break
+		}
+		result.TotalRequests++
+		result.TotalAmount += payments.payload[entry.correlationId]
+	}
+
+	return result, nil
+}
+
+func (r *paymentsMemoryRepository) GetRoutingStats(_ context.Context) (map[string]map[string]domain.SummaryItem, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	stats := make(map[string]map[string]domain.SummaryItem, len(r.routing))
+	for strategy, byProcessor := range r.routing {
+		stats[strategy] = maps.Clone(byProcessor)
+	}
+
+	return stats, nil
+}
+
+func (r *paymentsMemoryRepository) ResetState(_ context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.processors = map[string]*processorPayments{}
+	r.routing = map[string]map[string]domain.SummaryItem{}
+	r.records = map[string]domain.PaymentRecord{}
+	return nil
+}
+
+func (r *paymentsMemoryRepository) TransitionPayment(_ context.Context, payment *domain.Payment, status domain.PaymentStatus) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.transition(payment, status)
+}
+
+// transition aplica a transição ao registro; quem chama precisa estar com a trava de escrita.
+func (r *paymentsMemoryRepository) transition(payment *domain.Payment, status domain.PaymentStatus) error {
+	var current *domain.PaymentRecord
+	if record, ok := r.records[payment.CorrelationId]; ok {
+		current = &record
+	}
+
+	record, err := domain.ApplyTransition(current, payment, status, time.Now())
+	if err != nil {
+		return err
+	}
+	r.records[payment.CorrelationId] = *record
+	return nil
+}
+
+func (r *paymentsMemoryRepository) GetPaymentRecord(_ context.Context, correlationId string) (*domain.PaymentRecord, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	record, ok := r.records[correlationId]
+	if !ok {
+		return nil, domain.ErrPaymentNotFound
+	}
+	record.Transitions = slices.Clone(record.Transitions)
+	return &record, nil
+}
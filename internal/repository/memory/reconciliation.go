@@ -0,0 +1,58 @@
+package memory
+
+import (
+	"context"
+	"slices"
+	"sync"
+
+	"github.com/nicolasmmb/go-rinha-backend-2025/internal/core"
+	"github.com/nicolasmmb/go-rinha-backend-2025/internal/domain"
+)
+
+type reconciliationMemoryRepository struct {
+	mu     sync.Mutex
+	parked map[string]domain.ParkedPayment
+}
+
+func NewReconciliationRepository() core.ReconciliationRepositoryInterface {
+	return &reconciliationMemoryRepository{parked: map[string]domain.ParkedPayment{}}
+}
+
+func (r *reconciliationMemoryRepository) ParkPayment(_ context.Context, parked *domain.ParkedPayment) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	copied := *parked
+	copied.Processors = slices.Clone(parked.Processors)
+	r.parked[parked.Payment.CorrelationId] = copied
+	return nil
+}
+
+func (r *reconciliationMemoryRepository) ListParkedPayments(_ context.Context) ([]domain.ParkedPayment, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	parkedPayments := make([]domain.ParkedPayment, 0, len(r.parked))
+	for _, parked := range r.parked {
+		parked.Processors = slices.Clone(parked.Processors)
+		parkedPayments = append(parkedPayments, parked)
+	}
+	return parkedPayments, nil
+}
+
+func (r *reconciliationMemoryRepository) RemoveParkedPayment(_ context.Context, correlationId string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.parked, correlationId)
+	return nil
+}
+
+func (r *reconciliationMemoryRepository) PurgeParkedPayments(_ context.Context) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	total := int64(len(r.parked))
+	r.parked = map[string]domain.ParkedPayment{}
+	return total, nil
+}
@@ -0,0 +1,37 @@
+package memory
+
+import (
+	"context"
+	"sync"
+
+	"github.com/nicolasmmb/go-rinha-backend-2025/internal/core"
+	"github.com/nicolasmmb/go-rinha-backend-2025/internal/domain"
+)
+
+type spillMemoryRepository struct {
+	mu       sync.Mutex
+	payments []domain.Payment
+}
+
+// NewQueueSpillRepository guarda os pagamentos que sobraram na fila apenas enquanto o processo existir.
+// Sem um arquivo de spill configurado, eles se perdem no desligamento.
+func NewQueueSpillRepository() core.QueueSpillRepositoryInterface {
+	return &spillMemoryRepository{}
+}
+
+func (r *spillMemoryRepository) SaveSpilled(_ context.Context, payments []domain.Payment) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.payments = append(r.payments, payments...)
+	return nil
+}
+
+func (r *spillMemoryRepository) TakeSpilled(_ context.Context) ([]domain.Payment, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	payments := r.payments
+	r.payments = nil
+	return payments, nil
+}
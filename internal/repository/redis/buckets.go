@@ -0,0 +1,207 @@
+package redis
+
+import (
+	"context"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/nicolasmmb/go-rinha-backend-2025/internal/domain"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// Contadores por bucket de tempo: processador, tamanho do bucket em ms, tamanho do shard em ms e shard.
+	// Os tamanhos fazem parte da chave para que mudar a configuração não misture buckets diferentes.
+	RD_KEY_TX_BUCKET_COUNT  = "tx:bucket:count:%s:%d:%d:%d"
+	RD_KEY_TX_BUCKET_AMOUNT = "tx:bucket:amount:%s:%d:%d:%d"
+
+	// Quantidade de buckets lidos por HMGET.
+	RD_BUCKET_READ_BATCH = 1000
+)
+
+// timeBuckets divide a linha do tempo em buckets de tamanho fixo.
+//
+// O timeline guarda o instante em nanossegundos como score de um sorted set, que é um double;
+// acima de 2^53 esse valor é arredondado. Para que o resumo por buckets seja igual ao exato,
+// os limites dos buckets são comparados no mesmo espaço de doubles usado pelo ZRANGEBYSCORE.
+type timeBuckets struct {
+	size int64
+}
+
+func newTimeBuckets(size time.Duration) timeBuckets {
+	return timeBuckets{size: size.Nanoseconds()}
+}
+
+func (tb timeBuckets) sizeMs() int64 {
+	return tb.size / int64(time.Millisecond)
+}
+
+// boundary é o início do bucket b, como o Redis o enxerga.
+func (tb timeBuckets) boundary(b int64) float64 {
+	return float64(b * tb.size)
+}
+
+// bucketOf retorna o bucket b em que boundary(b) <= score < boundary(b+1).
+func (tb timeBuckets) bucketOf(score float64) int64 {
+	b := int64(math.Floor(score / float64(tb.size)))
+	for tb.boundary(b) > score {
+		b--
+	}
+	for tb.boundary(b+1) <= score {
+		b++
+	}
+	return b
+}
+
+// fullRange retorna o primeiro e o último bucket inteiramente contidos em [from, to].
+// Quando nenhum bucket cabe na janela, first > last.
+func (tb timeBuckets) fullRange(from, to float64) (first, last int64) {
+	first = tb.bucketOf(from)
+	if tb.boundary(first) < from {
+		first++
+	}
+	last = tb.bucketOf(to)
+	for tb.boundary(last+1) > to {
+		last--
+	}
+	return first, last
+}
+
+func formatScore(score float64) string {
+	return strconv.FormatFloat(score, 'f', -1, 64)
+}
+
+func (r *paymentsRedisRepository) bucketCountKey(processor string, shard int64) string {
+	return r.ns.Key(RD_KEY_TX_BUCKET_COUNT, processor, r.buckets.sizeMs(), r.shards.sizeMs(), shard)
+}
+
+func (r *paymentsRedisRepository) bucketAmountKey(processor string, shard int64) string {
+	return r.ns.Key(RD_KEY_TX_BUCKET_AMOUNT, processor, r.buckets.sizeMs(), r.shards.sizeMs(), shard)
+}
+
+// getSummaryFromBuckets soma os buckets inteiros da janela e faz a busca exata apenas nas bordas.
+func (r *paymentsRedisRepository) getSummaryFromBuckets(ctx context.Context, processor string, from, to time.Time) (*domain.SummaryItem, error) {
+	fromScore := float64(from.UnixNano())
+	toScore := float64(to.UnixNano())
+
+	shards, err := r.shardsInWindow(ctx, processor, fromScore, toScore)
+	if err != nil {
+		return nil, err
+	}
+
+	// A janela é limitada ao intervalo com dados, para que janelas enormes não percorram buckets vazios.
+	pipeline := r.db.Pipeline()
+	oldest := make([]*redis.ZSliceCmd, len(shards))
+	newest := make([]*redis.ZSliceCmd, len(shards))
+	for i, shard := range shards {
+		oldest[i] = pipeline.ZRangeWithScores(ctx, r.timelineKey(processor, shard), 0, 0)
+		newest[i] = pipeline.ZRangeWithScores(ctx, r.timelineKey(processor, shard), -1, -1)
+	}
+	if _, err := pipeline.Exec(ctx); err != nil {
+		return nil, err
+	}
+
+	found := false
+	for i := range shards {
+		if len(oldest[i].Val()) > 0 {
+			fromScore = max(fromScore, oldest[i].Val()[0].Score)
+			found = true
+			break
+		}
+	}
+	for i := len(shards) - 1; i >= 0; i-- {
+		if len(newest[i].Val()) > 0 {
+			toScore = min(toScore, newest[i].Val()[0].Score)
+			break
+		}
+	}
+	if !found || fromScore > toScore {
+		return &domain.SummaryItem{}, nil
+	}
+
+	first, last := r.buckets.fullRange(fromScore, toScore)
+	if first > last {
+		return r.sumRange(ctx, processor, r.shardsBetween(shards, fromScore, toScore), formatScore(fromScore), formatScore(toScore))
+	}
+
+	result, err := r.sumBuckets(ctx, processor, shards, first, last)
+	if err != nil {
+		return nil, err
+	}
+
+	edges := []struct {
+		from, to       float64
+		minArg, maxArg string
+	}{
+		{fromScore, r.buckets.boundary(first), formatScore(fromScore), "(" + formatScore(r.buckets.boundary(first))},
+		{r.buckets.boundary(last + 1), toScore, formatScore(r.buckets.boundary(last + 1)), formatScore(toScore)},
+	}
+	for _, edge := range edges {
+		item, err := r.sumRange(ctx, processor, r.shardsBetween(shards, edge.from, edge.to), edge.minArg, edge.maxArg)
+		if err != nil {
+			return nil, err
+		}
+		result.TotalRequests += item.TotalRequests
+		result.TotalAmount += item.TotalAmount
+	}
+
+	return result, nil
+}
+
+// sumBuckets soma os buckets de first a last que estão nos shards informados.
+func (r *paymentsRedisRepository) sumBuckets(ctx context.Context, processor string, shards []int64, first, last int64) (*domain.SummaryItem, error) {
+	result := &domain.SummaryItem{}
+
+	for _, shard := range shards {
+		shardFirst, shardLast := r.shardBuckets(shard)
+		shardFirst, shardLast = max(shardFirst, first), min(shardLast, last)
+
+		for start := shardFirst; start <= shardLast; start += RD_BUCKET_READ_BATCH {
+			end := min(start+RD_BUCKET_READ_BATCH-1, shardLast)
+			if err := r.sumBucketBatch(ctx, processor, shard, start, end, result); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// sumBucketBatch acrescenta ao resultado os buckets de start a end, todos do mesmo shard.
+func (r *paymentsRedisRepository) sumBucketBatch(ctx context.Context, processor string, shard, start, end int64, result *domain.SummaryItem) error {
+	fields := make([]string, 0, end-start+1)
+	for b := start; b <= end; b++ {
+		fields = append(fields, strconv.FormatInt(b, 10))
+	}
+
+	pipeline := r.db.Pipeline()
+	counts := pipeline.HMGet(ctx, r.bucketCountKey(processor, shard), fields...)
+	amounts := pipeline.HMGet(ctx, r.bucketAmountKey(processor, shard), fields...)
+	if _, err := pipeline.Exec(ctx); err != nil {
+		return err
+	}
+
+	for i, value := range counts.Val() {
+		count, ok := value.(string)
+		if !ok {
+			continue
+		}
+		requests, err := strconv.ParseInt(count, 10, 64)
+		if err != nil {
+			return err
+		}
+		result.TotalRequests += requests
+
+		// Contagem e valor são gravados juntos, então o valor só falta se o bucket não existir.
+		if cents, ok := amounts.Val()[i].(string); ok {
+			amount, err := domain.ParseMoneyCents(cents)
+			if err != nil {
+				return err
+			}
+			result.TotalAmount += amount
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,120 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/nicolasmmb/go-rinha-backend-2025/internal/core"
+	"github.com/nicolasmmb/go-rinha-backend-2025/internal/domain"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// Reserva de cada correlationId (status e dono), que expira com o TTL da reserva.
+	RD_KEY_TX_CLAIM = "tx:claim:%s"
+	// Tentativas duplicadas por status da reserva encontrada.
+	RD_KEY_TX_CLAIM_DUPLICATES = "tx:claims:duplicates"
+
+	RD_PATTERN_TX_CLAIMS = "tx:claim:*"
+)
+
+// KEYS: reserva, duplicatas. ARGV: dono, TTL em ms (zero para não expirar).
+// O mesmo dono pode renovar a própria reserva. Retorna {obtida, status, dono}.
+var claimPaymentScript = redis.NewScript(`
+local claim = redis.call("HMGET", KEYS[1], "status", "owner")
+if not claim[1] or (claim[1] == "claimed" and claim[2] == ARGV[1]) then
+	redis.call("HSET", KEYS[1], "status", "claimed", "owner", ARGV[1])
+	if tonumber(ARGV[2]) > 0 then
+		redis.call("PEXPIRE", KEYS[1], ARGV[2])
+	end
+	return {1, "claimed", ARGV[1]}
+end
+
+redis.call("HINCRBY", KEYS[2], claim[1], 1)
+return {0, claim[1], claim[2] or ""}
+`)
+
+// KEYS: reserva. ARGV: dono. Apaga a reserva apenas se ela ainda estiver com o dono.
+var releaseClaimScript = redis.NewScript(`
+local claim = redis.call("HMGET", KEYS[1], "status", "owner")
+if claim[1] == "claimed" and claim[2] == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+type claimRedisRepository struct {
+	db *redis.Client
+	ns Namespace
+}
+
+func NewClaimRepository(db *redis.Client, ns Namespace) core.ClaimRepositoryInterface {
+	return &claimRedisRepository{db: db, ns: ns}
+}
+
+func (r *claimRedisRepository) ClaimPayment(ctx context.Context, correlationId, owner string, ttl time.Duration) (*domain.PaymentClaim, error) {
+	reply, err := claimPaymentScript.Run(ctx, r.db,
+		[]string{r.ns.Key(RD_KEY_TX_CLAIM, correlationId), r.ns.Key(RD_KEY_TX_CLAIM_DUPLICATES)},
+		owner, ttl.Milliseconds(),
+	).Slice()
+	if err != nil {
+		return nil, err
+	}
+	if len(reply) != 3 {
+		return nil, fmt.Errorf("resposta inesperada do script de reserva: %v", reply)
+	}
+
+	acquired, _ := reply[0].(int64)
+	status, _ := reply[1].(string)
+	current, _ := reply[2].(string)
+	return &domain.PaymentClaim{
+		CorrelationId: correlationId,
+		Status:        domain.ClaimStatus(status),
+		Owner:         current,
+		Acquired:      acquired == 1,
+	}, nil
+}
+
+func (r *claimRedisRepository) CompleteClaim(ctx context.Context, correlationId, owner string, ttl time.Duration) error {
+	key := r.ns.Key(RD_KEY_TX_CLAIM, correlationId)
+
+	pipeline := r.db.TxPipeline()
+	pipeline.HSet(ctx, key, "status", string(domain.CLAIM_STATUS_COMPLETED), "owner", owner)
+	if ttl > 0 {
+		pipeline.PExpire(ctx, key, ttl)
+	} else {
+		pipeline.Persist(ctx, key)
+	}
+	_, err := pipeline.Exec(ctx)
+	return err
+}
+
+func (r *claimRedisRepository) ReleaseClaim(ctx context.Context, correlationId, owner string) error {
+	return releaseClaimScript.Run(ctx, r.db, []string{r.ns.Key(RD_KEY_TX_CLAIM, correlationId)}, owner).Err()
+}
+
+func (r *claimRedisRepository) GetClaimStats(ctx context.Context) (domain.ClaimStats, error) {
+	values, err := r.db.HMGet(ctx, r.ns.Key(RD_KEY_TX_CLAIM_DUPLICATES), string(domain.CLAIM_STATUS_CLAIMED), string(domain.CLAIM_STATUS_COMPLETED)).Result()
+	if err != nil {
+		return domain.ClaimStats{}, err
+	}
+
+	var stats domain.ClaimStats
+	for i, count := range []*int64{&stats.Claimed, &stats.Completed} {
+		if value, ok := values[i].(string); ok {
+			if *count, err = strconv.ParseInt(value, 10, 64); err != nil {
+				return domain.ClaimStats{}, err
+			}
+		}
+	}
+	return stats, nil
+}
+
+func (r *claimRedisRepository) ResetClaims(ctx context.Context) error {
+	if _, err := r.ns.Unlink(ctx, r.db, RD_PATTERN_TX_CLAIMS); err != nil {
+		return err
+	}
+	return r.db.Unlink(ctx, r.ns.Key(RD_KEY_TX_CLAIM_DUPLICATES)).Err()
+}
@@ -0,0 +1,12 @@
+package redis_test
+
+import (
+	"testing"
+
+	"github.com/nicolasmmb/go-rinha-backend-2025/internal/repository/redis"
+	"github.com/nicolasmmb/go-rinha-backend-2025/internal/repository/repotest"
+)
+
+func TestClaimRepository(t *testing.T) {
+	repotest.TestClaimRepository(t, redis.NewClaimRepository(newTestClient(t), TEST_NAMESPACE))
+}
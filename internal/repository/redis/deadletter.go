@@ -0,0 +1,128 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+
+	"github.com/nicolasmmb/go-rinha-backend-2025/internal/core"
+	"github.com/nicolasmmb/go-rinha-backend-2025/internal/domain"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	RD_KEY_TX_DLQ_PAYLOAD = "tx:dlq:payload"
+	RD_KEY_TX_DLQ_INDEX   = "tx:dlq:index"
+)
+
+type deadLetterRedisRepository struct {
+	db *redis.Client
+	ns Namespace
+}
+
+func NewDeadLetterRepository(db *redis.Client, ns Namespace) core.DeadLetterRepositoryInterface {
+	return &deadLetterRedisRepository{db: db, ns: ns}
+}
+
+func (r *deadLetterRedisRepository) SaveDeadLetter(ctx context.Context, deadLetter *domain.DeadLetter) error {
+	payload, err := json.Marshal(deadLetter)
+	if err != nil {
+		return err
+	}
+
+	pipeline := r.db.TxPipeline()
+	pipeline.HSet(ctx, r.ns.Key(RD_KEY_TX_DLQ_PAYLOAD), deadLetter.Payment.CorrelationId, payload)
+	pipeline.ZAdd(ctx, r.ns.Key(RD_KEY_TX_DLQ_INDEX), redis.Z{
+		Score:  float64(deadLetter.FirstFailedAt.UnixMilli()),
+		Member: deadLetter.Payment.CorrelationId,
+	})
+
+	if _, err := pipeline.Exec(ctx); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (r *deadLetterRedisRepository) GetDeadLetter(ctx context.Context, correlationId string) (*domain.DeadLetter, error) {
+	payload, err := r.db.HGet(ctx, r.ns.Key(RD_KEY_TX_DLQ_PAYLOAD), correlationId).Bytes()
+	if err == redis.Nil {
+		return nil, domain.ErrDeadLetterNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	deadLetter := &domain.DeadLetter{}
+	if err := json.Unmarshal(payload, deadLetter); err != nil {
+		return nil, err
+	}
+
+	return deadLetter, nil
+}
+
+func (r *deadLetterRedisRepository) ListDeadLetters(ctx context.Context, offset, limit int) ([]domain.DeadLetter, int64, error) {
+	total, err := r.db.ZCard(ctx, r.ns.Key(RD_KEY_TX_DLQ_INDEX)).Result()
+	if err != nil {
+		return nil, 0, err
+	}
+	// Sem esse corte, limit=0 viraria ZRange(offset, offset-1) e, com offset 0, o índice inteiro.
+	if limit <= 0 {
+		return []domain.DeadLetter{}, total, nil
+	}
+
+	ids, err := r.db.ZRange(ctx, r.ns.Key(RD_KEY_TX_DLQ_INDEX), int64(offset), int64(offset+limit-1)).Result()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if len(ids) == 0 {
+		return []domain.DeadLetter{}, total, nil
+	}
+
+	values, err := r.db.HMGet(ctx, r.ns.Key(RD_KEY_TX_DLQ_PAYLOAD), ids...).Result()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	deadLetters := make([]domain.DeadLetter, 0, len(values))
+	for i, value := range values {
+		valueStr, ok := value.(string)
+		if !ok {
+			continue
+		}
+
+		var deadLetter domain.DeadLetter
+		if err := json.Unmarshal([]byte(valueStr), &deadLetter); err != nil {
+			slog.Warn("entrada inválida na dead-letter", "correlationId", ids[i], "error", err.Error())
+			continue
+		}
+		deadLetters = append(deadLetters, deadLetter)
+	}
+
+	return deadLetters, total, nil
+}
+
+func (r *deadLetterRedisRepository) DeleteDeadLetter(ctx context.Context, correlationId string) error {
+	pipeline := r.db.TxPipeline()
+	pipeline.HDel(ctx, r.ns.Key(RD_KEY_TX_DLQ_PAYLOAD), correlationId)
+	pipeline.ZRem(ctx, r.ns.Key(RD_KEY_TX_DLQ_INDEX), correlationId)
+
+	if _, err := pipeline.Exec(ctx); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (r *deadLetterRedisRepository) PurgeDeadLetters(ctx context.Context) (int64, error) {
+	pipeline := r.db.TxPipeline()
+	total := pipeline.ZCard(ctx, r.ns.Key(RD_KEY_TX_DLQ_INDEX))
+	pipeline.Unlink(ctx, r.ns.Key(RD_KEY_TX_DLQ_PAYLOAD), r.ns.Key(RD_KEY_TX_DLQ_INDEX))
+
+	if _, err := pipeline.Exec(ctx); err != nil {
+		return 0, err
+	}
+
+	return total.Val(), nil
+}
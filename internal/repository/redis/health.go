@@ -0,0 +1,94 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+
+	"github.com/nicolasmmb/go-rinha-backend-2025/internal/core"
+	"github.com/nicolasmmb/go-rinha-backend-2025/internal/domain"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	RD_KEY_HEALTH_SNAPSHOT     = "health:snapshot"
+	RD_CHANNEL_HEALTH_SNAPSHOT = "health:updates"
+)
+
+type healthRedisRepository struct {
+	db *redis.Client
+	ns Namespace
+}
+
+func NewHealthRepository(db *redis.Client, ns Namespace) core.HealthRepositoryInterface {
+	return &healthRedisRepository{db: db, ns: ns}
+}
+
+func (r *healthRedisRepository) SaveHealth(ctx context.Context, statuses map[string]domain.ProcessorHealth) error {
+	payload, err := json.Marshal(statuses)
+	if err != nil {
+		return err
+	}
+
+	pipeline := r.db.Pipeline()
+	pipeline.Set(ctx, r.ns.Key(RD_KEY_HEALTH_SNAPSHOT), payload, 0)
+	pipeline.Publish(ctx, r.ns.Key(RD_CHANNEL_HEALTH_SNAPSHOT), payload)
+
+	if _, err := pipeline.Exec(ctx); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (r *healthRedisRepository) GetHealth(ctx context.Context) (map[string]domain.ProcessorHealth, error) {
+	payload, err := r.db.Get(ctx, r.ns.Key(RD_KEY_HEALTH_SNAPSHOT)).Bytes()
+	if err == redis.Nil {
+		return map[string]domain.ProcessorHealth{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := map[string]domain.ProcessorHealth{}
+	if err := json.Unmarshal(payload, &statuses); err != nil {
+		return nil, err
+	}
+
+	return statuses, nil
+}
+
+func (r *healthRedisRepository) SubscribeHealth(ctx context.Context) <-chan map[string]domain.ProcessorHealth {
+	updates := make(chan map[string]domain.ProcessorHealth, 1)
+
+	go func() {
+		defer close(updates)
+
+		sub := r.db.Subscribe(ctx, r.ns.Key(RD_CHANNEL_HEALTH_SNAPSHOT))
+		defer sub.Close()
+
+		messages := sub.Channel()
+		for {
+			var msg *redis.Message
+			select {
+			case <-ctx.Done():
+				return
+			case msg = <-messages:
+			}
+
+			statuses := map[string]domain.ProcessorHealth{}
+			if err := json.Unmarshal([]byte(msg.Payload), &statuses); err != nil {
+				slog.Warn("snapshot de health inválido recebido", "error", err.Error())
+				continue
+			}
+
+			select {
+			case updates <- statuses:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return updates
+}
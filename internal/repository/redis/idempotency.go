@@ -0,0 +1,76 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/nicolasmmb/go-rinha-backend-2025/internal/core"
+	"github.com/nicolasmmb/go-rinha-backend-2025/internal/domain"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// Registro de cada Idempotency-Key, em JSON, que expira ao fim da janela.
+	RD_KEY_TX_IDEMPOTENCY = "tx:idempotency:%s"
+
+	RD_PATTERN_TX_IDEMPOTENCY = "tx:idempotency:*"
+)
+
+// KEYS: registro. ARGV: reserva em JSON, TTL em ms.
+// Retorna o registro existente, ou false quando grava a reserva.
+var reserveIdempotencyKeyScript = redis.NewScript(`
+local current = redis.call("GET", KEYS[1])
+if current then
+	return current
+end
+redis.call("SET", KEYS[1], ARGV[1], "PX", ARGV[2])
+return false
+`)
+
+type idempotencyRedisRepository struct {
+	db *redis.Client
+	ns Namespace
+}
+
+func NewIdempotencyRepository(db *redis.Client, ns Namespace) core.IdempotencyRepositoryInterface {
+	return &idempotencyRedisRepository{db: db, ns: ns}
+}
+
+func (r *idempotencyRedisRepository) ReserveIdempotencyKey(ctx context.Context, key, requestHash string, ttl time.Duration) (*domain.IdempotencyRecord, error) {
+	reservation, err := json.Marshal(domain.IdempotencyRecord{RequestHash: requestHash})
+	if err != nil {
+		return nil, err
+	}
+
+	current, err := reserveIdempotencyKeyScript.Run(ctx, r.db, []string{r.ns.Key(RD_KEY_TX_IDEMPOTENCY, key)}, reservation, ttl.Milliseconds()).Text()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	record := &domain.IdempotencyRecord{}
+	if err := json.Unmarshal([]byte(current), record); err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+func (r *idempotencyRedisRepository) SaveIdempotentResponse(ctx context.Context, key string, record *domain.IdempotencyRecord, ttl time.Duration) error {
+	payload, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	return r.db.Set(ctx, r.ns.Key(RD_KEY_TX_IDEMPOTENCY, key), payload, ttl).Err()
+}
+
+func (r *idempotencyRedisRepository) ReleaseIdempotencyKey(ctx context.Context, key string) error {
+	return r.db.Del(ctx, r.ns.Key(RD_KEY_TX_IDEMPOTENCY, key)).Err()
+}
+
+func (r *idempotencyRedisRepository) ResetIdempotencyKeys(ctx context.Context) error {
+	_, err := r.ns.Unlink(ctx, r.db, RD_PATTERN_TX_IDEMPOTENCY)
+	return err
+}
@@ -0,0 +1,635 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/nicolasmmb/go-rinha-backend-2025/internal/domain"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// Presente quando as chaves gravadas sem prefixo, antes do namespace, já foram movidas para ele.
+	RD_KEY_MIGRATION_NAMESPACE      = "tx:migrations:namespace"
+	RD_KEY_MIGRATION_NAMESPACE_LOCK = "tx:migrations:namespace:lock"
+
+	// Presente quando os valores em float já foram convertidos para centavos.
+	RD_KEY_MIGRATION_MONEY_CENTS = "tx:migrations:money-cents"
+	// Hashes já convertidos, para que uma migração interrompida não converta nenhum duas vezes.
+	RD_KEY_MIGRATION_MONEY_CENTS_DONE = "tx:migrations:money-cents:done"
+	// Trava que impede duas instâncias de migrarem ao mesmo tempo.
+	RD_KEY_MIGRATION_MONEY_CENTS_LOCK = "tx:migrations:money-cents:lock"
+
+	// Presente quando o timeline e o payload já foram divididos em shards de tempo.
+	RD_KEY_MIGRATION_TIME_SHARDS      = "tx:migrations:time-shards"
+	RD_KEY_MIGRATION_TIME_SHARDS_LOCK = "tx:migrations:time-shards:lock"
+
+	// Presente quando os contadores dos buckets do tamanho de bucket e de shard da chave (em ms) foram reconstruídos a partir do timeline.
+	RD_KEY_MIGRATION_BUCKETS      = "tx:migrations:buckets:%d:%d"
+	RD_KEY_MIGRATION_BUCKETS_LOCK = "tx:migrations:buckets:%d:%d:lock"
+	RD_PATTERN_MIGRATION_BUCKETS  = "tx:migrations:buckets:*"
+	// Tentativas de reconstruir um shard que recebe pagamentos durante a reconstrução.
+	RD_BUCKETS_REBUILD_ATTEMPTS = 5
+
+	// Chaves de cada processador antes da divisão em shards.
+	RD_KEY_TX_PAYMENTS_PAYLOAD_UNSHARDED  = "tx:payload:%s"
+	RD_KEY_TX_PAYMENTS_TIMELINE_UNSHARDED = "tx:timeline:%s"
+
+	RD_MIGRATION_LOCK_TTL   = 30 * time.Second
+	RD_MIGRATION_POLL       = 200 * time.Millisecond
+	RD_MIGRATION_SCAN_COUNT = 500
+	RD_MIGRATION_TMP_SUFFIX = ":migrating"
+)
+
+// Hashes cujos valores eram gravados em float antes de domain.Money.
+// Nessa época o payload ainda não era dividido em shards.
+var moneyHashFormats = []string{
+	RD_KEY_TX_PAYMENTS_PAYLOAD_UNSHARDED,
+	RD_KEY_TX_ROUTING_AMOUNT,
+}
+
+// Renova a trava de migração apenas se ela ainda pertence a esta instância.
+var renewMigrationLockScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+// Libera a trava de migração apenas se ela ainda pertence a esta instância.
+var releaseMigrationLockScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// Grava o marcador da migração e apaga as chaves auxiliares (KEYS[3..]) apenas se a trava ainda pertence a esta instância.
+var finishMigrationScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) ~= ARGV[1] then
+	return 0
+end
+redis.call("SET", KEYS[2], ARGV[2])
+for i = 3, #KEYS do
+	redis.call("DEL", KEYS[i])
+end
+return 1
+`)
+
+// Troca o hash temporário (KEYS[2]) pelo original (KEYS[3]) e registra a conversão em KEYS[4],
+// apenas se a trava ainda pertence a esta instância.
+var renameMigratedHashScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) ~= ARGV[1] then
+	return 0
+end
+if ARGV[2] == "1" then
+	redis.call("RENAME", KEYS[2], KEYS[3])
+end
+redis.call("SADD", KEYS[4], KEYS[3])
+return 1
+`)
+
+// Move a chave sem prefixo (KEYS[2]) para o namespace (KEYS[3]), apenas se a trava ainda pertence a esta instância.
+// Retorna -1, sem mover nada, quando a chave de destino já existe.
+var moveToNamespaceScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) ~= ARGV[1] then
+	return 0
+end
+if redis.call("EXISTS", KEYS[3]) == 1 then
+	return -1
+end
+if redis.call("EXISTS", KEYS[2]) == 1 then
+	redis.call("RENAME", KEYS[2], KEYS[3])
+end
+return 1
+`)
+
+// A trava expirou e outra instância assumiu a migração.
+var errMigrationLockLost = errors.New("trava de migração perdida para outra instância")
+
+// migrationLock é a trava de uma migração, identificada por um token único da instância que a obteve.
+type migrationLock struct {
+	db    *redis.Client
+	key   string
+	token string
+}
+
+// renew renova a trava a cada terço do TTL até o contexto acabar; se ela for perdida, chama lost.
+func (l *migrationLock) renew(ctx context.Context, lost context.CancelFunc) {
+	ticker := time.NewTicker(RD_MIGRATION_LOCK_TTL / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		renewed, err := renewMigrationLockScript.Run(ctx, l.db, []string{l.key}, l.token, RD_MIGRATION_LOCK_TTL.Milliseconds()).Int()
+		if err != nil {
+			// Uma falha isolada não derruba a migração: a trava ainda vale até o TTL acabar.
+			slog.Warn("falha ao renovar a trava de migração", "lock", l.key, "error", err.Error())
+			continue
+		}
+		if renewed == 0 {
+			lost()
+			return
+		}
+	}
+}
+
+func (l *migrationLock) release(ctx context.Context) {
+	if err := releaseMigrationLockScript.Run(ctx, l.db, []string{l.key}, l.token).Err(); err != nil {
+		slog.Warn("falha ao liberar a trava de migração", "lock", l.key, "error", err.Error())
+	}
+}
+
+// Troca os contadores de um shard pelos reconstruídos (KEYS[3] e KEYS[4]), apenas se a trava ainda pertence a esta
+// instância e se o timeline (KEYS[2]) não recebeu pagamentos desde a contagem; nesse caso retorna -1.
+// ARGV: token, tamanho do timeline na contagem, expiração em ms (0 para não expirar).
+var replaceBucketsScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) ~= ARGV[1] then
+	return 0
+end
+if redis.call("ZCARD", KEYS[2]) ~= tonumber(ARGV[2]) then
+	return -1
+end
+if redis.call("EXISTS", KEYS[3]) == 1 then
+	redis.call("RENAME", KEYS[3], KEYS[5])
+	redis.call("RENAME", KEYS[4], KEYS[6])
+	if ARGV[3] ~= "0" then
+		redis.call("PEXPIREAT", KEYS[5], ARGV[3])
+		redis.call("PEXPIREAT", KEYS[6], ARGV[3])
+	end
+else
+	redis.call("DEL", KEYS[5], KEYS[6])
+end
+return 1
+`)
+
+// fenced converte o resultado de um script protegido pela trava: 0 significa que ela já é de outra instância.
+func fenced(result int, err error) error {
+	if err != nil {
+		return err
+	}
+	if result == 0 {
+		return errMigrationLockLost
+	}
+	return nil
+}
+
+// migrateOnce executa a migração uma única vez por base. Apenas uma instância migra; as demais esperam
+// a migração terminar. A trava é renovada enquanto migrate roda, e o marcador só é gravado, junto com a remoção
+// das chaves auxiliares que migrate retorna, se a trava ainda for desta instância. Se ela for perdida
+// (uma pausa maior que o TTL, por exemplo), a instância volta a esperar a outra terminar.
+func migrateOnce(ctx context.Context, db *redis.Client, marker, lockKey string, migrate func(ctx context.Context, lock *migrationLock) ([]string, error)) error {
+	for {
+		done, err := db.Exists(ctx, marker).Result()
+		if err != nil {
+			return err
+		}
+		if done > 0 {
+			return nil
+		}
+
+		lock := &migrationLock{db: db, key: lockKey, token: uuid.NewString()}
+		locked, err := db.SetNX(ctx, lock.key, lock.token, RD_MIGRATION_LOCK_TTL).Result()
+		if err != nil {
+			return err
+		}
+		if locked {
+			err := runMigration(ctx, marker, lock, migrate)
+			if !errors.Is(err, errMigrationLockLost) {
+				return err
+			}
+			slog.Warn("trava de migração perdida, aguardando a outra instância", "lock", lock.key)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(RD_MIGRATION_POLL):
+		}
+	}
+}
+
+func runMigration(ctx context.Context, marker string, lock *migrationLock, migrate func(ctx context.Context, lock *migrationLock) ([]string, error)) error {
+	migrateCtx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	defer lock.release(context.WithoutCancel(ctx))
+
+	go lock.renew(migrateCtx, func() { cancel(errMigrationLockLost) })
+
+	cleanup, err := migrate(migrateCtx, lock)
+	if cause := context.Cause(migrateCtx); errors.Is(cause, errMigrationLockLost) {
+		return cause
+	}
+	if err != nil {
+		return err
+	}
+
+	keys := append([]string{lock.key, marker}, cleanup...)
+	return fenced(finishMigrationScript.Run(ctx, lock.db, keys, lock.token, time.Now().Format(time.RFC3339)).Int())
+}
+
+// Chaves gravadas sem prefixo antes do namespace: o timeline e o payload de cada processador.
+var unprefixedFormats = []string{
+	RD_KEY_TX_PAYMENTS_TIMELINE_UNSHARDED,
+	RD_KEY_TX_PAYMENTS_PAYLOAD_UNSHARDED,
+}
+
+// MigrateToNamespace move, uma única vez, o timeline e o payload gravados sem prefixo, antes do namespace,
+// para dentro dele, renomeando cada chave. Precisa rodar antes das outras migrações, que só enxergam o namespace.
+// Se várias aplicações dividem o banco, a primeira a subir fica com as chaves antigas.
+func MigrateToNamespace(ctx context.Context, db *redis.Client, ns Namespace) error {
+	return migrateOnce(ctx, db, ns.Key(RD_KEY_MIGRATION_NAMESPACE), ns.Key(RD_KEY_MIGRATION_NAMESPACE_LOCK), func(ctx context.Context, lock *migrationLock) ([]string, error) {
+		moved := 0
+		for _, format := range unprefixedFormats {
+			prefix := fmt.Sprintf(format, "")
+
+			// Sem namespace o padrão é o próprio formato; as chaves do namespace nunca começam por ele.
+			var keys []string
+			iter := db.Scan(ctx, 0, fmt.Sprintf(format, "*"), RD_MIGRATION_SCAN_COUNT).Iterator()
+			for iter.Next(ctx) {
+				if processor := strings.TrimPrefix(iter.Val(), prefix); !strings.Contains(processor, ":") {
+					keys = append(keys, iter.Val())
+				}
+			}
+			if err := iter.Err(); err != nil {
+				return nil, err
+			}
+
+			for _, key := range keys {
+				result, err := moveToNamespaceScript.Run(ctx, db, []string{lock.key, key, string(ns) + key}, lock.token).Int()
+				if err == nil && result == -1 {
+					return nil, fmt.Errorf("%s já existe no namespace, a chave %s não foi movida", string(ns)+key, key)
+				}
+				if err := fenced(result, err); err != nil {
+					return nil, err
+				}
+				moved++
+			}
+		}
+
+		if moved > 0 {
+			slog.Info("chaves sem prefixo movidas para o namespace", "namespace", string(ns), "keys", moved)
+		}
+		return nil, nil
+	})
+}
+
+// MigrateMoneyToCents converte, uma única vez, os valores em float dos hashes de pagamentos
+// para centavos inteiros. As outras instâncias esperam a migração terminar,
+// já que gravar centavos antes disso faria esses valores serem convertidos de novo.
+func MigrateMoneyToCents(ctx context.Context, db *redis.Client, ns Namespace) error {
+	return migrateOnce(ctx, db, ns.Key(RD_KEY_MIGRATION_MONEY_CENTS), ns.Key(RD_KEY_MIGRATION_MONEY_CENTS_LOCK), func(ctx context.Context, lock *migrationLock) ([]string, error) {
+		migrated, err := migrateMoneyHashes(ctx, db, ns, lock)
+		if err != nil {
+			return nil, err
+		}
+
+		slog.Info("valores monetários migrados para centavos", "hashes", migrated)
+		return []string{ns.Key(RD_KEY_MIGRATION_MONEY_CENTS_DONE)}, nil
+	})
+}
+
+func migrateMoneyHashes(ctx context.Context, db *redis.Client, ns Namespace, lock *migrationLock) (int, error) {
+	migrated := 0
+	for _, format := range moneyHashFormats {
+		iter := db.Scan(ctx, 0, ns.pattern(fmt.Sprintf(format, "*")), RD_MIGRATION_SCAN_COUNT).Iterator()
+		for iter.Next(ctx) {
+			key := iter.Val()
+			if strings.HasSuffix(key, RD_MIGRATION_TMP_SUFFIX) {
+				continue
+			}
+
+			converted, err := db.SIsMember(ctx, ns.Key(RD_KEY_MIGRATION_MONEY_CENTS_DONE), key).Result()
+			if err != nil {
+				return migrated, err
+			}
+			if converted {
+				continue
+			}
+
+			if err := migrateMoneyHash(ctx, db, ns, lock, key); err != nil {
+				return migrated, fmt.Errorf("falha ao migrar %s: %w", key, err)
+			}
+			migrated++
+		}
+		if err := iter.Err(); err != nil {
+			return migrated, err
+		}
+	}
+	return migrated, nil
+}
+
+// migrateMoneyHash escreve os valores convertidos em um hash temporário e o troca pelo original
+// atomicamente, junto com o registro de que o hash já foi convertido. A troca só acontece se a trava ainda
+// for desta instância, para que uma instância atrasada não troque um hash temporário incompleto.
+func migrateMoneyHash(ctx context.Context, db *redis.Client, ns Namespace, lock *migrationLock, key string) error {
+	tmp := key + RD_MIGRATION_TMP_SUFFIX
+	if err := db.Del(ctx, tmp).Err(); err != nil {
+		return err
+	}
+
+	var cursor uint64
+	written := false
+	for {
+		fields, next, err := db.HScan(ctx, key, cursor, "", RD_MIGRATION_SCAN_COUNT).Result()
+		if err != nil {
+			return err
+		}
+
+		values := make([]any, 0, len(fields))
+		for i := 0; i+1 < len(fields); i += 2 {
+			amount, err := strconv.ParseFloat(fields[i+1], 64)
+			if err != nil {
+				return fmt.Errorf("valor inválido para %s: %w", fields[i], err)
+			}
+			values = append(values, fields[i], domain.MoneyFromFloat(amount).Cents())
+		}
+		if len(values) > 0 {
+			if err := db.HSet(ctx, tmp, values...).Err(); err != nil {
+				return err
+			}
+			written = true
+		}
+
+		cursor = next
+		if cursor == 0 {
+			break
+		}
+	}
+
+	rename := "0"
+	if written {
+		rename = "1"
+	}
+	keys := []string{lock.key, tmp, key, ns.Key(RD_KEY_MIGRATION_MONEY_CENTS_DONE)}
+	return fenced(renameMigratedHashScript.Run(ctx, db, keys, lock.token, rename).Int())
+}
+
+// MigrateToTimeShards move, uma única vez, o timeline e o payload gravados antes da divisão em shards
+// para os shards da configuração informada, aplicando a retenção, e apaga as chaves antigas.
+// Cada pagamento movido é marcado como gravado no seu registro, para que reenvios continuem sendo duplicatas;
+// os contadores dos buckets são montados depois por RebuildBuckets.
+func MigrateToTimeShards(ctx context.Context, db *redis.Client, ns Namespace, opts PaymentsRepositoryOptions) error {
+	r, err := newPaymentsRepository(db, ns, opts)
+	if err != nil {
+		return err
+	}
+
+	return migrateOnce(ctx, db, ns.Key(RD_KEY_MIGRATION_TIME_SHARDS), ns.Key(RD_KEY_MIGRATION_TIME_SHARDS_LOCK), func(ctx context.Context, _ *migrationLock) ([]string, error) {
+		prefix := ns.Key(RD_KEY_TX_PAYMENTS_TIMELINE_UNSHARDED, "")
+
+		var processors []string
+		iter := db.Scan(ctx, 0, ns.pattern(fmt.Sprintf(RD_KEY_TX_PAYMENTS_TIMELINE_UNSHARDED, "*")), RD_MIGRATION_SCAN_COUNT).Iterator()
+		for iter.Next(ctx) {
+			// Os timelines em shards casam com o mesmo padrão, mas têm o tamanho e o shard depois do processador.
+			if processor := strings.TrimPrefix(iter.Val(), prefix); !strings.Contains(processor, ":") {
+				processors = append(processors, processor)
+			}
+		}
+		if err := iter.Err(); err != nil {
+			return nil, err
+		}
+
+		migrated := 0
+		for _, processor := range processors {
+			n, err := r.migrateUnshardedTimeline(ctx, processor)
+			if err != nil {
+				return nil, fmt.Errorf("falha ao migrar o timeline de %s: %w", processor, err)
+			}
+			if err := db.Unlink(ctx,
+				ns.Key(RD_KEY_TX_PAYMENTS_TIMELINE_UNSHARDED, processor),
+				ns.Key(RD_KEY_TX_PAYMENTS_PAYLOAD_UNSHARDED, processor),
+			).Err(); err != nil {
+				return nil, err
+			}
+			migrated += n
+		}
+
+		slog.Info("pagamentos divididos em shards de tempo", "processors", len(processors), "payments", migrated)
+		return nil, nil
+	})
+}
+
+// migrateUnshardedTimeline copia cada pagamento do timeline antigo para o seu shard e o marca como gravado no registro.
+// As cópias são idempotentes, então uma migração interrompida pode ser repetida.
+func (r *paymentsRedisRepository) migrateUnshardedTimeline(ctx context.Context, processor string) (int, error) {
+	timeline := r.ns.Key(RD_KEY_TX_PAYMENTS_TIMELINE_UNSHARDED, processor)
+	payload := r.ns.Key(RD_KEY_TX_PAYMENTS_PAYLOAD_UNSHARDED, processor)
+	saved := fmt.Sprintf(RD_FIELD_RECORD_SAVED, processor)
+
+	migrated := 0
+	for start := int64(0); ; start += RD_MIGRATION_SCAN_COUNT {
+		members, err := r.db.ZRangeWithScores(ctx, timeline, start, start+RD_MIGRATION_SCAN_COUNT-1).Result()
+		if err != nil {
+			return migrated, err
+		}
+		if len(members) == 0 {
+			return migrated, nil
+		}
+
+		ids := make([]string, len(members))
+		for i, member := range members {
+			ids[i] = member.Member.(string)
+		}
+		values, err := r.db.HMGet(ctx, payload, ids...).Result()
+		if err != nil {
+			return migrated, err
+		}
+
+		pipeline := r.db.Pipeline()
+		shards := map[int64]bool{}
+		for i, member := range members {
+			cents, ok := values[i].(string)
+			if !ok {
+				continue
+			}
+			shard := r.bucketShard(r.buckets.bucketOf(member.Score))
+			pipeline.ZAdd(ctx, r.timelineKey(processor, shard), member)
+			pipeline.HSet(ctx, r.payloadKey(processor, shard), ids[i], cents)
+			pipeline.HSet(ctx, r.recordKey(ids[i]), saved, shard)
+			if at := r.expireAt(shard); at != 0 {
+				pipeline.PExpireAt(ctx, r.recordKey(ids[i]), time.UnixMilli(at))
+			}
+			shards[shard] = true
+			migrated++
+		}
+		for shard := range shards {
+			r.indexShard(ctx, pipeline, processor, shard, r.timelineKey(processor, shard), r.payloadKey(processor, shard))
+		}
+		if _, err := pipeline.Exec(ctx); err != nil {
+			return migrated, err
+		}
+
+		if len(members) < RD_MIGRATION_SCAN_COUNT {
+			return migrated, nil
+		}
+	}
+}
+
+// indexShard registra o shard no índice do processador e aplica a retenção às chaves dele, como faz o savePaymentScript.
+func (r *paymentsRedisRepository) indexShard(ctx context.Context, pipeline redis.Pipeliner, processor string, shard int64, keys ...string) {
+	pipeline.ZAdd(ctx, r.shardIndexKey(processor), redis.Z{Score: float64(shard), Member: shard})
+	if at := r.expireAt(shard); at != 0 {
+		for _, key := range keys {
+			pipeline.PExpireAt(ctx, key, time.UnixMilli(at))
+		}
+	}
+}
+
+// RebuildBuckets reconstrói, uma única vez por tamanho de bucket e de shard, os contadores dos buckets a partir
+// do timeline e do payload. Os contadores só existem para a configuração com que cada pagamento foi gravado:
+// sem a reconstrução, mudar SUMMARY_BUCKET_MS (ou ligar o modo buckets numa base gravada antes dos contadores)
+// faria o resumo por buckets contar menos pagamentos, sem nenhum erro.
+func RebuildBuckets(ctx context.Context, db *redis.Client, ns Namespace, opts PaymentsRepositoryOptions) error {
+	r, err := newPaymentsRepository(db, ns, opts)
+	if err != nil {
+		return err
+	}
+
+	marker := ns.Key(RD_KEY_MIGRATION_BUCKETS, r.buckets.sizeMs(), r.shards.sizeMs())
+	lock := ns.Key(RD_KEY_MIGRATION_BUCKETS_LOCK, r.buckets.sizeMs(), r.shards.sizeMs())
+	return migrateOnce(ctx, db, marker, lock, func(ctx context.Context, lock *migrationLock) ([]string, error) {
+		processors, err := r.indexedProcessors(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		rebuilt := 0
+		for _, processor := range processors {
+			shards, err := r.indexedShards(ctx, processor)
+			if err != nil {
+				return nil, err
+			}
+			for _, shard := range shards {
+				if err := r.rebuildShardBuckets(ctx, lock, processor, shard); err != nil {
+					return nil, fmt.Errorf("falha ao reconstruir os buckets de %s no shard %d: %w", processor, shard, err)
+				}
+			}
+			rebuilt += len(shards)
+		}
+
+		// Enquanto esta configuração grava, os contadores das outras ficam para trás:
+		// os marcadores delas são apagados para que voltar a uma delas também reconstrua os contadores.
+		var stale []string
+		iter := db.Scan(ctx, 0, ns.pattern(RD_PATTERN_MIGRATION_BUCKETS), RD_MIGRATION_SCAN_COUNT).Iterator()
+		for iter.Next(ctx) {
+			if key := iter.Val(); key != marker && !strings.HasSuffix(key, ":lock") {
+				stale = append(stale, key)
+			}
+		}
+		if err := iter.Err(); err != nil {
+			return nil, err
+		}
+
+		slog.Info("contadores dos buckets reconstruídos", "bucketMs", r.buckets.sizeMs(), "shardMs", r.shards.sizeMs(), "processors", len(processors), "shards", rebuilt)
+		return stale, nil
+	})
+}
+
+// rebuildShardBuckets conta os pagamentos do shard por bucket em hashes temporários e os troca pelos contadores.
+// Se o shard receber pagamentos durante a contagem, ela é refeita.
+func (r *paymentsRedisRepository) rebuildShardBuckets(ctx context.Context, lock *migrationLock, processor string, shard int64) error {
+	timeline, payload := r.timelineKey(processor, shard), r.payloadKey(processor, shard)
+	countKey, amountKey := r.bucketCountKey(processor, shard), r.bucketAmountKey(processor, shard)
+	tmpCount, tmpAmount := countKey+RD_MIGRATION_TMP_SUFFIX, amountKey+RD_MIGRATION_TMP_SUFFIX
+
+	for range RD_BUCKETS_REBUILD_ATTEMPTS {
+		size, err := r.db.ZCard(ctx, timeline).Result()
+		if err != nil {
+			return err
+		}
+
+		counts := map[int64]int64{}
+		amounts := map[int64]int64{}
+		for start := int64(0); start < size; start += RD_MIGRATION_SCAN_COUNT {
+			members, err := r.db.ZRangeWithScores(ctx, timeline, start, start+RD_MIGRATION_SCAN_COUNT-1).Result()
+			if err != nil {
+				return err
+			}
+			ids := make([]string, len(members))
+			for i, member := range members {
+				ids[i] = member.Member.(string)
+			}
+			if len(ids) == 0 {
+				break
+			}
+			values, err := r.db.HMGet(ctx, payload, ids...).Result()
+			if err != nil {
+				return err
+			}
+			for i, member := range members {
+				value, ok := values[i].(string)
+				if !ok {
+					continue
+				}
+				cents, err := domain.ParseMoneyCents(value)
+				if err != nil {
+					return fmt.Errorf("valor inválido para %s: %w", ids[i], err)
+				}
+				bucket := r.buckets.bucketOf(member.Score)
+				counts[bucket]++
+				amounts[bucket] += cents.Cents()
+			}
+		}
+
+		pipeline := r.db.Pipeline()
+		pipeline.Del(ctx, tmpCount, tmpAmount)
+		for bucket, count := range counts {
+			field := strconv.FormatInt(bucket, 10)
+			pipeline.HSet(ctx, tmpCount, field, count)
+			pipeline.HSet(ctx, tmpAmount, field, amounts[bucket])
+		}
+		if _, err := pipeline.Exec(ctx); err != nil {
+			return err
+		}
+
+		keys := []string{lock.key, timeline, tmpCount, tmpAmount, countKey, amountKey}
+		replaced, err := replaceBucketsScript.Run(ctx, r.db, keys, lock.token, size, r.expireAt(shard)).Int()
+		if err != nil {
+			return err
+		}
+		if replaced != -1 {
+			return fenced(replaced, nil)
+		}
+	}
+
+	r.db.Del(ctx, tmpCount, tmpAmount)
+	return fmt.Errorf("o shard continuou recebendo pagamentos depois de %d tentativas", RD_BUCKETS_REBUILD_ATTEMPTS)
+}
+
+// indexedProcessors retorna os processadores com índice de shards no tamanho de shard configurado.
+func (r *paymentsRedisRepository) indexedProcessors(ctx context.Context) ([]string, error) {
+	suffix := fmt.Sprintf(":%d", r.shards.sizeMs())
+	prefix := strings.TrimSuffix(r.shardIndexKey(""), suffix)
+
+	var processors []string
+	iter := r.db.Scan(ctx, 0, r.ns.pattern(fmt.Sprintf(RD_KEY_TX_PAYMENTS_SHARDS, "*", r.shards.sizeMs())), RD_MIGRATION_SCAN_COUNT).Iterator()
+	for iter.Next(ctx) {
+		processor := strings.TrimSuffix(strings.TrimPrefix(iter.Val(), prefix), suffix)
+		if !strings.Contains(processor, ":") {
+			processors = append(processors, processor)
+		}
+	}
+	return processors, iter.Err()
+}
+
+// indexedShards retorna, em ordem, os shards do índice do processador.
+func (r *paymentsRedisRepository) indexedShards(ctx context.Context, processor string) ([]int64, error) {
+	members, err := r.db.ZRange(ctx, r.shardIndexKey(processor), 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+	return parseShards(members)
+}
@@ -0,0 +1,74 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Quantidade de chaves pedidas a cada SCAN e apagadas a cada UNLINK durante o reset.
+const RD_RESET_SCAN_COUNT = 1000
+
+// Namespace é o prefixo de todas as chaves e canais da aplicação no Valkey,
+// para que ela possa dividir o banco com outras aplicações e o reset apague apenas o que é dela.
+type Namespace string
+
+// NewNamespace valida o prefixo: sem ele o reset apagaria o banco inteiro.
+func NewNamespace(prefix string) (Namespace, error) {
+	if prefix == "" {
+		return "", fmt.Errorf("o prefixo das chaves do Valkey não pode ser vazio")
+	}
+	return Namespace(prefix), nil
+}
+
+// Key monta a chave a partir de um dos formatos RD_KEY_*.
+func (ns Namespace) Key(format string, args ...any) string {
+	if len(args) == 0 {
+		return string(ns) + format
+	}
+	return string(ns) + fmt.Sprintf(format, args...)
+}
+
+// pattern monta o padrão de SCAN para as chaves do namespace que casam com match.
+// O prefixo é escapado, já que é um texto livre e pode conter caracteres especiais do glob.
+func (ns Namespace) pattern(match string) string {
+	var escaped strings.Builder
+	for _, r := range string(ns) {
+		if strings.ContainsRune(`*?[]\`, r) {
+			escaped.WriteByte('\\')
+		}
+		escaped.WriteRune(r)
+	}
+	return escaped.String() + match
+}
+
+// Unlink apaga as chaves do namespace que casam com match (na sintaxe do SCAN) e retorna quantas foram apagadas.
+// O SCAN percorre o banco em lotes e o UNLINK libera a memória em segundo plano, então o servidor não fica bloqueado.
+func (ns Namespace) Unlink(ctx context.Context, db *redis.Client, match string) (int64, error) {
+	var (
+		cursor  uint64
+		deleted int64
+	)
+
+	for {
+		keys, next, err := db.Scan(ctx, cursor, ns.pattern(match), RD_RESET_SCAN_COUNT).Result()
+		if err != nil {
+			return deleted, err
+		}
+
+		if len(keys) > 0 {
+			n, err := db.Unlink(ctx, keys...).Result()
+			if err != nil {
+				return deleted, err
+			}
+			deleted += n
+		}
+
+		if next == 0 {
+			return deleted, nil
+		}
+		cursor = next
+	}
+}
@@ -3,6 +3,7 @@ package redis
 import (
 	"context"
 	"fmt"
+	"log/slog"
 	"strconv"
 	"time"
 
@@ -11,82 +12,378 @@ import (
 	"github.com/redis/go-redis/v9"
 )
 
+// Os valores dos pagamentos são gravados em centavos inteiros (ver domain.Money).
+// O timeline e o payload de cada processador ficam em shards de tempo (ver shards.go).
 const (
-	RD_KEY_TX_PAYMENTS_PAYLOAD  = "tx:payload:%s"
-	RD_KEY_TX_PAYMENTS_TIMELINE = "tx:timeline:%s"
+	RD_KEY_TX_ROUTING_STRATEGIES = "tx:routing:strategies"
+	RD_KEY_TX_ROUTING_COUNT      = "tx:routing:count:%s"
+	RD_KEY_TX_ROUTING_AMOUNT     = "tx:routing:amount:%s"
+
+	// Todas as chaves de transações, apagadas pelo reset.
+	RD_PATTERN_TX = "tx:*"
+)
+
+const (
+	// Soma cada pagamento da janela.
+	SUMMARY_MODE_EXACT = "exact"
+	// Soma os contadores dos buckets inteiros da janela e apenas os pagamentos das bordas.
+	SUMMARY_MODE_BUCKETS = "buckets"
+	// Soma cada pagamento da janela num script no servidor, que retorna apenas a quantidade e o total.
+	SUMMARY_MODE_SCRIPT = "script"
+
+	// Quantidade de membros do timeline lidos por página no resumo, quando não configurada.
+	RD_SUMMARY_CHUNK_SIZE = 1000
 )
 
+// PaymentsRepositoryOptions configura como os pagamentos são guardados e como o resumo é calculado.
+type PaymentsRepositoryOptions struct {
+	SummaryMode string
+	BucketSize  time.Duration
+	// Quantidade de pagamentos lidos por página ao somar uma janela (zero usa RD_SUMMARY_CHUNK_SIZE).
+	ChunkSize int
+	// Período coberto por cada shard do timeline, do payload e dos buckets; precisa ser múltiplo de BucketSize.
+	ShardSize time.Duration
+	// Tempo que cada shard é mantido depois do seu fim (zero para manter para sempre).
+	Retention time.Duration
+}
+
 type paymentsRedisRepository struct {
-	db *redis.Client
+	db          *redis.Client
+	ns          Namespace
+	summaryMode string
+	buckets     timeBuckets
+	shards      timeBuckets
+	retention   time.Duration
+	chunkSize   int64
 }
 
-func NewPaymentsRepository(db *redis.Client) core.PaymentRepositoryInterface {
-	return &paymentsRedisRepository{db: db}
+func NewPaymentsRepository(db *redis.Client, ns Namespace, opts PaymentsRepositoryOptions) (core.PaymentRepositoryInterface, error) {
+	return newPaymentsRepository(db, ns, opts)
 }
 
-func (r *paymentsRedisRepository) SavePayment(ctx context.Context, payment *domain.Payment) (err error) {
+func newPaymentsRepository(db *redis.Client, ns Namespace, opts PaymentsRepositoryOptions) (*paymentsRedisRepository, error) {
+	switch opts.SummaryMode {
+	case "":
+		opts.SummaryMode = SUMMARY_MODE_EXACT
+	case SUMMARY_MODE_EXACT, SUMMARY_MODE_BUCKETS, SUMMARY_MODE_SCRIPT:
+	default:
+		return nil, fmt.Errorf("modo de resumo desconhecido: %q", opts.SummaryMode)
+	}
 
-	pipeline := r.db.Pipeline()
+	if opts.BucketSize < time.Millisecond || opts.BucketSize%time.Millisecond != 0 {
+		return nil, fmt.Errorf("tamanho de bucket inválido: %s (use um múltiplo de 1ms)", opts.BucketSize)
+	}
 
-	pipeline.ZAdd(
-		ctx,
-		fmt.Sprintf(RD_KEY_TX_PAYMENTS_TIMELINE, payment.Processor),
-		redis.Z{
-			Score:  float64(payment.RequestedAt.UnixNano()),
-			Member: payment.CorrelationId,
-		},
-	)
+	if opts.ShardSize < opts.BucketSize || opts.ShardSize%opts.BucketSize != 0 {
+		return nil, fmt.Errorf("tamanho de shard inválido: %s (use um múltiplo do tamanho do bucket, %s)", opts.ShardSize, opts.BucketSize)
+	}
 
-	pipeline.HSet(
-		ctx,
-		fmt.Sprintf(RD_KEY_TX_PAYMENTS_PAYLOAD, payment.Processor),
-		payment.CorrelationId, payment.Amount,
-	)
+	if opts.Retention < 0 || opts.Retention%time.Millisecond != 0 {
+		return nil, fmt.Errorf("retenção inválida: %s (use um múltiplo de 1ms, ou zero para não expirar)", opts.Retention)
+	}
 
-	if _, err := pipeline.Exec(ctx); err != nil {
-		return err
+	switch {
+	case opts.ChunkSize == 0:
+		opts.ChunkSize = RD_SUMMARY_CHUNK_SIZE
+	case opts.ChunkSize < 0:
+		return nil, fmt.Errorf("tamanho de página do resumo inválido: %d", opts.ChunkSize)
+	case opts.SummaryMode == SUMMARY_MODE_SCRIPT && opts.ChunkSize > RD_SUMMARY_SCRIPT_MAX_CHUNK:
+		return nil, fmt.Errorf("tamanho de página do resumo inválido para o modo script: %d (máximo %d)", opts.ChunkSize, RD_SUMMARY_SCRIPT_MAX_CHUNK)
 	}
 
-	return nil
+	return &paymentsRedisRepository{
+		db:          db,
+		ns:          ns,
+		summaryMode: opts.SummaryMode,
+		buckets:     newTimeBuckets(opts.BucketSize),
+		shards:      newTimeBuckets(opts.ShardSize),
+		retention:   opts.Retention,
+		chunkSize:   int64(opts.ChunkSize),
+	}, nil
 }
 
-func (r *paymentsRedisRepository) GetSummaryByProcessor(ctx context.Context, typeOfProcessor string, from, to time.Time) (*domain.SummaryItem, error) {
+// Grava timeline, payload, buckets e contadores de roteamento numa única operação atômica,
+// ignorando correlationIds já gravados para o processador, e leva o registro do pagamento para succeeded.
+// Retorna {0} para duplicata, {1} para inserção e {1, status atual} quando o registro não pôde ir para succeeded.
+//
+// A duplicata pode chegar com outro requestedAt, e portanto em outro shard, então quem a reconhece é o campo
+// ARGV[12] do registro do pagamento, que expira junto com ele. Com retenção, o índice esquece os shards
+// anteriores a ARGV[11] e as chaves do shard expiram em ARGV[10].
+//
+// KEYS: timeline, payload, bucket count, bucket amount, estratégias, contagem e valor por estratégia, registro,
+// índice de shards.
+// ARGV: correlationId, score, centavos, bucket, processador, estratégia (vazia para não contar),
+// registro em JSON, transição em JSON, shard, expiração em ms (0 para não expirar), shard mais antigo ainda vivo,
+// campo de gravação do registro, retenção do registro em ms, status de origem permitidos para succeeded.
+var savePaymentScript = redis.NewScript(transitionLua + `
+if ARGV[10] ~= "0" then
+	redis.call("ZREMRANGEBYSCORE", KEYS[9], "-inf", "(" .. ARGV[11])
+end
+if redis.call("HEXISTS", KEYS[8], ARGV[12]) == 1 then
+	return {0}
+end
+
+redis.call("ZADD", KEYS[1], ARGV[2], ARGV[1])
+redis.call("HSET", KEYS[2], ARGV[1], ARGV[3])
+redis.call("HINCRBY", KEYS[3], ARGV[4], 1)
+redis.call("HINCRBY", KEYS[4], ARGV[4], ARGV[3])
+redis.call("ZADD", KEYS[9], ARGV[9], ARGV[9])
+if ARGV[10] ~= "0" then
+	for i = 1, 4 do
+		redis.call("PEXPIREAT", KEYS[i], ARGV[10])
+	end
+end
+if ARGV[6] ~= "" then
+	redis.call("SADD", KEYS[5], ARGV[6])
+	redis.call("HINCRBY", KEYS[6], ARGV[5], 1)
+	redis.call("HINCRBY", KEYS[7], ARGV[5], ARGV[3])
+end
+redis.call("HSET", KEYS[8], ARGV[12], ARGV[9])
+if ARGV[13] ~= "0" then
+	redis.call("PEXPIRE", KEYS[8], ARGV[13])
+end
+local rejected = transition(KEYS[8], ARGV[7], ARGV[8], ARGV[13], 14)
+if rejected then
+	return {1, rejected}
+end
+return {1}
+`)
 
-	pIds, err := r.db.ZRangeByScore(ctx,
-		fmt.Sprintf(RD_KEY_TX_PAYMENTS_TIMELINE, typeOfProcessor),
-		&redis.ZRangeBy{
-			Min: strconv.FormatInt(from.UnixNano(), 10),
-			Max: strconv.FormatInt(to.UnixNano(), 10),
-		},
-	).Result()
+func (r *paymentsRedisRepository) SavePayment(ctx context.Context, payment *domain.Payment) (bool, error) {
+	score := float64(payment.RequestedAt.UnixNano())
+	bucket := r.buckets.bucketOf(score)
+	shard := r.bucketShard(bucket)
 
+	record, entry, allowed, err := transitionArgs(payment, domain.PAYMENT_STATUS_SUCCEEDED)
 	if err != nil {
-		return nil, err
+		return false, err
 	}
 
-	if len(pIds) == 0 {
-		return &domain.SummaryItem{}, nil
+	keys := []string{
+		r.timelineKey(payment.Processor, shard),
+		r.payloadKey(payment.Processor, shard),
+		r.bucketCountKey(payment.Processor, shard),
+		r.bucketAmountKey(payment.Processor, shard),
+		r.ns.Key(RD_KEY_TX_ROUTING_STRATEGIES),
+		r.ns.Key(RD_KEY_TX_ROUTING_COUNT, payment.Strategy),
+		r.ns.Key(RD_KEY_TX_ROUTING_AMOUNT, payment.Strategy),
+		r.recordKey(payment.CorrelationId),
+		r.shardIndexKey(payment.Processor),
 	}
 
-	values, err := r.db.HMGet(ctx, fmt.Sprintf(RD_KEY_TX_PAYMENTS_PAYLOAD, typeOfProcessor), pIds...).Result()
+	args := append([]any{
+		payment.CorrelationId,
+		formatScore(score),
+		payment.Amount.Cents(),
+		bucket,
+		payment.Processor,
+		payment.Strategy,
+		record,
+		entry,
+		shard,
+		r.expireAt(shard),
+		r.oldestLiveShard(float64(time.Now().UnixNano())),
+		fmt.Sprintf(RD_FIELD_RECORD_SAVED, payment.Processor),
+		r.retention.Milliseconds(),
+	}, allowed...)
 
+	reply, err := savePaymentScript.Run(ctx, r.db, keys, args...).Slice()
+	if err != nil {
+		return false, err
+	}
+	if created, _ := reply[0].(int64); created == 0 {
+		return false, nil
+	}
+
+	if len(reply) > 1 {
+		err := transitionResult(payment, domain.PAYMENT_STATUS_SUCCEEDED, []any{int64(0), reply[1]})
+		slog.Warn("pagamento gravado sem atualizar o registro", "correlationId", payment.CorrelationId, "error", err.Error())
+	}
+	return true, nil
+}
+
+func (r *paymentsRedisRepository) GetSummaryByProcessor(ctx context.Context, typeOfProcessor string, from, to time.Time) (*domain.SummaryItem, error) {
+	switch r.summaryMode {
+	case SUMMARY_MODE_BUCKETS:
+		return r.getSummaryFromBuckets(ctx, typeOfProcessor, from, to)
+	case SUMMARY_MODE_SCRIPT:
+		summary, err := r.sumScript(ctx, []string{typeOfProcessor}, from, to)
+		if err != nil {
+			return nil, err
+		}
+		item := summary[typeOfProcessor]
+		return &item, nil
+	}
+
+	shards, err := r.shardsInWindow(ctx, typeOfProcessor, float64(from.UnixNano()), float64(to.UnixNano()))
 	if err != nil {
 		return nil, err
 	}
 
+	return r.sumRange(ctx, typeOfProcessor, shards,
+		strconv.FormatInt(from.UnixNano(), 10),
+		strconv.FormatInt(to.UnixNano(), 10),
+	)
+}
+
+// sumRange soma os pagamentos do processador com score entre minScore e maxScore (na sintaxe do ZRANGEBYSCORE)
+// nos shards informados.
+func (r *paymentsRedisRepository) sumRange(ctx context.Context, typeOfProcessor string, shards []int64, minScore, maxScore string) (*domain.SummaryItem, error) {
 	result := &domain.SummaryItem{}
-	for _, value := range values {
-		if valueStr, ok := value.(string); ok {
-			if amount, err := strconv.ParseFloat(valueStr, 64); err == nil {
-				result.TotalAmount += amount
-				result.TotalRequests++
+	for _, shard := range shards {
+		item, err := r.sumShard(ctx, typeOfProcessor, shard, minScore, maxScore)
+		if err != nil {
+			return nil, err
+		}
+		result.TotalRequests += item.TotalRequests
+		result.TotalAmount += item.TotalAmount
+	}
+	return result, nil
+}
+
+// sumShard soma os pagamentos de um shard do processador com score entre minScore e maxScore.
+//
+// A janela é percorrida em páginas de até chunkSize membros, para que a memória não cresça com o tamanho dela:
+// cada round-trip busca, no mesmo pipeline, os valores da página anterior e os membros da próxima.
+// A página seguinte começa no score do último membro lido, pulando os membros com esse score que já foram lidos,
+// então o custo de cada página não cresce com o deslocamento na janela.
+func (r *paymentsRedisRepository) sumShard(ctx context.Context, typeOfProcessor string, shard int64, minScore, maxScore string) (*domain.SummaryItem, error) {
+	timeline := r.timelineKey(typeOfProcessor, shard)
+	payload := r.payloadKey(typeOfProcessor, shard)
+
+	var (
+		result = &domain.SummaryItem{}
+		ids    = make([]string, 0, r.chunkSize)
+		start  = minScore
+		more   = true
+
+		// Score em que a página atual começa (depois da primeira) e quantos membros com ele já foram lidos.
+		startScore float64
+		resumed    bool
+		offset     int64
+	)
+
+	for more || len(ids) > 0 {
+		pipeline := r.db.Pipeline()
+
+		var values *redis.SliceCmd
+		if len(ids) > 0 {
+			values = pipeline.HMGet(ctx, payload, ids...)
+		}
+
+		var page *redis.ZSliceCmd
+		if more {
+			page = pipeline.ZRangeArgsWithScores(ctx, redis.ZRangeArgs{
+				Key:     timeline,
+				Start:   start,
+				Stop:    maxScore,
+				ByScore: true,
+				Offset:  offset,
+				Count:   r.chunkSize,
+			})
+		}
+
+		if _, err := pipeline.Exec(ctx); err != nil {
+			return nil, err
+		}
+
+		if values != nil {
+			for _, value := range values.Val() {
+				if valueStr, ok := value.(string); ok {
+					if amount, err := domain.ParseMoneyCents(valueStr); err == nil {
+						result.TotalAmount += amount
+						result.TotalRequests++
+					}
+				}
 			}
 		}
+
+		ids = ids[:0]
+		if page == nil {
+			continue
+		}
+
+		members := page.Val()
+		for _, member := range members {
+			ids = append(ids, member.Member.(string))
+		}
+		if int64(len(members)) < r.chunkSize {
+			more = false
+			continue
+		}
+
+		last := members[len(members)-1].Score
+		tied := int64(0)
+		for i := len(members) - 1; i >= 0 && members[i].Score == last; i-- {
+			tied++
+		}
+
+		if resumed && last == startScore {
+			offset += tied
+		} else {
+			startScore, resumed, offset = last, true, tied
+			start = formatScore(last)
+		}
 	}
 
 	return result, nil
 }
 
+func (r *paymentsRedisRepository) GetRoutingStats(ctx context.Context) (map[string]map[string]domain.SummaryItem, error) {
+	strategies, err := r.db.SMembers(ctx, r.ns.Key(RD_KEY_TX_ROUTING_STRATEGIES)).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	pipeline := r.db.Pipeline()
+	counts := make([]*redis.MapStringStringCmd, len(strategies))
+	amounts := make([]*redis.MapStringStringCmd, len(strategies))
+	for i, strategy := range strategies {
+		counts[i] = pipeline.HGetAll(ctx, r.ns.Key(RD_KEY_TX_ROUTING_COUNT, strategy))
+		amounts[i] = pipeline.HGetAll(ctx, r.ns.Key(RD_KEY_TX_ROUTING_AMOUNT, strategy))
+	}
+
+	if len(strategies) > 0 {
+		if _, err := pipeline.Exec(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	stats := make(map[string]map[string]domain.SummaryItem, len(strategies))
+	for i, strategy := range strategies {
+		byProcessor := map[string]domain.SummaryItem{}
+		for processor, count := range counts[i].Val() {
+			item := byProcessor[processor]
+			item.TotalRequests, _ = strconv.ParseInt(count, 10, 64)
+			byProcessor[processor] = item
+		}
+		for processor, amount := range amounts[i].Val() {
+			item := byProcessor[processor]
+			item.TotalAmount, _ = domain.ParseMoneyCents(amount)
+			byProcessor[processor] = item
+		}
+		stats[strategy] = byProcessor
+	}
+
+	return stats, nil
+}
+
+// ResetState apaga as chaves de transações do namespace (pagamentos, registros, fila, dead-letter e estacionados),
+// com SCAN + UNLINK para não bloquear o Valkey. As chaves de health e da liderança são preservadas.
 func (r *paymentsRedisRepository) ResetState(ctx context.Context) error {
-	return r.db.FlushDB(ctx).Err()
+	deleted, err := r.ns.Unlink(ctx, r.db, RD_PATTERN_TX)
+	if err != nil {
+		return err
+	}
+	slog.Info("chaves de transações apagadas", "namespace", string(r.ns), "keys", deleted)
+
+	// A base vazia já nasce no namespace, em centavos, em shards e com os buckets da configuração atual;
+	// sem os marcadores a próxima inicialização migraria os novos valores de novo.
+	now := time.Now().Format(time.RFC3339)
+	return r.db.MSet(ctx,
+		r.ns.Key(RD_KEY_MIGRATION_NAMESPACE), now,
+		r.ns.Key(RD_KEY_MIGRATION_MONEY_CENTS), now,
+		r.ns.Key(RD_KEY_MIGRATION_TIME_SHARDS), now,
+		r.ns.Key(RD_KEY_MIGRATION_BUCKETS, r.buckets.sizeMs(), r.shards.sizeMs()), now,
+	).Err()
 }
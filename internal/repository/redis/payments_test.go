@@ -0,0 +1,63 @@
+package redis_test
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	goredis "github.com/redis/go-redis/v9"
+
+	"github.com/nicolasmmb/go-rinha-backend-2025/internal/repository/redis"
+	"github.com/nicolasmmb/go-rinha-backend-2025/internal/repository/repotest"
+)
+
+// Namespace das chaves criadas pelos testes.
+const TEST_NAMESPACE redis.Namespace = "repotest:"
+
+// Banco do Valkey/Redis usado pelos testes; as chaves do namespace são apagadas.
+const TEST_REDIS_DB = 15
+
+// newTestClient conecta ao Valkey/Redis de REPOTEST_REDIS_ADDR, ou pula o teste quando a variável não está definida.
+func newTestClient(t testing.TB) *goredis.Client {
+	t.Helper()
+
+	addr := os.Getenv("REPOTEST_REDIS_ADDR")
+	if addr == "" {
+		t.Skip("REPOTEST_REDIS_ADDR não definido")
+	}
+
+	rds := goredis.NewClient(&goredis.Options{Addr: addr, DB: TEST_REDIS_DB})
+	t.Cleanup(func() { rds.Close() })
+	return rds
+}
+
+func TestPaymentsRepository(t *testing.T) {
+	rds := newTestClient(t)
+
+	// Chave fora do namespace dos testes, que o reset do repositório não pode apagar.
+	const outsideKey = "repotest-outside-namespace"
+	if err := rds.Set(t.Context(), outsideKey, "1", 0).Err(); err != nil {
+		t.Fatalf("erro ao preparar o Redis: %v", err)
+	}
+	t.Cleanup(func() { rds.Del(t.Context(), outsideKey) })
+
+	// Páginas pequenas para que o resumo atravesse várias delas mesmo com poucos pagamentos.
+	for _, mode := range []string{redis.SUMMARY_MODE_EXACT, redis.SUMMARY_MODE_BUCKETS, redis.SUMMARY_MODE_SCRIPT} {
+		t.Run(mode, func(t *testing.T) {
+			repo, err := redis.NewPaymentsRepository(rds, TEST_NAMESPACE, redis.PaymentsRepositoryOptions{
+				SummaryMode: mode,
+				BucketSize:  100 * time.Millisecond,
+				ChunkSize:   4,
+				ShardSize:   time.Second,
+			})
+			if err != nil {
+				t.Fatalf("erro ao criar o repositório: %v", err)
+			}
+			repotest.TestPaymentRepository(t, repo)
+		})
+	}
+
+	if exists, err := rds.Exists(t.Context(), outsideKey).Result(); err != nil || exists == 0 {
+		t.Errorf("o reset apagou uma chave fora do namespace (erro %v)", err)
+	}
+}
@@ -0,0 +1,293 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/nicolasmmb/go-rinha-backend-2025/internal/core"
+	"github.com/nicolasmmb/go-rinha-backend-2025/internal/domain"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	RD_KEY_TX_QUEUE       = "tx:queue"
+	RD_QUEUE_GROUP        = "workers"
+	RD_QUEUE_FIELD        = "payment"
+	RD_QUEUE_BATCH_SIZE   = 100
+	RD_QUEUE_BLOCK_TIME   = time.Second
+	RD_QUEUE_CLAIM_PERIOD = 5 * time.Second
+	// Prazo para remover o consumidor desta instância do grupo no desligamento.
+	RD_QUEUE_LEAVE_TIMEOUT = time.Second
+)
+
+// Adiciona o pagamento ao stream apenas se ele tem menos de ARGV[1] entradas (0 = sem limite), na mesma operação
+// que confere o tamanho, para que instâncias concorrentes não passem juntas do limite. Retorna 0 com a fila cheia.
+var enqueueScript = redis.NewScript(`
+local maxLen = tonumber(ARGV[1])
+if maxLen > 0 and redis.call("XLEN", KEYS[1]) >= maxLen then
+	return 0
+end
+redis.call("XADD", KEYS[1], "*", ARGV[2], ARGV[3])
+return 1
+`)
+
+// Remove o consumidor ARGV[2] do grupo ARGV[1] apenas se ele não tem entradas pendentes, que seriam perdidas junto.
+// Retorna 1 quando o consumidor foi removido.
+var leaveGroupScript = redis.NewScript(`
+if #redis.call("XPENDING", KEYS[1], ARGV[1], "-", "+", 1, ARGV[2]) > 0 then
+	return 0
+end
+redis.call("XGROUP", "DELCONSUMER", KEYS[1], ARGV[1], ARGV[2])
+return 1
+`)
+
+// streamQueue é a fila durável baseada em Redis Streams com consumer group.
+// As entradas só saem da lista de pendentes após o Ack; entradas pendentes de consumidores
+// mortos são reivindicadas com XAUTOCLAIM após ficarem ociosas por claimIdle.
+type streamQueue struct {
+	db        *redis.Client
+	stream    string
+	consumer  string
+	maxLen    int64
+	claimIdle time.Duration
+
+	fetchOnce sync.Once
+	stopFetch context.CancelFunc
+	messages  chan *core.QueueMessage
+	closed    atomic.Bool
+}
+
+func NewStreamQueue(ctx context.Context, db *redis.Client, ns Namespace, consumer string, maxLen int, claimIdle time.Duration) (core.PaymentQueueInterface, error) {
+	q := &streamQueue{
+		db:        db,
+		stream:    ns.Key(RD_KEY_TX_QUEUE),
+		consumer:  consumer,
+		maxLen:    int64(maxLen),
+		claimIdle: claimIdle,
+		messages:  make(chan *core.QueueMessage, RD_QUEUE_BATCH_SIZE),
+	}
+
+	if err := q.createGroup(ctx); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+// createGroup cria o stream e o consumer group, se ainda não existirem.
+func (q *streamQueue) createGroup(ctx context.Context) error {
+	err := q.db.XGroupCreateMkStream(ctx, q.stream, RD_QUEUE_GROUP, "0").Err()
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return err
+	}
+	return nil
+}
+
+func (q *streamQueue) Enqueue(ctx context.Context, payment *domain.Payment) error {
+	if q.closed.Load() {
+		return core.ErrQueueClosed
+	}
+
+	payload, err := json.Marshal(payment)
+	if err != nil {
+		return err
+	}
+
+	added, err := enqueueScript.Run(ctx, q.db, []string{q.stream}, q.maxLen, RD_QUEUE_FIELD, payload).Int()
+	if err != nil {
+		return err
+	}
+	if added == 0 {
+		return core.ErrQueueFull
+	}
+	return nil
+}
+
+// Dequeue entrega as mensagens lidas por um único leitor em segundo plano,
+// evitando que cada worker prenda uma conexão do pool em um XREADGROUP bloqueante.
+func (q *streamQueue) Dequeue(ctx context.Context) (*core.QueueMessage, error) {
+	q.fetchOnce.Do(func() {
+		fetchCtx, cancel := context.WithCancel(ctx)
+		q.stopFetch = cancel
+		go q.fetch(fetchCtx)
+	})
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case msg, ok := <-q.messages:
+		if !ok {
+			return nil, core.ErrQueueClosed
+		}
+		return msg, nil
+	}
+}
+
+func (q *streamQueue) fetch(ctx context.Context) {
+	defer close(q.messages)
+
+	lastClaim := time.Time{}
+	for ctx.Err() == nil {
+		if time.Since(lastClaim) >= RD_QUEUE_CLAIM_PERIOD {
+			q.claimStale(ctx)
+			lastClaim = time.Now()
+		}
+
+		streams, err := q.db.XReadGroup(ctx, &redis.XReadGroupArgs{
+			Group:    RD_QUEUE_GROUP,
+			Consumer: q.consumer,
+			Streams:  []string{q.stream, ">"},
+			Count:    RD_QUEUE_BATCH_SIZE,
+			Block:    RD_QUEUE_BLOCK_TIME,
+		}).Result()
+
+		if errors.Is(err, redis.Nil) {
+			continue
+		}
+		if err != nil {
+			// O reset apaga o stream junto com o consumer group, que precisa ser recriado.
+			if strings.HasPrefix(err.Error(), "NOGROUP") && q.createGroup(ctx) == nil {
+				continue
+			}
+			if ctx.Err() == nil {
+				slog.Warn("falha ao ler a fila de pagamentos", "error", err.Error())
+				time.Sleep(100 * time.Millisecond)
+			}
+			continue
+		}
+
+		for _, stream := range streams {
+			q.deliver(ctx, stream.Messages)
+		}
+	}
+}
+
+// claimStale reivindica as entradas pendentes há mais de claimIdle, deixadas por consumidores que morreram.
+func (q *streamQueue) claimStale(ctx context.Context) {
+	start := "0-0"
+	for {
+		messages, next, err := q.db.XAutoClaim(ctx, &redis.XAutoClaimArgs{
+			Stream:   q.stream,
+			Group:    RD_QUEUE_GROUP,
+			Consumer: q.consumer,
+			MinIdle:  q.claimIdle,
+			Start:    start,
+			Count:    RD_QUEUE_BATCH_SIZE,
+		}).Result()
+		if err != nil {
+			if ctx.Err() == nil {
+				slog.Warn("falha ao reivindicar pagamentos pendentes", "error", err.Error())
+			}
+			return
+		}
+
+		if len(messages) > 0 {
+			slog.Info("pagamentos pendentes reivindicados", "count", len(messages))
+			q.deliver(ctx, messages)
+		}
+
+		if next == "0-0" || next == "" {
+			return
+		}
+		start = next
+	}
+}
+
+func (q *streamQueue) deliver(ctx context.Context, messages []redis.XMessage) {
+	for _, message := range messages {
+		msg := &core.QueueMessage{ID: message.ID}
+
+		payload, _ := message.Values[RD_QUEUE_FIELD].(string)
+		if err := json.Unmarshal([]byte(payload), &msg.Payment); err != nil {
+			slog.Warn("pagamento inválido na fila, descartando", "id", message.ID, "error", err.Error())
+			q.Ack(ctx, msg)
+			continue
+		}
+
+		select {
+		case q.messages <- msg:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (q *streamQueue) Ack(ctx context.Context, msg *core.QueueMessage) error {
+	pipeline := q.db.Pipeline()
+	pipeline.XAck(ctx, q.stream, RD_QUEUE_GROUP, msg.ID)
+	pipeline.XDel(ctx, q.stream, msg.ID)
+
+	if _, err := pipeline.Exec(ctx); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Close para a leitura de novas entradas; as já lidas continuam sendo entregues pelo Dequeue.
+// Entradas não confirmadas continuam pendentes no stream e são reivindicadas por outro consumidor.
+func (q *streamQueue) Close() {
+	q.closed.Store(true)
+
+	// Garante que um Dequeue posterior não inicie o leitor.
+	q.fetchOnce.Do(func() { close(q.messages) })
+	if q.stopFetch != nil {
+		q.stopFetch()
+	}
+}
+
+// TakeRemaining não retorna nada: tudo que não foi confirmado continua durável no stream.
+// Chamada depois que os workers param, remove do grupo o consumidor desta instância se ele não tem entradas pendentes;
+// o nome muda a cada boot, e sem isso os consumidores de instâncias antigas se acumulariam no grupo.
+func (q *streamQueue) TakeRemaining() []domain.Payment {
+	ctx, cancel := context.WithTimeout(context.Background(), RD_QUEUE_LEAVE_TIMEOUT)
+	defer cancel()
+
+	left, err := leaveGroupScript.Run(ctx, q.db, []string{q.stream}, RD_QUEUE_GROUP, q.consumer).Int()
+	switch {
+	case err != nil && strings.Contains(err.Error(), "NOGROUP"):
+		// O reset apagou o stream junto com o grupo e os consumidores.
+	case err != nil:
+		slog.Warn("falha ao remover o consumidor do grupo da fila", "consumer", q.consumer, "error", err.Error())
+	case left == 0:
+		slog.Info("consumidor mantido no grupo da fila com entradas pendentes, que serão reivindicadas", "consumer", q.consumer)
+	}
+	return nil
+}
+
+func (q *streamQueue) Len(ctx context.Context) (int64, error) {
+	return q.db.XLen(ctx, q.stream).Result()
+}
+
+func (q *streamQueue) Redelivers() bool {
+	return true
+}
+
+// Purge apaga o stream, recriando o consumer group, e descarta as entradas já lidas que aguardam um worker.
+func (q *streamQueue) Purge(ctx context.Context) (int64, error) {
+	pipeline := q.db.TxPipeline()
+	length := pipeline.XLen(ctx, q.stream)
+	pipeline.Unlink(ctx, q.stream)
+	if _, err := pipeline.Exec(ctx); err != nil {
+		return 0, err
+	}
+	if err := q.createGroup(ctx); err != nil {
+		return 0, err
+	}
+
+	for {
+		select {
+		case _, ok := <-q.messages:
+			if !ok {
+				return length.Val(), nil
+			}
+		default:
+			return length.Val(), nil
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package redis_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/nicolasmmb/go-rinha-backend-2025/internal/core"
+	"github.com/nicolasmmb/go-rinha-backend-2025/internal/domain"
+	"github.com/nicolasmmb/go-rinha-backend-2025/internal/repository/redis"
+)
+
+func TestStreamQueue(t *testing.T) {
+	rds := newTestClient(t)
+	ctx := t.Context()
+
+	const capacity = 10
+	stream := TEST_NAMESPACE.Key(redis.RD_KEY_TX_QUEUE)
+	if err := rds.Unlink(ctx, stream).Err(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { rds.Unlink(ctx, stream) })
+
+	// Duas instâncias dividem o stream e enfileiram ao mesmo tempo.
+	var queues []core.PaymentQueueInterface
+	for _, consumer := range []string{"repotest-a", "repotest-b"} {
+		queue, err := redis.NewStreamQueue(ctx, rds, TEST_NAMESPACE, consumer, capacity, time.Minute)
+		if err != nil {
+			t.Fatal(err)
+		}
+		queues = append(queues, queue)
+	}
+
+	t.Run("limite da fila", func(t *testing.T) {
+		var wg sync.WaitGroup
+		var mu sync.Mutex
+		accepted, full := 0, 0
+		for i := range 4 * capacity {
+			wg.Go(func() {
+				err := queues[i%len(queues)].Enqueue(ctx, &domain.Payment{CorrelationId: uuid.NewString(), Amount: 100})
+				mu.Lock()
+				defer mu.Unlock()
+				switch {
+				case err == nil:
+					accepted++
+				case errors.Is(err, core.ErrQueueFull):
+					full++
+				default:
+					t.Error(err)
+				}
+			})
+		}
+		wg.Wait()
+
+		if accepted != capacity || full != 3*capacity {
+			t.Fatalf("enfileirados %d e recusados %d, esperava %d e %d", accepted, full, capacity, 3*capacity)
+		}
+		if length, err := queues[0].Len(ctx); err != nil || length != capacity {
+			t.Fatalf("tamanho da fila = %d, %v, esperava %d", length, err, capacity)
+		}
+	})
+
+	t.Run("consumidor sai do grupo no desligamento", func(t *testing.T) {
+		queue := queues[0]
+		dequeueCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		defer cancel()
+
+		msg, err := queue.Dequeue(dequeueCtx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		queue.Close()
+
+		consumers := func() map[string]int64 {
+			infos, err := rds.XInfoConsumers(ctx, stream, redis.RD_QUEUE_GROUP).Result()
+			if err != nil {
+				t.Fatal(err)
+			}
+			pending := make(map[string]int64, len(infos))
+			for _, info := range infos {
+				pending[info.Name] = info.Pending
+			}
+			return pending
+		}
+
+		// Com entradas pendentes o consumidor continua no grupo, para que elas possam ser reivindicadas.
+		queue.TakeRemaining()
+		if _, ok := consumers()["repotest-a"]; !ok {
+			t.Fatal("consumidor com entradas pendentes removido do grupo")
+		}
+
+		// Entregas do leitor que ainda não chegaram a um worker continuam pendentes e são confirmadas aqui.
+		for {
+			if err := queue.Ack(ctx, msg); err != nil {
+				t.Fatal(err)
+			}
+			if msg, err = queue.Dequeue(dequeueCtx); err != nil {
+				break
+			}
+		}
+
+		queue.TakeRemaining()
+		if _, ok := consumers()["repotest-a"]; ok {
+			t.Fatal("consumidor sem entradas pendentes continua no grupo")
+		}
+	})
+}
@@ -0,0 +1,66 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+
+	"github.com/nicolasmmb/go-rinha-backend-2025/internal/core"
+	"github.com/nicolasmmb/go-rinha-backend-2025/internal/domain"
+	"github.com/redis/go-redis/v9"
+)
+
+const RD_KEY_TX_PARKED = "tx:parked"
+
+type reconciliationRedisRepository struct {
+	db *redis.Client
+	ns Namespace
+}
+
+func NewReconciliationRepository(db *redis.Client, ns Namespace) core.ReconciliationRepositoryInterface {
+	return &reconciliationRedisRepository{db: db, ns: ns}
+}
+
+func (r *reconciliationRedisRepository) ParkPayment(ctx context.Context, parked *domain.ParkedPayment) error {
+	payload, err := json.Marshal(parked)
+	if err != nil {
+		return err
+	}
+
+	return r.db.HSet(ctx, r.ns.Key(RD_KEY_TX_PARKED), parked.Payment.CorrelationId, payload).Err()
+}
+
+func (r *reconciliationRedisRepository) ListParkedPayments(ctx context.Context) ([]domain.ParkedPayment, error) {
+	values, err := r.db.HGetAll(ctx, r.ns.Key(RD_KEY_TX_PARKED)).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	parkedPayments := make([]domain.ParkedPayment, 0, len(values))
+	for correlationId, value := range values {
+		var parked domain.ParkedPayment
+		if err := json.Unmarshal([]byte(value), &parked); err != nil {
+			slog.Warn("pagamento estacionado inválido", "correlationId", correlationId, "error", err.Error())
+			continue
+		}
+		parkedPayments = append(parkedPayments, parked)
+	}
+
+	return parkedPayments, nil
+}
+
+func (r *reconciliationRedisRepository) RemoveParkedPayment(ctx context.Context, correlationId string) error {
+	return r.db.HDel(ctx, r.ns.Key(RD_KEY_TX_PARKED), correlationId).Err()
+}
+
+func (r *reconciliationRedisRepository) PurgeParkedPayments(ctx context.Context) (int64, error) {
+	pipeline := r.db.TxPipeline()
+	total := pipeline.HLen(ctx, r.ns.Key(RD_KEY_TX_PARKED))
+	pipeline.Unlink(ctx, r.ns.Key(RD_KEY_TX_PARKED))
+
+	if _, err := pipeline.Exec(ctx); err != nil {
+		return 0, err
+	}
+
+	return total.Val(), nil
+}
@@ -0,0 +1,160 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/nicolasmmb/go-rinha-backend-2025/internal/domain"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// Registro de cada pagamento: um hash por correlationId, com o registro em JSON no campo "record"
+	// e o histórico de transições, um array JSON, no campo "transitions". Com retenção, expira depois
+	// da última transição, como os shards de pagamentos.
+	RD_KEY_TX_PAYMENT_RECORD = "tx:record:%s"
+
+	RD_FIELD_RECORD             = "record"
+	RD_FIELD_RECORD_TRANSITIONS = "transitions"
+	// Presente quando o pagamento já foi gravado para o processador; é o que reconhece as duplicatas,
+	// mesmo quando chegam com outro requestedAt, e portanto em outro shard.
+	RD_FIELD_RECORD_SAVED = "saved:%s"
+)
+
+// transitionLua define a função usada pelos scripts que mudam o status de um pagamento.
+// Se o status atual estiver entre ARGV[first:], grava o registro, acrescenta a entrada ao histórico
+// como domain.AppendTransition e renova a expiração (ttl em ms, "0" para não expirar);
+// senão não altera nada e retorna o status atual ("" quando não há registro).
+var transitionLua = `
+local function transition(key, record, entry, ttl, first)
+	local current = redis.call("HGET", key, "record")
+	local status = ""
+	if current then
+		status = cjson.decode(current).status
+	end
+
+	local allowed = false
+	for i = first, #ARGV do
+		if ARGV[i] == status then
+			allowed = true
+			break
+		end
+	end
+	if not allowed then
+		return status
+	end
+
+	local history = {}
+	local stored = redis.call("HGET", key, "transitions")
+	if stored then
+		history = cjson.decode(stored)
+	end
+	local added = cjson.decode(entry)
+	if #history > 0 and history[#history].status == added.status then
+		history[#history] = added
+	else
+		table.insert(history, added)
+		while #history > ` + strconv.Itoa(domain.MAX_PAYMENT_TRANSITIONS) + ` do
+			table.remove(history, 1)
+		end
+	end
+
+	redis.call("HSET", key, "record", record, "transitions", cjson.encode(history))
+	if ttl ~= "0" then
+		redis.call("PEXPIRE", key, ttl)
+	end
+	return nil
+end
+`
+
+// KEYS: registro. ARGV: registro em JSON, transição em JSON, expiração em ms, status de origem permitidos.
+// Retorna {1} quando aplica a transição e {0, status atual} quando a recusa.
+var transitionPaymentScript = redis.NewScript(transitionLua + `
+local rejected = transition(KEYS[1], ARGV[1], ARGV[2], ARGV[3], 4)
+if rejected then
+	return {0, rejected}
+end
+return {1}
+`)
+
+func (r *paymentsRedisRepository) recordKey(correlationId string) string {
+	return r.ns.Key(RD_KEY_TX_PAYMENT_RECORD, correlationId)
+}
+
+// transitionArgs monta o registro, a entrada do histórico e os status de origem permitidos para a transição.
+func transitionArgs(payment *domain.Payment, status domain.PaymentStatus) (record, entry []byte, allowed []any, err error) {
+	at := time.Now()
+
+	next := domain.NewPaymentRecord(payment, status)
+	next.UpdatedAt = at
+	if record, err = json.Marshal(next); err != nil {
+		return nil, nil, nil, err
+	}
+	if entry, err = json.Marshal(domain.PaymentTransition{Status: status, At: at}); err != nil {
+		return nil, nil, nil, err
+	}
+
+	for _, previous := range domain.PreviousStatuses(status) {
+		allowed = append(allowed, string(previous))
+	}
+	return record, entry, allowed, nil
+}
+
+// transitionResult interpreta a resposta {aplicada, status atual} dos scripts de transição.
+func transitionResult(payment *domain.Payment, status domain.PaymentStatus, reply []any) error {
+	if len(reply) == 0 {
+		return fmt.Errorf("resposta inesperada do script de transição: %v", reply)
+	}
+	if applied, _ := reply[0].(int64); applied == 1 {
+		return nil
+	}
+
+	from := ""
+	if len(reply) > 1 {
+		from, _ = reply[1].(string)
+	}
+	return &domain.TransitionError{CorrelationId: payment.CorrelationId, From: domain.PaymentStatus(from), To: status}
+}
+
+func (r *paymentsRedisRepository) TransitionPayment(ctx context.Context, payment *domain.Payment, status domain.PaymentStatus) error {
+	record, entry, allowed, err := transitionArgs(payment, status)
+	if err != nil {
+		return err
+	}
+
+	args := append([]any{record, entry, r.retention.Milliseconds()}, allowed...)
+	reply, err := transitionPaymentScript.Run(ctx, r.db, []string{r.recordKey(payment.CorrelationId)}, args...).Slice()
+	if err != nil {
+		return err
+	}
+
+	return transitionResult(payment, status, reply)
+}
+
+func (r *paymentsRedisRepository) GetPaymentRecord(ctx context.Context, correlationId string) (*domain.PaymentRecord, error) {
+	values, err := r.db.HMGet(ctx, r.recordKey(correlationId), RD_FIELD_RECORD, RD_FIELD_RECORD_TRANSITIONS).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	payload, ok := values[0].(string)
+	if !ok {
+		return nil, domain.ErrPaymentNotFound
+	}
+
+	record := &domain.PaymentRecord{}
+	if err := json.Unmarshal([]byte(payload), record); err != nil {
+		return nil, err
+	}
+
+	if transitions, ok := values[1].(string); ok {
+		if err := json.Unmarshal([]byte(transitions), &record.Transitions); err != nil {
+			return nil, err
+		}
+	}
+
+	return record, nil
+}
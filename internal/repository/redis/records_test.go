@@ -0,0 +1,86 @@
+package redis_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	goredis "github.com/redis/go-redis/v9"
+
+	"github.com/nicolasmmb/go-rinha-backend-2025/internal/domain"
+	"github.com/nicolasmmb/go-rinha-backend-2025/internal/repository/redis"
+)
+
+// Pagamentos gravados para medir a memória; o bastante para que os hashes e sorted sets dos shards
+// passem do encoding compacto, como numa base real.
+const MEMORY_PAYMENTS = 2000
+
+// Memória máxima por pagamento aceita pelo teste, somando o registro e as entradas nos shards.
+// Com 40MB de maxmemory, 2KB por pagamento ainda guardam cerca de 20 mil pagamentos na retenção.
+const MEMORY_BUDGET_PER_PAYMENT = 2048
+
+// TestMemoryPerPayment mede, com MEMORY USAGE, quanto cada pagamento ocupa no Valkey depois do ciclo completo
+// (queued, processing, succeeded). O miniredis e servidores sem MEMORY USAGE pulam o teste.
+func TestMemoryPerPayment(t *testing.T) {
+	rds := newTestClient(t)
+	ctx := t.Context()
+
+	if err := rds.MemoryUsage(ctx, "repotest-memory-probe", 0).Err(); err != nil && !errors.Is(err, goredis.Nil) {
+		t.Skipf("servidor sem MEMORY USAGE: %v", err)
+	}
+
+	repo, err := redis.NewPaymentsRepository(rds, TEST_NAMESPACE, redis.PaymentsRepositoryOptions{
+		SummaryMode: redis.SUMMARY_MODE_EXACT,
+		BucketSize:  time.Second,
+		ShardSize:   time.Hour,
+		Retention:   24 * time.Hour,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.ResetState(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	for i := range MEMORY_PAYMENTS {
+		payment := &domain.Payment{CorrelationId: uuid.NewString(), Amount: 1990, Strategy: "health-aware", Attempts: 1}
+		for _, status := range []domain.PaymentStatus{domain.PAYMENT_STATUS_QUEUED, domain.PAYMENT_STATUS_PROCESSING} {
+			if err := repo.TransitionPayment(ctx, payment, status); err != nil {
+				t.Fatal(err)
+			}
+		}
+		payment.Processor = domain.PROCESSOR_DEFAULT
+		payment.RequestedAt = now.Add(-time.Duration(i) * time.Millisecond)
+		if _, err := repo.SavePayment(ctx, payment); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	recordPrefix := TEST_NAMESPACE.Key(redis.RD_KEY_TX_PAYMENT_RECORD, "")
+	var records, shards int64
+	iter := rds.Scan(ctx, 0, string(TEST_NAMESPACE)+"tx:*", 1000).Iterator()
+	for iter.Next(ctx) {
+		usage, err := rds.MemoryUsage(ctx, iter.Val(), 0).Result()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.HasPrefix(iter.Val(), recordPrefix) {
+			records += usage
+		} else {
+			shards += usage
+		}
+	}
+	if err := iter.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	perPayment := (records + shards) / MEMORY_PAYMENTS
+	t.Logf("memória por pagamento: %d bytes (registro %d, shards e contadores %d)",
+		perPayment, records/MEMORY_PAYMENTS, shards/MEMORY_PAYMENTS)
+	if perPayment > MEMORY_BUDGET_PER_PAYMENT {
+		t.Fatalf("memória por pagamento: %d bytes, esperava até %d", perPayment, MEMORY_BUDGET_PER_PAYMENT)
+	}
+}
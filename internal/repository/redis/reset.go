@@ -0,0 +1,53 @@
+package redis
+
+import (
+	"context"
+
+	"github.com/nicolasmmb/go-rinha-backend-2025/internal/core"
+	"github.com/redis/go-redis/v9"
+)
+
+// Canal em que cada reset é anunciado com o ID da instância que o fez.
+const RD_CHANNEL_RESET = "reset:requests"
+
+type resetRedisNotifier struct {
+	db *redis.Client
+	ns Namespace
+}
+
+func NewResetNotifier(db *redis.Client, ns Namespace) core.ResetNotifierInterface {
+	return &resetRedisNotifier{db: db, ns: ns}
+}
+
+func (r *resetRedisNotifier) PublishReset(ctx context.Context, origin string) error {
+	return r.db.Publish(ctx, r.ns.Key(RD_CHANNEL_RESET), origin).Err()
+}
+
+func (r *resetRedisNotifier) SubscribeReset(ctx context.Context) <-chan string {
+	resets := make(chan string, 1)
+
+	go func() {
+		defer close(resets)
+
+		sub := r.db.Subscribe(ctx, r.ns.Key(RD_CHANNEL_RESET))
+		defer sub.Close()
+
+		messages := sub.Channel()
+		for {
+			var msg *redis.Message
+			select {
+			case <-ctx.Done():
+				return
+			case msg = <-messages:
+			}
+
+			select {
+			case resets <- msg.Payload:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return resets
+}
@@ -0,0 +1,105 @@
+package redis
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// Pagamentos de cada processador, divididos em shards de tempo: processador, tamanho do shard em ms e shard.
+	// O tamanho faz parte da chave para que mudar a configuração não misture shards diferentes.
+	RD_KEY_TX_PAYMENTS_PAYLOAD  = "tx:payload:%s:%d:%d"
+	RD_KEY_TX_PAYMENTS_TIMELINE = "tx:timeline:%s:%d:%d"
+
+	// Shards com pagamentos de cada processador, por tamanho de shard, para que o resumo leia apenas os que existem.
+	RD_KEY_TX_PAYMENTS_SHARDS = "tx:shards:%s:%d"
+)
+
+// O shard s guarda os pagamentos com score em [boundary(s), boundary(s+1)), usando a mesma aritmética dos buckets.
+// Como o tamanho do shard é múltiplo do tamanho do bucket, cada bucket cabe inteiro num único shard.
+
+func (r *paymentsRedisRepository) timelineKey(processor string, shard int64) string {
+	return r.ns.Key(RD_KEY_TX_PAYMENTS_TIMELINE, processor, r.shards.sizeMs(), shard)
+}
+
+func (r *paymentsRedisRepository) payloadKey(processor string, shard int64) string {
+	return r.ns.Key(RD_KEY_TX_PAYMENTS_PAYLOAD, processor, r.shards.sizeMs(), shard)
+}
+
+func (r *paymentsRedisRepository) shardIndexKey(processor string) string {
+	return r.ns.Key(RD_KEY_TX_PAYMENTS_SHARDS, processor, r.shards.sizeMs())
+}
+
+// bucketShard retorna o shard que contém o bucket b.
+func (r *paymentsRedisRepository) bucketShard(b int64) int64 {
+	perShard := r.shards.size / r.buckets.size
+	shard := b / perShard
+	if b%perShard != 0 && b < 0 {
+		shard--
+	}
+	return shard
+}
+
+// shardBuckets retorna o primeiro e o último bucket do shard.
+func (r *paymentsRedisRepository) shardBuckets(shard int64) (first, last int64) {
+	perShard := r.shards.size / r.buckets.size
+	return shard * perShard, (shard+1)*perShard - 1
+}
+
+// expireAt retorna, em ms, quando as chaves do shard expiram: a retenção conta a partir do fim do shard.
+// Sem retenção as chaves não expiram e o retorno é zero.
+func (r *paymentsRedisRepository) expireAt(shard int64) int64 {
+	if r.retention == 0 {
+		return 0
+	}
+	return (shard+1)*r.shards.sizeMs() + r.retention.Milliseconds()
+}
+
+// oldestLiveShard retorna o shard mais antigo que ainda pode ter chaves no instante score.
+func (r *paymentsRedisRepository) oldestLiveShard(score float64) int64 {
+	return r.shards.bucketOf(score-float64(r.retention.Nanoseconds())) - 1
+}
+
+// shardsInWindow retorna, em ordem, os shards do processador que podem ter pagamentos com score entre fromScore e toScore.
+func (r *paymentsRedisRepository) shardsInWindow(ctx context.Context, processor string, fromScore, toScore float64) ([]int64, error) {
+	if fromScore > toScore {
+		return nil, nil
+	}
+
+	members, err := r.db.ZRangeByScore(ctx, r.shardIndexKey(processor), &redis.ZRangeBy{
+		Min: strconv.FormatInt(r.shards.bucketOf(fromScore), 10),
+		Max: strconv.FormatInt(r.shards.bucketOf(toScore), 10),
+	}).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	return parseShards(members)
+}
+
+func parseShards(members []string) ([]int64, error) {
+	shards := make([]int64, 0, len(members))
+	for _, member := range members {
+		shard, err := strconv.ParseInt(member, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		shards = append(shards, shard)
+	}
+	return shards, nil
+}
+
+// shardsBetween filtra os shards que podem ter pagamentos com score entre fromScore e toScore.
+func (r *paymentsRedisRepository) shardsBetween(shards []int64, fromScore, toScore float64) []int64 {
+	first, last := r.shards.bucketOf(fromScore), r.shards.bucketOf(toScore)
+
+	var between []int64
+	for _, shard := range shards {
+		if shard >= first && shard <= last {
+			between = append(between, shard)
+		}
+	}
+	return between
+}
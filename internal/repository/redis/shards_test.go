@@ -0,0 +1,283 @@
+package redis_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	goredis "github.com/redis/go-redis/v9"
+
+	"github.com/nicolasmmb/go-rinha-backend-2025/internal/domain"
+	"github.com/nicolasmmb/go-rinha-backend-2025/internal/repository/redis"
+)
+
+// Opções dos casos específicos do Redis: shards pequenos para que a janela atravesse vários deles.
+var shardedOptions = redis.PaymentsRepositoryOptions{
+	SummaryMode: redis.SUMMARY_MODE_EXACT,
+	BucketSize:  100 * time.Millisecond,
+	ShardSize:   time.Second,
+	Retention:   time.Hour,
+}
+
+func TestShardRetention(t *testing.T) {
+	rds := newTestClient(t)
+	ctx := t.Context()
+
+	repo, err := redis.NewPaymentsRepository(rds, TEST_NAMESPACE, shardedOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.ResetState(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	for _, at := range []time.Time{now.Add(-3 * time.Hour), now} {
+		payment := &domain.Payment{CorrelationId: uuid.NewString(), Amount: 100, Processor: domain.PROCESSOR_DEFAULT, RequestedAt: at}
+		if _, err := repo.SavePayment(ctx, payment); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	summary, err := repo.GetSummaryByProcessor(ctx, domain.PROCESSOR_DEFAULT, now.Add(-4*time.Hour), now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *summary != (domain.SummaryItem{TotalRequests: 1, TotalAmount: 100}) {
+		t.Fatalf("retenção: resumo = %+v, esperava apenas o pagamento recente", *summary)
+	}
+
+	keys, err := rds.Keys(ctx, string(TEST_NAMESPACE)+"tx:timeline:*").Result()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range keys {
+		ttl, err := rds.PTTL(ctx, key).Result()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ttl <= 0 || ttl > time.Hour+2*time.Second {
+			t.Fatalf("retenção: %s expira em %s, esperava até 1h depois do fim do shard", key, ttl)
+		}
+	}
+}
+
+func TestShardMigration(t *testing.T) {
+	rds := newTestClient(t)
+	ctx := t.Context()
+
+	repo, err := redis.NewPaymentsRepository(rds, TEST_NAMESPACE, shardedOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.ResetState(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// Pagamentos no formato anterior aos shards, sem contadores de buckets.
+	now := time.Now().Truncate(time.Second)
+	key := func(format string, args ...any) string { return TEST_NAMESPACE.Key(format, args...) }
+	var ids []string
+	pipeline := rds.Pipeline()
+	for i, cents := range []int64{100, 250, 1000} {
+		id := uuid.NewString()
+		at := now.Add(-time.Duration(i) * 1500 * time.Millisecond)
+		pipeline.ZAdd(ctx, key(redis.RD_KEY_TX_PAYMENTS_TIMELINE_UNSHARDED, domain.PROCESSOR_DEFAULT), goredis.Z{Score: float64(at.UnixNano()), Member: id})
+		pipeline.HSet(ctx, key(redis.RD_KEY_TX_PAYMENTS_PAYLOAD_UNSHARDED, domain.PROCESSOR_DEFAULT), id, cents)
+		ids = append(ids, id)
+	}
+	pipeline.Del(ctx, key(redis.RD_KEY_MIGRATION_TIME_SHARDS), key(redis.RD_KEY_MIGRATION_BUCKETS, shardedOptions.BucketSize.Milliseconds(), shardedOptions.ShardSize.Milliseconds()))
+	if _, err := pipeline.Exec(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := redis.MigrateToTimeShards(ctx, rds, TEST_NAMESPACE, shardedOptions); err != nil {
+		t.Fatalf("migração para shards: %v", err)
+	}
+	if err := redis.RebuildBuckets(ctx, rds, TEST_NAMESPACE, shardedOptions); err != nil {
+		t.Fatalf("reconstrução dos buckets: %v", err)
+	}
+
+	want := domain.SummaryItem{TotalRequests: 3, TotalAmount: 1350}
+	for _, mode := range []string{redis.SUMMARY_MODE_EXACT, redis.SUMMARY_MODE_BUCKETS, redis.SUMMARY_MODE_SCRIPT} {
+		opts := shardedOptions
+		opts.SummaryMode = mode
+		migrated, err := redis.NewPaymentsRepository(rds, TEST_NAMESPACE, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		summary, err := migrated.GetSummaryByProcessor(ctx, domain.PROCESSOR_DEFAULT, now.Add(-time.Minute), now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if *summary != want {
+			t.Fatalf("migração para shards (%s): resumo = %+v, esperava %+v", mode, *summary, want)
+		}
+	}
+
+	// O reenvio de um pagamento movido, com outro requestedAt, continua sendo duplicata.
+	duplicate := &domain.Payment{CorrelationId: ids[2], Amount: 1000, Processor: domain.PROCESSOR_DEFAULT, RequestedAt: now}
+	if created, err := repo.SavePayment(ctx, duplicate); err != nil || created {
+		t.Fatalf("migração para shards: SavePayment do reenvio = %v, %v, esperava false", created, err)
+	}
+
+	legacy, err := rds.Exists(ctx,
+		key(redis.RD_KEY_TX_PAYMENTS_TIMELINE_UNSHARDED, domain.PROCESSOR_DEFAULT),
+		key(redis.RD_KEY_TX_PAYMENTS_PAYLOAD_UNSHARDED, domain.PROCESSOR_DEFAULT),
+	).Result()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if legacy != 0 {
+		t.Fatal("migração para shards: as chaves antigas não foram apagadas")
+	}
+}
+
+func TestNamespaceMigration(t *testing.T) {
+	rds := newTestClient(t)
+	ctx := t.Context()
+
+	repo, err := redis.NewPaymentsRepository(rds, TEST_NAMESPACE, shardedOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.ResetState(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// Pagamentos no formato da primeira versão: chaves sem prefixo, valores em float e sem shards.
+	now := time.Now().Truncate(time.Second)
+	timeline := fmt.Sprintf(redis.RD_KEY_TX_PAYMENTS_TIMELINE_UNSHARDED, domain.PROCESSOR_DEFAULT)
+	payload := fmt.Sprintf(redis.RD_KEY_TX_PAYMENTS_PAYLOAD_UNSHARDED, domain.PROCESSOR_DEFAULT)
+	t.Cleanup(func() { rds.Del(ctx, timeline, payload) })
+
+	key := func(format string, args ...any) string { return TEST_NAMESPACE.Key(format, args...) }
+	pipeline := rds.Pipeline()
+	for i, amount := range []float64{1, 2.5, 10} {
+		id := uuid.NewString()
+		at := now.Add(-time.Duration(i) * 1500 * time.Millisecond)
+		pipeline.ZAdd(ctx, timeline, goredis.Z{Score: float64(at.UnixNano()), Member: id})
+		pipeline.HSet(ctx, payload, id, amount)
+	}
+	pipeline.Del(ctx,
+		key(redis.RD_KEY_MIGRATION_NAMESPACE),
+		key(redis.RD_KEY_MIGRATION_MONEY_CENTS),
+		key(redis.RD_KEY_MIGRATION_TIME_SHARDS),
+		key(redis.RD_KEY_MIGRATION_BUCKETS, shardedOptions.BucketSize.Milliseconds(), shardedOptions.ShardSize.Milliseconds()),
+	)
+	if _, err := pipeline.Exec(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// Mesma ordem da inicialização da aplicação.
+	if err := redis.MigrateToNamespace(ctx, rds, TEST_NAMESPACE); err != nil {
+		t.Fatalf("migração para o namespace: %v", err)
+	}
+	if err := redis.MigrateMoneyToCents(ctx, rds, TEST_NAMESPACE); err != nil {
+		t.Fatalf("migração para centavos: %v", err)
+	}
+	if err := redis.MigrateToTimeShards(ctx, rds, TEST_NAMESPACE, shardedOptions); err != nil {
+		t.Fatalf("migração para shards: %v", err)
+	}
+	if err := redis.RebuildBuckets(ctx, rds, TEST_NAMESPACE, shardedOptions); err != nil {
+		t.Fatalf("reconstrução dos buckets: %v", err)
+	}
+
+	want := domain.SummaryItem{TotalRequests: 3, TotalAmount: 1350}
+	for _, mode := range []string{redis.SUMMARY_MODE_EXACT, redis.SUMMARY_MODE_BUCKETS, redis.SUMMARY_MODE_SCRIPT} {
+		opts := shardedOptions
+		opts.SummaryMode = mode
+		migrated, err := redis.NewPaymentsRepository(rds, TEST_NAMESPACE, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		summary, err := migrated.GetSummaryByProcessor(ctx, domain.PROCESSOR_DEFAULT, now.Add(-time.Minute), now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if *summary != want {
+			t.Fatalf("migração para o namespace (%s): resumo = %+v, esperava %+v", mode, *summary, want)
+		}
+	}
+
+	legacy, err := rds.Exists(ctx, timeline, payload).Result()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if legacy != 0 {
+		t.Fatal("migração para o namespace: as chaves sem prefixo continuam no banco")
+	}
+}
+
+func TestBucketRebuild(t *testing.T) {
+	rds := newTestClient(t)
+	ctx := t.Context()
+
+	repo, err := redis.NewPaymentsRepository(rds, TEST_NAMESPACE, shardedOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.ResetState(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now().Truncate(time.Second)
+	for i, cents := range []domain.Money{100, 250, 1000} {
+		payment := &domain.Payment{CorrelationId: uuid.NewString(), Amount: cents, Processor: domain.PROCESSOR_DEFAULT, RequestedAt: now.Add(-time.Duration(i) * 1500 * time.Millisecond)}
+		if _, err := repo.SavePayment(ctx, payment); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Os pagamentos foram contados em buckets de 100ms; com 250ms o resumo por buckets depende da reconstrução.
+	opts := shardedOptions
+	opts.SummaryMode = redis.SUMMARY_MODE_BUCKETS
+	opts.BucketSize = 250 * time.Millisecond
+	if err := redis.RebuildBuckets(ctx, rds, TEST_NAMESPACE, opts); err != nil {
+		t.Fatalf("reconstrução dos buckets: %v", err)
+	}
+	rebuilt, err := redis.NewPaymentsRepository(rds, TEST_NAMESPACE, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := domain.SummaryItem{TotalRequests: 3, TotalAmount: 1350}
+	summary, err := rebuilt.GetSummaryByProcessor(ctx, domain.PROCESSOR_DEFAULT, now.Add(-time.Minute), now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *summary != want {
+		t.Fatalf("reconstrução dos buckets: resumo = %+v, esperava %+v", *summary, want)
+	}
+}
+
+func TestDuplicateAcrossShards(t *testing.T) {
+	rds := newTestClient(t)
+	ctx := t.Context()
+
+	repo, err := redis.NewPaymentsRepository(rds, TEST_NAMESPACE, shardedOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.ResetState(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	payment := &domain.Payment{CorrelationId: uuid.NewString(), Amount: 100, Processor: domain.PROCESSOR_DEFAULT, RequestedAt: now.Add(-time.Minute)}
+	if _, err := repo.SavePayment(ctx, payment); err != nil {
+		t.Fatal(err)
+	}
+
+	// A duplicata chega com outro requestedAt, que cai em outro shard.
+	duplicate := *payment
+	duplicate.RequestedAt = now
+	created, err := repo.SavePayment(ctx, &duplicate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created {
+		t.Fatal("duplicata em outro shard: SavePayment = true, esperava false")
+	}
+}
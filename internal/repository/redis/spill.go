@@ -0,0 +1,61 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+
+	"github.com/nicolasmmb/go-rinha-backend-2025/internal/core"
+	"github.com/nicolasmmb/go-rinha-backend-2025/internal/domain"
+	"github.com/redis/go-redis/v9"
+)
+
+const RD_KEY_TX_QUEUE_SPILL = "tx:queue:spill"
+
+type spillRedisRepository struct {
+	db *redis.Client
+	ns Namespace
+}
+
+func NewQueueSpillRepository(db *redis.Client, ns Namespace) core.QueueSpillRepositoryInterface {
+	return &spillRedisRepository{db: db, ns: ns}
+}
+
+func (r *spillRedisRepository) SaveSpilled(ctx context.Context, payments []domain.Payment) error {
+	if len(payments) == 0 {
+		return nil
+	}
+
+	values := make([]any, 0, len(payments))
+	for i := range payments {
+		payload, err := json.Marshal(&payments[i])
+		if err != nil {
+			return err
+		}
+		values = append(values, payload)
+	}
+
+	return r.db.RPush(ctx, r.ns.Key(RD_KEY_TX_QUEUE_SPILL), values...).Err()
+}
+
+func (r *spillRedisRepository) TakeSpilled(ctx context.Context) ([]domain.Payment, error) {
+	pipeline := r.db.TxPipeline()
+	values := pipeline.LRange(ctx, r.ns.Key(RD_KEY_TX_QUEUE_SPILL), 0, -1)
+	pipeline.Del(ctx, r.ns.Key(RD_KEY_TX_QUEUE_SPILL))
+
+	if _, err := pipeline.Exec(ctx); err != nil {
+		return nil, err
+	}
+
+	payments := make([]domain.Payment, 0, len(values.Val()))
+	for _, value := range values.Val() {
+		var payment domain.Payment
+		if err := json.Unmarshal([]byte(value), &payment); err != nil {
+			slog.Warn("pagamento inválido na fila guardada, descartando", "error", err.Error())
+			continue
+		}
+		payments = append(payments, payment)
+	}
+
+	return payments, nil
+}
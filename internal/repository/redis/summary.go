@@ -0,0 +1,153 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/nicolasmmb/go-rinha-backend-2025/internal/domain"
+	"github.com/redis/go-redis/v9"
+)
+
+// Maior página aceita pelo modo script: os ids de cada página são passados ao HMGET com unpack,
+// que é limitado pela pilha do Lua.
+const RD_SUMMARY_SCRIPT_MAX_CHUNK = 5000
+
+// Soma, no servidor, os pagamentos de cada processador na janela e retorna apenas {quantidade, centavos} de cada um.
+// Cada shard é percorrido em páginas, como em sumShard, para que o script não monte uma tabela com a janela inteira.
+//
+// KEYS: timeline e payload de cada shard, em pares, agrupados por processador.
+// ARGV: score mínimo, score máximo, tamanho da página e, para cada processador, a quantidade de shards.
+var summaryScript = redis.NewScript(`
+local min, max, chunk = ARGV[1], ARGV[2], tonumber(ARGV[3])
+
+local function sum(timeline, payload)
+	local count, total = 0, 0
+	local start, offset, startScore = min, 0, nil
+
+	while true do
+		local page = redis.call("ZRANGEBYSCORE", timeline, start, max, "WITHSCORES", "LIMIT", offset, chunk)
+		local n = #page / 2
+		if n == 0 then
+			break
+		end
+
+		local ids = {}
+		for i = 1, n do
+			ids[i] = page[2 * i - 1]
+		end
+		local values = redis.call("HMGET", payload, unpack(ids))
+		for i = 1, n do
+			local amount = values[i] and tonumber(values[i])
+			if amount then
+				count = count + 1
+				total = total + amount
+			end
+		end
+
+		if n < chunk then
+			break
+		end
+
+		local last = page[2 * n]
+		local tied = 0
+		for i = n, 1, -1 do
+			if page[2 * i] ~= last then
+				break
+			end
+			tied = tied + 1
+		end
+
+		if last == startScore then
+			offset = offset + tied
+		else
+			start, offset, startScore = last, tied, last
+		end
+	end
+
+	return count, total
+end
+
+local result, key = {}, 1
+for p = 4, #ARGV do
+	local count, total = 0, 0
+	for _ = 1, tonumber(ARGV[p]) do
+		local c, t = sum(KEYS[key], KEYS[key + 1])
+		count, total = count + c, total + t
+		key = key + 2
+	end
+	result[#result + 1] = count
+	result[#result + 1] = total
+end
+return result
+`)
+
+// GetSummaryByProcessors calcula o resumo de vários processadores de uma vez.
+// No modo script todos são somados numa única chamada ao servidor; nos demais, um a um.
+func (r *paymentsRedisRepository) GetSummaryByProcessors(ctx context.Context, processors []string, from, to time.Time) (domain.Summary, error) {
+	if r.summaryMode == SUMMARY_MODE_SCRIPT {
+		return r.sumScript(ctx, processors, from, to)
+	}
+
+	summary := make(domain.Summary, len(processors))
+	for _, processor := range processors {
+		item, err := r.GetSummaryByProcessor(ctx, processor, from, to)
+		if err != nil {
+			return nil, err
+		}
+		summary[processor] = *item
+	}
+	return summary, nil
+}
+
+// sumScript busca os shards de cada processador num pipeline e soma todos eles numa única chamada ao script.
+// Os shards são passados em KEYS, e não lidos do índice pelo script, para que ele declare todas as chaves que acessa.
+func (r *paymentsRedisRepository) sumScript(ctx context.Context, processors []string, from, to time.Time) (domain.Summary, error) {
+	fromScore, toScore := float64(from.UnixNano()), float64(to.UnixNano())
+	first, last := r.shards.bucketOf(fromScore), r.shards.bucketOf(toScore)
+
+	pipeline := r.db.Pipeline()
+	indexes := make([]*redis.StringSliceCmd, len(processors))
+	for i, processor := range processors {
+		indexes[i] = pipeline.ZRangeByScore(ctx, r.shardIndexKey(processor), &redis.ZRangeBy{
+			Min: strconv.FormatInt(first, 10),
+			Max: strconv.FormatInt(last, 10),
+		})
+	}
+	if fromScore <= toScore {
+		if _, err := pipeline.Exec(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	var keys []string
+	args := []any{strconv.FormatInt(from.UnixNano(), 10), strconv.FormatInt(to.UnixNano(), 10), r.chunkSize}
+	for i, processor := range processors {
+		shards, err := parseShards(indexes[i].Val())
+		if err != nil {
+			return nil, err
+		}
+		for _, shard := range shards {
+			keys = append(keys, r.timelineKey(processor, shard), r.payloadKey(processor, shard))
+		}
+		args = append(args, len(shards))
+	}
+
+	reply, err := summaryScript.Run(ctx, r.db, keys, args...).Int64Slice()
+	if err != nil {
+		return nil, err
+	}
+	if len(reply) != 2*len(processors) {
+		return nil, fmt.Errorf("resposta inesperada do script de resumo: %v", reply)
+	}
+
+	summary := make(domain.Summary, len(processors))
+	for i, processor := range processors {
+		summary[processor] = domain.SummaryItem{
+			TotalRequests: reply[2*i],
+			TotalAmount:   domain.Money(reply[2*i+1]),
+		}
+	}
+	return summary, nil
+}
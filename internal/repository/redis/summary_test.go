@@ -0,0 +1,119 @@
+package redis_test
+
+import (
+	"context"
+	"maps"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/nicolasmmb/go-rinha-backend-2025/internal/core"
+	"github.com/nicolasmmb/go-rinha-backend-2025/internal/domain"
+	"github.com/nicolasmmb/go-rinha-backend-2025/internal/repository/redis"
+)
+
+const (
+	// Namespace das chaves criadas pelo benchmark.
+	BENCH_NAMESPACE redis.Namespace = "summarybench:"
+	// Pagamentos gravados antes das medições, divididos entre os processadores default e fallback.
+	BENCH_PAYMENTS = 50000
+	// Goroutines que gravam os pagamentos antes das medições.
+	BENCH_SEED_WRITERS = 16
+)
+
+// BenchmarkSummary compara o tempo e a memória do resumo dos dois processadores em cada modo do repositório.
+// Os modos exact e buckets somam no cliente; o script soma no servidor.
+//
+//	REPOTEST_REDIS_ADDR=localhost:6379 go test -run '^$' -bench Summary ./internal/repository/redis
+func BenchmarkSummary(b *testing.B) {
+	rds := newTestClient(b)
+	ctx := b.Context()
+
+	newRepository := func(mode string) core.PaymentRepositoryInterface {
+		repo, err := redis.NewPaymentsRepository(rds, BENCH_NAMESPACE, redis.PaymentsRepositoryOptions{
+			SummaryMode: mode,
+			BucketSize:  time.Second,
+			ChunkSize:   redis.RD_SUMMARY_CHUNK_SIZE,
+			ShardSize:   time.Hour,
+		})
+		if err != nil {
+			b.Fatalf("erro ao criar o repositório %s: %v", mode, err)
+		}
+		return repo
+	}
+
+	seed := newRepository(redis.SUMMARY_MODE_EXACT)
+	if err := seed.ResetState(ctx); err != nil {
+		b.Fatalf("erro ao limpar o namespace: %v", err)
+	}
+	b.Cleanup(func() { seed.ResetState(context.Background()) })
+
+	from := time.Now().Add(-BENCH_PAYMENTS * time.Millisecond)
+	to := time.Now()
+	if err := seedPayments(ctx, seed, BENCH_PAYMENTS, from); err != nil {
+		b.Fatalf("erro ao gravar os pagamentos: %v", err)
+	}
+
+	processors := []string{domain.PROCESSOR_DEFAULT, domain.PROCESSOR_FALLBACK}
+	expected, err := seed.(core.BatchSummaryRepositoryInterface).GetSummaryByProcessors(ctx, processors, from, to)
+	if err != nil {
+		b.Fatalf("erro ao consultar o resumo: %v", err)
+	}
+
+	for _, mode := range []string{redis.SUMMARY_MODE_EXACT, redis.SUMMARY_MODE_BUCKETS, redis.SUMMARY_MODE_SCRIPT} {
+		b.Run(mode, func(b *testing.B) {
+			repo := newRepository(mode).(core.BatchSummaryRepositoryInterface)
+			b.ReportAllocs()
+
+			for b.Loop() {
+				summary, err := repo.GetSummaryByProcessors(ctx, processors, from, to)
+				if err != nil {
+					b.Fatalf("erro ao consultar o resumo: %v", err)
+				}
+				if !maps.Equal(summary, expected) {
+					b.Fatalf("resumo %+v diferente do modo exact %+v", summary, expected)
+				}
+			}
+		})
+	}
+}
+
+// seedPayments grava os pagamentos, um por milissegundo a partir de start, alternando entre os processadores.
+func seedPayments(ctx context.Context, repo core.PaymentRepositoryInterface, total int, start time.Time) error {
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs error
+	)
+
+	for w := range BENCH_SEED_WRITERS {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := w; i < total; i += BENCH_SEED_WRITERS {
+				processor := domain.PROCESSOR_DEFAULT
+				if i%2 == 1 {
+					processor = domain.PROCESSOR_FALLBACK
+				}
+
+				_, err := repo.SavePayment(ctx, &domain.Payment{
+					CorrelationId: uuid.NewString(),
+					Amount:        domain.Money(1990 + i%100),
+					Processor:     processor,
+					RequestedAt:   start.Add(time.Duration(i) * time.Millisecond),
+				})
+				if err != nil {
+					mu.Lock()
+					errs = err
+					mu.Unlock()
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	return errs
+}
@@ -0,0 +1,194 @@
+package repotest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/nicolasmmb/go-rinha-backend-2025/internal/core"
+	"github.com/nicolasmmb/go-rinha-backend-2025/internal/domain"
+)
+
+type claimCase struct {
+	name string
+	run  func(ctx context.Context, repo core.ClaimRepositoryInterface) error
+}
+
+var claimCases = []claimCase{
+	{"reserva fica com o primeiro dono", testClaimOwner},
+	{"reserva concluída recusa reenvios", testClaimCompleted},
+	{"reserva liberada apenas pelo dono", testClaimRelease},
+	{"reserva expira", testClaimExpires},
+	{"reservas concorrentes", testConcurrentClaims},
+	{"reset apaga reservas e contagens", testClaimReset},
+}
+
+// TestClaimRepository executa a suíte de conformidade contra um repositório de reservas, um subteste por caso.
+// ResetClaims é chamado antes de cada caso.
+func TestClaimRepository(t *testing.T, repo core.ClaimRepositoryInterface) {
+	t.Helper()
+	for _, c := range claimCases {
+		t.Run(c.name, func(t *testing.T) {
+			if err := repo.ResetClaims(t.Context()); err != nil {
+				t.Fatalf("ResetClaims: %v", err)
+			}
+			if err := c.run(t.Context(), repo); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+// TTL das reservas dos casos que não testam a expiração.
+const claimTTL = time.Minute
+
+func expectClaim(ctx context.Context, repo core.ClaimRepositoryInterface, id, owner string, want domain.PaymentClaim) error {
+	claim, err := repo.ClaimPayment(ctx, id, owner, claimTTL)
+	if err != nil {
+		return err
+	}
+	want.CorrelationId = id
+	if *claim != want {
+		return fmt.Errorf("%s reservando: %+v, esperava %+v", owner, *claim, want)
+	}
+	return nil
+}
+
+func expectClaimStats(ctx context.Context, repo core.ClaimRepositoryInterface, want domain.ClaimStats) error {
+	stats, err := repo.GetClaimStats(ctx)
+	if err != nil {
+		return err
+	}
+	if stats != want {
+		return fmt.Errorf("duplicatas = %+v, esperava %+v", stats, want)
+	}
+	return nil
+}
+
+func testClaimOwner(ctx context.Context, repo core.ClaimRepositoryInterface) error {
+	id := uuid.NewString()
+	claimed := domain.PaymentClaim{Status: domain.CLAIM_STATUS_CLAIMED, Owner: "a", Acquired: true}
+
+	if err := expectClaim(ctx, repo, id, "a", claimed); err != nil {
+		return err
+	}
+	if err := expectClaim(ctx, repo, id, "b", domain.PaymentClaim{Status: domain.CLAIM_STATUS_CLAIMED, Owner: "a"}); err != nil {
+		return err
+	}
+	// O dono pode renovar a própria reserva, e isso não é uma duplicata.
+	if err := expectClaim(ctx, repo, id, "a", claimed); err != nil {
+		return err
+	}
+	return expectClaimStats(ctx, repo, domain.ClaimStats{Claimed: 1})
+}
+
+func testClaimCompleted(ctx context.Context, repo core.ClaimRepositoryInterface) error {
+	id := uuid.NewString()
+	if _, err := repo.ClaimPayment(ctx, id, "a", claimTTL); err != nil {
+		return err
+	}
+	if err := repo.CompleteClaim(ctx, id, "a", claimTTL); err != nil {
+		return err
+	}
+
+	completed := domain.PaymentClaim{Status: domain.CLAIM_STATUS_COMPLETED, Owner: "a"}
+	for _, owner := range []string{"a", "b"} {
+		if err := expectClaim(ctx, repo, id, owner, completed); err != nil {
+			return err
+		}
+	}
+	// Liberar uma reserva concluída não a desfaz.
+	if err := repo.ReleaseClaim(ctx, id, "a"); err != nil {
+		return err
+	}
+	if err := expectClaim(ctx, repo, id, "b", completed); err != nil {
+		return err
+	}
+	return expectClaimStats(ctx, repo, domain.ClaimStats{Completed: 3})
+}
+
+func testClaimRelease(ctx context.Context, repo core.ClaimRepositoryInterface) error {
+	id := uuid.NewString()
+	if _, err := repo.ClaimPayment(ctx, id, "a", claimTTL); err != nil {
+		return err
+	}
+
+	if err := repo.ReleaseClaim(ctx, id, "b"); err != nil {
+		return err
+	}
+	if err := expectClaim(ctx, repo, id, "b", domain.PaymentClaim{Status: domain.CLAIM_STATUS_CLAIMED, Owner: "a"}); err != nil {
+		return fmt.Errorf("liberada por outro dono: %w", err)
+	}
+
+	if err := repo.ReleaseClaim(ctx, id, "a"); err != nil {
+		return err
+	}
+	return expectClaim(ctx, repo, id, "b", domain.PaymentClaim{Status: domain.CLAIM_STATUS_CLAIMED, Owner: "b", Acquired: true})
+}
+
+func testClaimExpires(ctx context.Context, repo core.ClaimRepositoryInterface) error {
+	id := uuid.NewString()
+	if _, err := repo.ClaimPayment(ctx, id, "a", 50*time.Millisecond); err != nil {
+		return err
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	return expectClaim(ctx, repo, id, "b", domain.PaymentClaim{Status: domain.CLAIM_STATUS_CLAIMED, Owner: "b", Acquired: true})
+}
+
+func testConcurrentClaims(ctx context.Context, repo core.ClaimRepositoryInterface) error {
+	const owners = 16
+	id := uuid.NewString()
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		acquired int
+		errs     []error
+	)
+	for o := range owners {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			claim, err := repo.ClaimPayment(ctx, id, fmt.Sprint("worker-", o), claimTTL)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				errs = append(errs, err)
+			} else if claim.Acquired {
+				acquired++
+			}
+		}()
+	}
+	wg.Wait()
+
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+	if acquired != 1 {
+		return fmt.Errorf("%d donos obtiveram a reserva, esperava 1", acquired)
+	}
+	return expectClaimStats(ctx, repo, domain.ClaimStats{Claimed: owners - 1})
+}
+
+func testClaimReset(ctx context.Context, repo core.ClaimRepositoryInterface) error {
+	id := uuid.NewString()
+	for _, owner := range []string{"a", "b"} {
+		if _, err := repo.ClaimPayment(ctx, id, owner, claimTTL); err != nil {
+			return err
+		}
+	}
+
+	if err := repo.ResetClaims(ctx); err != nil {
+		return err
+	}
+	if err := expectClaimStats(ctx, repo, domain.ClaimStats{}); err != nil {
+		return err
+	}
+	return expectClaim(ctx, repo, id, "b", domain.PaymentClaim{Status: domain.CLAIM_STATUS_CLAIMED, Owner: "b", Acquired: true})
+}
@@ -0,0 +1,168 @@
+package repotest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/nicolasmmb/go-rinha-backend-2025/internal/core"
+	"github.com/nicolasmmb/go-rinha-backend-2025/internal/domain"
+)
+
+type idempotencyCase struct {
+	name string
+	run  func(ctx context.Context, repo core.IdempotencyRepositoryInterface) error
+}
+
+var idempotencyCases = []idempotencyCase{
+	{"chave nova é reservada", testIdempotencyReserve},
+	{"resposta gravada é repetida", testIdempotencyReplay},
+	{"chave liberada pode ser reservada de novo", testIdempotencyRelease},
+	{"chave expira", testIdempotencyExpires},
+	{"reservas concorrentes da mesma chave", testConcurrentIdempotency},
+	{"reset apaga as chaves", testIdempotencyReset},
+}
+
+// TestIdempotencyRepository executa a suíte de conformidade contra um repositório de Idempotency-Key, um subteste por caso.
+// ResetIdempotencyKeys é chamado antes de cada caso.
+func TestIdempotencyRepository(t *testing.T, repo core.IdempotencyRepositoryInterface) {
+	t.Helper()
+	for _, c := range idempotencyCases {
+		t.Run(c.name, func(t *testing.T) {
+			if err := repo.ResetIdempotencyKeys(t.Context()); err != nil {
+				t.Fatalf("ResetIdempotencyKeys: %v", err)
+			}
+			if err := c.run(t.Context(), repo); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+// TTL das chaves dos casos que não testam a expiração.
+const idempotencyTTL = time.Minute
+
+func expectReserved(ctx context.Context, repo core.IdempotencyRepositoryInterface, key string) error {
+	record, err := repo.ReserveIdempotencyKey(ctx, key, "hash", idempotencyTTL)
+	if err != nil {
+		return err
+	}
+	if record != nil {
+		return fmt.Errorf("chave %s não foi reservada: encontrou %+v", key, *record)
+	}
+	return nil
+}
+
+func testIdempotencyReserve(ctx context.Context, repo core.IdempotencyRepositoryInterface) error {
+	key := uuid.NewString()
+	if err := expectReserved(ctx, repo, key); err != nil {
+		return err
+	}
+
+	// A segunda reserva encontra a primeira, ainda sem resposta, com o hash original.
+	record, err := repo.ReserveIdempotencyKey(ctx, key, "other-hash", idempotencyTTL)
+	if err != nil {
+		return err
+	}
+	if record == nil || record.RequestHash != "hash" || record.Response != nil {
+		return fmt.Errorf("segunda reserva: %+v, esperava a reserva pendente com o hash original", record)
+	}
+	return nil
+}
+
+func testIdempotencyReplay(ctx context.Context, repo core.IdempotencyRepositoryInterface) error {
+	key := uuid.NewString()
+	if err := expectReserved(ctx, repo, key); err != nil {
+		return err
+	}
+
+	want := domain.IdempotentResponse{Status: 201, ContentType: "application/json", Body: `{"ok":true}`}
+	if err := repo.SaveIdempotentResponse(ctx, key, &domain.IdempotencyRecord{RequestHash: "hash", Response: &want}, idempotencyTTL); err != nil {
+		return err
+	}
+
+	record, err := repo.ReserveIdempotencyKey(ctx, key, "hash", idempotencyTTL)
+	if err != nil {
+		return err
+	}
+	if record == nil || record.Response == nil {
+		return fmt.Errorf("reserva depois da resposta: %+v, esperava a resposta gravada", record)
+	}
+	got := *record.Response
+	if got.Status != want.Status || got.ContentType != want.ContentType || got.Body != want.Body || record.RequestHash != "hash" {
+		return fmt.Errorf("resposta repetida = %+v (hash %q), esperava %+v", got, record.RequestHash, want)
+	}
+	return nil
+}
+
+func testIdempotencyRelease(ctx context.Context, repo core.IdempotencyRepositoryInterface) error {
+	key := uuid.NewString()
+	if err := expectReserved(ctx, repo, key); err != nil {
+		return err
+	}
+	if err := repo.ReleaseIdempotencyKey(ctx, key); err != nil {
+		return err
+	}
+	return expectReserved(ctx, repo, key)
+}
+
+func testIdempotencyExpires(ctx context.Context, repo core.IdempotencyRepositoryInterface) error {
+	key := uuid.NewString()
+	if _, err := repo.ReserveIdempotencyKey(ctx, key, "hash", 50*time.Millisecond); err != nil {
+		return err
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	return expectReserved(ctx, repo, key)
+}
+
+func testConcurrentIdempotency(ctx context.Context, repo core.IdempotencyRepositoryInterface) error {
+	const requests = 16
+	key := uuid.NewString()
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		reserved int
+		errs     []error
+	)
+	for range requests {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			record, err := repo.ReserveIdempotencyKey(ctx, key, "hash", idempotencyTTL)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				errs = append(errs, err)
+			} else if record == nil {
+				reserved++
+			}
+		}()
+	}
+	wg.Wait()
+
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+	if reserved != 1 {
+		return fmt.Errorf("%d requisições reservaram a chave, esperava 1", reserved)
+	}
+	return nil
+}
+
+func testIdempotencyReset(ctx context.Context, repo core.IdempotencyRepositoryInterface) error {
+	key := uuid.NewString()
+	if err := expectReserved(ctx, repo, key); err != nil {
+		return err
+	}
+	if err := repo.ResetIdempotencyKeys(ctx); err != nil {
+		return err
+	}
+	return expectReserved(ctx, repo, key)
+}
@@ -0,0 +1,517 @@
+// Package repotest reúne os comportamentos que toda implementação de repositório precisa ter,
+// para que os backends (Redis, memória, ...) possam ser trocados sem mudar os resultados da API.
+// Os testes de cada backend chamam as suítes com os seus repositórios.
+package repotest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/nicolasmmb/go-rinha-backend-2025/internal/core"
+	"github.com/nicolasmmb/go-rinha-backend-2025/internal/domain"
+)
+
+type paymentCase struct {
+	name string
+	run  func(ctx context.Context, repo core.PaymentRepositoryInterface) error
+}
+
+var paymentCases = []paymentCase{
+	{"resumo vazio", testEmptySummary},
+	{"janela fechada nas duas pontas", testSummaryWindow},
+	{"resumo separado por processador", testSummaryByProcessor},
+	{"duplicata não altera o resumo", testDuplicate},
+	{"mesmo correlationId em processadores diferentes", testSameIdOtherProcessor},
+	{"soma exata de centavos", testExactSum},
+	{"janela com muitos pagamentos no mesmo instante", testSummaryTies},
+	{"estatísticas de roteamento", testRoutingStats},
+	{"gravações concorrentes", testConcurrentSaves},
+	{"reset apaga tudo", testResetState},
+	{"registro de pagamento desconhecido", testUnknownRecord},
+	{"registro acompanha o pagamento", testRecordLifecycle},
+	{"registro concluído não volta atrás", testSucceededRecordIsFinal},
+	{"transição inválida não cria registro", testInvalidTransition},
+	{"reentrega não repete o status no histórico", testRepeatedTransition},
+	{"histórico de transições limitado", testTransitionHistoryCap},
+}
+
+// TestPaymentRepository executa a suíte de conformidade contra um repositório de pagamentos, um subteste por caso.
+// ResetState é chamado antes de cada caso, então o repositório não pode conter dados que importam.
+func TestPaymentRepository(t *testing.T, repo core.PaymentRepositoryInterface) {
+	t.Helper()
+	for _, c := range paymentCases {
+		t.Run(c.name, func(t *testing.T) {
+			if err := repo.ResetState(t.Context()); err != nil {
+				t.Fatalf("ResetState: %v", err)
+			}
+			if err := c.run(t.Context(), repo); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+// Instante base dos casos, com precisão de milissegundos para valer em qualquer backend.
+var base = time.Date(2025, time.July, 15, 12, 0, 0, 0, time.UTC)
+
+func newPayment(processor string, amount domain.Money, at time.Time) *domain.Payment {
+	return &domain.Payment{
+		CorrelationId: uuid.NewString(),
+		Amount:        amount,
+		Processor:     processor,
+		RequestedAt:   at,
+	}
+}
+
+func save(ctx context.Context, repo core.PaymentRepositoryInterface, payments ...*domain.Payment) error {
+	for _, p := range payments {
+		created, err := repo.SavePayment(ctx, p)
+		if err != nil {
+			return fmt.Errorf("SavePayment(%s): %w", p.CorrelationId, err)
+		}
+		if !created {
+			return fmt.Errorf("SavePayment(%s): esperava created=true para um pagamento novo", p.CorrelationId)
+		}
+	}
+	return nil
+}
+
+func expectSummary(ctx context.Context, repo core.PaymentRepositoryInterface, processor string, from, to time.Time, want domain.SummaryItem) error {
+	got, err := repo.GetSummaryByProcessor(ctx, processor, from, to)
+	if err != nil {
+		return fmt.Errorf("GetSummaryByProcessor(%s): %w", processor, err)
+	}
+	if *got != want {
+		return fmt.Errorf("GetSummaryByProcessor(%s, %s, %s) = %+v, esperava %+v",
+			processor, from.Format(time.RFC3339Nano), to.Format(time.RFC3339Nano), *got, want)
+	}
+	return nil
+}
+
+func testEmptySummary(ctx context.Context, repo core.PaymentRepositoryInterface) error {
+	return expectSummary(ctx, repo, domain.PROCESSOR_DEFAULT, base.Add(-time.Hour), base.Add(time.Hour), domain.SummaryItem{})
+}
+
+func testSummaryWindow(ctx context.Context, repo core.PaymentRepositoryInterface) error {
+	err := save(ctx, repo,
+		newPayment(domain.PROCESSOR_DEFAULT, 100, base.Add(-time.Millisecond)),
+		newPayment(domain.PROCESSOR_DEFAULT, 200, base),
+		newPayment(domain.PROCESSOR_DEFAULT, 300, base.Add(500*time.Millisecond)),
+		newPayment(domain.PROCESSOR_DEFAULT, 400, base.Add(time.Second)),
+		newPayment(domain.PROCESSOR_DEFAULT, 500, base.Add(time.Second+time.Millisecond)),
+	)
+	if err != nil {
+		return err
+	}
+
+	return errors.Join(
+		expectSummary(ctx, repo, domain.PROCESSOR_DEFAULT, base, base.Add(time.Second), domain.SummaryItem{TotalRequests: 3, TotalAmount: 900}),
+		expectSummary(ctx, repo, domain.PROCESSOR_DEFAULT, base, base, domain.SummaryItem{TotalRequests: 1, TotalAmount: 200}),
+		expectSummary(ctx, repo, domain.PROCESSOR_DEFAULT, base.Add(2*time.Second), base.Add(3*time.Second), domain.SummaryItem{}),
+		expectSummary(ctx, repo, domain.PROCESSOR_DEFAULT, base.Add(-time.Hour), base.Add(time.Hour), domain.SummaryItem{TotalRequests: 5, TotalAmount: 1500}),
+	)
+}
+
+func testSummaryByProcessor(ctx context.Context, repo core.PaymentRepositoryInterface) error {
+	err := save(ctx, repo,
+		newPayment(domain.PROCESSOR_DEFAULT, 1990, base),
+		newPayment(domain.PROCESSOR_FALLBACK, 1000, base),
+		newPayment(domain.PROCESSOR_FALLBACK, 1, base.Add(time.Millisecond)),
+	)
+	if err != nil {
+		return err
+	}
+
+	from, to := base.Add(-time.Second), base.Add(time.Second)
+	want := domain.Summary{
+		domain.PROCESSOR_DEFAULT:  {TotalRequests: 1, TotalAmount: 1990},
+		domain.PROCESSOR_FALLBACK: {TotalRequests: 2, TotalAmount: 1001},
+		"desconhecido":            {},
+	}
+
+	var errs []error
+	for processor, item := range want {
+		errs = append(errs, expectSummary(ctx, repo, processor, from, to, item))
+	}
+
+	if batch, ok := repo.(core.BatchSummaryRepositoryInterface); ok {
+		processors := []string{domain.PROCESSOR_DEFAULT, domain.PROCESSOR_FALLBACK, "desconhecido"}
+		got, err := batch.GetSummaryByProcessors(ctx, processors, from, to)
+		if err != nil {
+			errs = append(errs, err)
+		} else if !maps.Equal(got, want) {
+			errs = append(errs, fmt.Errorf("resumo de vários processadores = %+v, esperava %+v", got, want))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func testDuplicate(ctx context.Context, repo core.PaymentRepositoryInterface) error {
+	payment := newPayment(domain.PROCESSOR_DEFAULT, 1000, base)
+	if err := save(ctx, repo, payment); err != nil {
+		return err
+	}
+
+	// A duplicata chega com outro valor e outro instante, mas o primeiro registro prevalece.
+	duplicate := *payment
+	duplicate.Amount = 5000
+	duplicate.RequestedAt = base.Add(time.Minute)
+	created, err := repo.SavePayment(ctx, &duplicate)
+	if err != nil {
+		return fmt.Errorf("SavePayment da duplicata: %w", err)
+	}
+	if created {
+		return errors.New("SavePayment da duplicata retornou created=true")
+	}
+
+	return errors.Join(
+		expectSummary(ctx, repo, domain.PROCESSOR_DEFAULT, base, base, domain.SummaryItem{TotalRequests: 1, TotalAmount: 1000}),
+		expectSummary(ctx, repo, domain.PROCESSOR_DEFAULT, base.Add(time.Second), base.Add(time.Hour), domain.SummaryItem{}),
+	)
+}
+
+func testSameIdOtherProcessor(ctx context.Context, repo core.PaymentRepositoryInterface) error {
+	payment := newPayment(domain.PROCESSOR_DEFAULT, 1000, base)
+	other := *payment
+	other.Processor = domain.PROCESSOR_FALLBACK
+	if err := save(ctx, repo, payment, &other); err != nil {
+		return err
+	}
+
+	return errors.Join(
+		expectSummary(ctx, repo, domain.PROCESSOR_DEFAULT, base, base, domain.SummaryItem{TotalRequests: 1, TotalAmount: 1000}),
+		expectSummary(ctx, repo, domain.PROCESSOR_FALLBACK, base, base, domain.SummaryItem{TotalRequests: 1, TotalAmount: 1000}),
+	)
+}
+
+func testExactSum(ctx context.Context, repo core.PaymentRepositoryInterface) error {
+	// Em float64, somar 0.1 mil vezes não dá exatamente 100.
+	const count = 1000
+	for i := range count {
+		if err := save(ctx, repo, newPayment(domain.PROCESSOR_DEFAULT, 10, base.Add(time.Duration(i)*time.Millisecond))); err != nil {
+			return err
+		}
+	}
+
+	return expectSummary(ctx, repo, domain.PROCESSOR_DEFAULT, base, base.Add(time.Hour), domain.SummaryItem{TotalRequests: count, TotalAmount: 10000})
+}
+
+// testSummaryTies cobre os backends que somam a janela em páginas: vários pagamentos com o mesmo score
+// atravessam o limite das páginas e nenhum pode ser contado duas vezes ou pulado.
+func testSummaryTies(ctx context.Context, repo core.PaymentRepositoryInterface) error {
+	var payments []*domain.Payment
+	for i := range 10 {
+		payments = append(payments, newPayment(domain.PROCESSOR_DEFAULT, 1, base.Add(time.Duration(i)*time.Millisecond)))
+	}
+	for range 15 {
+		payments = append(payments, newPayment(domain.PROCESSOR_DEFAULT, 10, base.Add(10*time.Millisecond)))
+	}
+	for i := range 5 {
+		payments = append(payments, newPayment(domain.PROCESSOR_DEFAULT, 100, base.Add(time.Duration(11+i)*time.Millisecond)))
+	}
+	if err := save(ctx, repo, payments...); err != nil {
+		return err
+	}
+
+	return errors.Join(
+		expectSummary(ctx, repo, domain.PROCESSOR_DEFAULT, base, base.Add(time.Second), domain.SummaryItem{TotalRequests: 30, TotalAmount: 660}),
+		expectSummary(ctx, repo, domain.PROCESSOR_DEFAULT, base.Add(10*time.Millisecond), base.Add(10*time.Millisecond), domain.SummaryItem{TotalRequests: 15, TotalAmount: 150}),
+		expectSummary(ctx, repo, domain.PROCESSOR_DEFAULT, base.Add(5*time.Millisecond), base.Add(12*time.Millisecond), domain.SummaryItem{TotalRequests: 22, TotalAmount: 355}),
+	)
+}
+
+func testRoutingStats(ctx context.Context, repo core.PaymentRepositoryInterface) error {
+	withStrategy := func(p *domain.Payment, strategy string) *domain.Payment {
+		p.Strategy = strategy
+		return p
+	}
+
+	err := save(ctx, repo,
+		withStrategy(newPayment(domain.PROCESSOR_DEFAULT, 100, base), "health-aware"),
+		withStrategy(newPayment(domain.PROCESSOR_DEFAULT, 250, base), "health-aware"),
+		withStrategy(newPayment(domain.PROCESSOR_FALLBACK, 300, base), "health-aware"),
+		withStrategy(newPayment(domain.PROCESSOR_FALLBACK, 400, base), "cost-weighted"),
+		// Pagamentos sem estratégia não entram nas estatísticas.
+		newPayment(domain.PROCESSOR_DEFAULT, 999, base),
+	)
+	if err != nil {
+		return err
+	}
+
+	stats, err := repo.GetRoutingStats(ctx)
+	if err != nil {
+		return fmt.Errorf("GetRoutingStats: %w", err)
+	}
+
+	want := map[string]map[string]domain.SummaryItem{
+		"health-aware": {
+			domain.PROCESSOR_DEFAULT:  {TotalRequests: 2, TotalAmount: 350},
+			domain.PROCESSOR_FALLBACK: {TotalRequests: 1, TotalAmount: 300},
+		},
+		"cost-weighted": {
+			domain.PROCESSOR_FALLBACK: {TotalRequests: 1, TotalAmount: 400},
+		},
+	}
+	if len(stats) != len(want) {
+		return fmt.Errorf("GetRoutingStats = %v, esperava %v", stats, want)
+	}
+	for strategy, byProcessor := range want {
+		if len(stats[strategy]) != len(byProcessor) {
+			return fmt.Errorf("GetRoutingStats[%s] = %v, esperava %v", strategy, stats[strategy], byProcessor)
+		}
+		for processor, item := range byProcessor {
+			if stats[strategy][processor] != item {
+				return fmt.Errorf("GetRoutingStats[%s][%s] = %+v, esperava %+v", strategy, processor, stats[strategy][processor], item)
+			}
+		}
+	}
+	return nil
+}
+
+func testConcurrentSaves(ctx context.Context, repo core.PaymentRepositoryInterface) error {
+	const (
+		writers   = 8
+		perWriter = 50
+	)
+
+	// Metade dos escritores repete os pagamentos da outra metade, simulando reentregas simultâneas.
+	payments := make([][]*domain.Payment, writers/2)
+	for w := range payments {
+		for i := range perWriter {
+			payments[w] = append(payments[w], newPayment(domain.PROCESSOR_DEFAULT, 100, base.Add(time.Duration(w*perWriter+i)*time.Millisecond)))
+		}
+	}
+
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		created int
+		errs    []error
+	)
+	for w := range writers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for _, p := range payments[w%len(payments)] {
+				ok, err := repo.SavePayment(ctx, p)
+				mu.Lock()
+				if err != nil {
+					errs = append(errs, err)
+				} else if ok {
+					created++
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+
+	total := int64(len(payments) * perWriter)
+	if int64(created) != total {
+		return fmt.Errorf("%d gravações retornaram created=true, esperava %d", created, total)
+	}
+	return expectSummary(ctx, repo, domain.PROCESSOR_DEFAULT, base, base.Add(time.Hour), domain.SummaryItem{TotalRequests: total, TotalAmount: domain.Money(total * 100)})
+}
+
+func testResetState(ctx context.Context, repo core.PaymentRepositoryInterface) error {
+	payment := newPayment(domain.PROCESSOR_DEFAULT, 100, base)
+	payment.Strategy = "health-aware"
+	if err := transitionTo(ctx, repo, payment, domain.PAYMENT_STATUS_QUEUED, domain.PAYMENT_STATUS_PROCESSING); err != nil {
+		return err
+	}
+	if err := save(ctx, repo, payment); err != nil {
+		return err
+	}
+
+	if err := repo.ResetState(ctx); err != nil {
+		return fmt.Errorf("ResetState: %w", err)
+	}
+
+	stats, err := repo.GetRoutingStats(ctx)
+	if err != nil {
+		return fmt.Errorf("GetRoutingStats: %w", err)
+	}
+	if len(stats) != 0 {
+		return fmt.Errorf("GetRoutingStats após o reset = %v, esperava vazio", stats)
+	}
+
+	if err := expectSummary(ctx, repo, domain.PROCESSOR_DEFAULT, base, base, domain.SummaryItem{}); err != nil {
+		return err
+	}
+	if _, err := repo.GetPaymentRecord(ctx, payment.CorrelationId); !errors.Is(err, domain.ErrPaymentNotFound) {
+		return fmt.Errorf("GetPaymentRecord após o reset: erro %v, esperava domain.ErrPaymentNotFound", err)
+	}
+
+	// Depois do reset o mesmo correlationId volta a ser um pagamento novo.
+	return save(ctx, repo, payment)
+}
+
+func transitionTo(ctx context.Context, repo core.PaymentRepositoryInterface, payment *domain.Payment, statuses ...domain.PaymentStatus) error {
+	for _, status := range statuses {
+		if err := repo.TransitionPayment(ctx, payment, status); err != nil {
+			return fmt.Errorf("TransitionPayment(%s): %w", status, err)
+		}
+	}
+	return nil
+}
+
+// expectRecord confere o registro do pagamento com o status atual e o histórico esperados.
+// O instante da última transição precisa ser o UpdatedAt do registro.
+func expectRecord(ctx context.Context, repo core.PaymentRepositoryInterface, payment *domain.Payment, transitions ...domain.PaymentStatus) (*domain.PaymentRecord, error) {
+	got, err := repo.GetPaymentRecord(ctx, payment.CorrelationId)
+	if err != nil {
+		return nil, fmt.Errorf("GetPaymentRecord(%s): %w", payment.CorrelationId, err)
+	}
+
+	want := domain.NewPaymentRecord(payment, transitions[len(transitions)-1])
+	sameRequestedAt := (got.RequestedAt == nil) == (want.RequestedAt == nil) &&
+		(got.RequestedAt == nil || got.RequestedAt.Equal(*want.RequestedAt))
+	if got.Amount != want.Amount || got.Status != want.Status || got.Processor != want.Processor ||
+		got.Strategy != want.Strategy || got.Attempts != want.Attempts || got.LastError != want.LastError ||
+		!sameRequestedAt {
+		return nil, fmt.Errorf("GetPaymentRecord(%s) = %+v, esperava %+v", payment.CorrelationId, *got, *want)
+	}
+
+	if len(got.Transitions) != len(transitions) {
+		return nil, fmt.Errorf("GetPaymentRecord(%s): histórico %+v, esperava os status %v", payment.CorrelationId, got.Transitions, transitions)
+	}
+	for i, transition := range got.Transitions {
+		if transition.Status != transitions[i] || (i > 0 && transition.At.Before(got.Transitions[i-1].At)) {
+			return nil, fmt.Errorf("GetPaymentRecord(%s): histórico %+v, esperava os status %v em ordem", payment.CorrelationId, got.Transitions, transitions)
+		}
+	}
+	if last := got.Transitions[len(got.Transitions)-1]; !last.At.Equal(got.UpdatedAt) {
+		return nil, fmt.Errorf("GetPaymentRecord(%s): updatedAt %s diferente da última transição %s", payment.CorrelationId, got.UpdatedAt, last.At)
+	}
+	return got, nil
+}
+
+func testUnknownRecord(ctx context.Context, repo core.PaymentRepositoryInterface) error {
+	if _, err := repo.GetPaymentRecord(ctx, uuid.NewString()); !errors.Is(err, domain.ErrPaymentNotFound) {
+		return fmt.Errorf("GetPaymentRecord: erro %v, esperava domain.ErrPaymentNotFound", err)
+	}
+	return nil
+}
+
+func testRecordLifecycle(ctx context.Context, repo core.PaymentRepositoryInterface) error {
+	payment := newPayment(domain.PROCESSOR_DEFAULT, 1990, time.Time{})
+	payment.Processor = ""
+
+	if err := transitionTo(ctx, repo, payment, domain.PAYMENT_STATUS_QUEUED); err != nil {
+		return err
+	}
+	if _, err := expectRecord(ctx, repo, payment, domain.PAYMENT_STATUS_QUEUED); err != nil {
+		return err
+	}
+	if err := transitionTo(ctx, repo, payment, domain.PAYMENT_STATUS_PROCESSING); err != nil {
+		return err
+	}
+
+	payment.Processor = domain.PROCESSOR_FALLBACK
+	payment.Strategy = "health-aware"
+	payment.RequestedAt = base
+	payment.Attempts = 6
+	payment.LastError = "processador respondeu 500"
+	if err := save(ctx, repo, payment); err != nil {
+		return err
+	}
+
+	_, err := expectRecord(ctx, repo, payment, domain.PAYMENT_STATUS_QUEUED, domain.PAYMENT_STATUS_PROCESSING, domain.PAYMENT_STATUS_SUCCEEDED)
+	return err
+}
+
+func testSucceededRecordIsFinal(ctx context.Context, repo core.PaymentRepositoryInterface) error {
+	payment := newPayment(domain.PROCESSOR_DEFAULT, 1000, base)
+	payment.Attempts = 1
+	if err := transitionTo(ctx, repo, payment, domain.PAYMENT_STATUS_QUEUED, domain.PAYMENT_STATUS_PROCESSING); err != nil {
+		return err
+	}
+	if err := save(ctx, repo, payment); err != nil {
+		return err
+	}
+
+	// Uma reentrega atrasada não pode rebaixar um pagamento concluído.
+	for _, status := range []domain.PaymentStatus{domain.PAYMENT_STATUS_QUEUED, domain.PAYMENT_STATUS_PROCESSING} {
+		err := repo.TransitionPayment(ctx, payment, status)
+		var transitionErr *domain.TransitionError
+		if !errors.As(err, &transitionErr) || !errors.Is(err, domain.ErrInvalidTransition) ||
+			transitionErr.From != domain.PAYMENT_STATUS_SUCCEEDED || transitionErr.To != status {
+			return fmt.Errorf("TransitionPayment(%s): erro %v, esperava *domain.TransitionError de succeeded", status, err)
+		}
+	}
+
+	_, err := expectRecord(ctx, repo, payment, domain.PAYMENT_STATUS_QUEUED, domain.PAYMENT_STATUS_PROCESSING, domain.PAYMENT_STATUS_SUCCEEDED)
+	return err
+}
+
+func testInvalidTransition(ctx context.Context, repo core.PaymentRepositoryInterface) error {
+	payment := newPayment(domain.PROCESSOR_DEFAULT, 1000, base)
+
+	// Um pagamento precisa ser enfileirado antes de qualquer outro status.
+	if err := repo.TransitionPayment(ctx, payment, domain.PAYMENT_STATUS_PROCESSING); !errors.Is(err, domain.ErrInvalidTransition) {
+		return fmt.Errorf("TransitionPayment(processing) sem registro: erro %v, esperava domain.ErrInvalidTransition", err)
+	}
+
+	// O pagamento confirmado é gravado mesmo quando o registro não pode ir para succeeded.
+	if err := save(ctx, repo, payment); err != nil {
+		return err
+	}
+	if err := expectSummary(ctx, repo, domain.PROCESSOR_DEFAULT, base, base, domain.SummaryItem{TotalRequests: 1, TotalAmount: 1000}); err != nil {
+		return err
+	}
+	if _, err := repo.GetPaymentRecord(ctx, payment.CorrelationId); !errors.Is(err, domain.ErrPaymentNotFound) {
+		return fmt.Errorf("GetPaymentRecord: erro %v, esperava domain.ErrPaymentNotFound", err)
+	}
+	return nil
+}
+
+func testRepeatedTransition(ctx context.Context, repo core.PaymentRepositoryInterface) error {
+	payment := newPayment(domain.PROCESSOR_DEFAULT, 1000, base)
+	if err := transitionTo(ctx, repo, payment, domain.PAYMENT_STATUS_QUEUED, domain.PAYMENT_STATUS_PROCESSING); err != nil {
+		return err
+	}
+
+	// Cada reentrega ao worker leva o pagamento de processing para processing de novo.
+	for range 3 {
+		if err := transitionTo(ctx, repo, payment, domain.PAYMENT_STATUS_PROCESSING); err != nil {
+			return err
+		}
+	}
+
+	_, err := expectRecord(ctx, repo, payment, domain.PAYMENT_STATUS_QUEUED, domain.PAYMENT_STATUS_PROCESSING)
+	return err
+}
+
+func testTransitionHistoryCap(ctx context.Context, repo core.PaymentRepositoryInterface) error {
+	payment := newPayment(domain.PROCESSOR_DEFAULT, 1000, base)
+	if err := transitionTo(ctx, repo, payment, domain.PAYMENT_STATUS_QUEUED); err != nil {
+		return err
+	}
+
+	// Um pagamento que falha e volta a ser processado alterna entre processing e failed.
+	statuses := []domain.PaymentStatus{domain.PAYMENT_STATUS_QUEUED}
+	for i := range domain.MAX_PAYMENT_TRANSITIONS {
+		status := domain.PAYMENT_STATUS_PROCESSING
+		if i%2 == 1 {
+			status = domain.PAYMENT_STATUS_FAILED
+		}
+		if err := transitionTo(ctx, repo, payment, status); err != nil {
+			return err
+		}
+		statuses = append(statuses, status)
+	}
+
+	_, err := expectRecord(ctx, repo, payment, statuses[len(statuses)-domain.MAX_PAYMENT_TRANSITIONS:]...)
+	return err
+}
@@ -0,0 +1,50 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+	"time"
+
+	// Driver SQLite em Go puro, sem cgo.
+	_ "modernc.org/sqlite"
+)
+
+// Pragmas aplicados em cada conexão: WAL permite leituras durante as escritas e o busy_timeout
+// faz escritas concorrentes esperarem pela trava em vez de falharem com SQLITE_BUSY.
+var connectionPragmas = []string{
+	"journal_mode(WAL)",
+	"synchronous(NORMAL)",
+	"busy_timeout(5000)",
+	"foreign_keys(ON)",
+}
+
+// Open abre (ou cria) o banco no caminho informado e aplica as migrações pendentes.
+func Open(ctx context.Context, path string) (*sql.DB, error) {
+	query := url.Values{}
+	for _, pragma := range connectionPragmas {
+		query.Add("_pragma", pragma)
+	}
+	// As transações leem o registro antes de alterá-lo; começando com a trava de escrita,
+	// duas transações concorrentes esperam uma pela outra em vez de falharem ao promover a trava.
+	query.Set("_txlock", "immediate")
+
+	db, err := sql.Open("sqlite", "file:"+path+"?"+query.Encode())
+	if err != nil {
+		return nil, err
+	}
+	db.SetConnMaxIdleTime(time.Minute)
+
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("falha ao abrir o SQLite em %s: %w", path, err)
+	}
+
+	if err := Migrate(ctx, db); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return db, nil
+}
@@ -0,0 +1,91 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// migrations são aplicadas em ordem, uma única vez cada; a versão de uma migração é sua posição na lista.
+// Migrações já publicadas nunca devem ser alteradas, apenas novas adicionadas ao final.
+var migrations = []string{
+	// 1: pagamentos, com o índice usado pelo resumo.
+	`CREATE TABLE payments (
+		processor      TEXT    NOT NULL,
+		correlation_id TEXT    NOT NULL,
+		amount_cents   INTEGER NOT NULL,
+		requested_at   INTEGER NOT NULL, -- Unix em nanossegundos
+		strategy       TEXT    NOT NULL DEFAULT '',
+		PRIMARY KEY (processor, correlation_id)
+	);
+	CREATE INDEX payments_processor_requested_at ON payments (processor, requested_at);`,
+
+	// 2: registro completo de cada pagamento, para a consulta por correlationId.
+	`CREATE TABLE payment_records (
+		correlation_id TEXT    PRIMARY KEY,
+		amount_cents   INTEGER NOT NULL,
+		status         TEXT    NOT NULL,
+		processor      TEXT    NOT NULL DEFAULT '',
+		strategy       TEXT    NOT NULL DEFAULT '',
+		requested_at   INTEGER,          -- Unix em nanossegundos; NULL enquanto não processado
+		attempts       INTEGER NOT NULL DEFAULT 0,
+		last_error     TEXT    NOT NULL DEFAULT '',
+		updated_at     INTEGER NOT NULL  -- Unix em nanossegundos
+	);`,
+
+	// 3: histórico de status de cada pagamento, na ordem de inserção.
+	`CREATE TABLE payment_transitions (
+		correlation_id TEXT    NOT NULL,
+		status         TEXT    NOT NULL,
+		at             INTEGER NOT NULL  -- Unix em nanossegundos
+	);
+	CREATE INDEX payment_transitions_correlation_id ON payment_transitions (correlation_id);`,
+}
+
+// Migrate cria a tabela de controle e aplica as migrações pendentes, cada uma na sua transação.
+func Migrate(ctx context.Context, db *sql.DB) error {
+	_, err := db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    INTEGER PRIMARY KEY,
+		applied_at TEXT    NOT NULL
+	)`)
+	if err != nil {
+		return fmt.Errorf("falha ao criar a tabela de migrações: %w", err)
+	}
+
+	var current int
+	if err := db.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&current); err != nil {
+		return fmt.Errorf("falha ao ler a versão do schema: %w", err)
+	}
+
+	for i := current; i < len(migrations); i++ {
+		version := i + 1
+		if err := applyMigration(ctx, db, version, migrations[i]); err != nil {
+			return fmt.Errorf("falha ao aplicar a migração %d: %w", version, err)
+		}
+		slog.Info("migração do SQLite aplicada", "version", version)
+	}
+
+	return nil
+}
+
+func applyMigration(ctx context.Context, db *sql.DB, version int, statements string) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, statements); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx,
+		`INSERT INTO schema_migrations (version, applied_at) VALUES (?, ?)`,
+		version, time.Now().UTC().Format(time.RFC3339),
+	); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
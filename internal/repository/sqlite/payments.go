@@ -0,0 +1,108 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/nicolasmmb/go-rinha-backend-2025/internal/core"
+	"github.com/nicolasmmb/go-rinha-backend-2025/internal/domain"
+)
+
+type paymentsSQLiteRepository struct {
+	db *sql.DB
+}
+
+// NewPaymentsRepository guarda os pagamentos num SQLite embutido, para ambientes de um único nó sem Valkey.
+// O banco precisa ter sido aberto com Open, que aplica as migrações.
+func NewPaymentsRepository(db *sql.DB) core.PaymentRepositoryInterface {
+	return &paymentsSQLiteRepository{db: db}
+}
+
+func (r *paymentsSQLiteRepository) SavePayment(ctx context.Context, payment *domain.Payment) (bool, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx,
+		`INSERT INTO payments (processor, correlation_id, amount_cents, requested_at, strategy)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (processor, correlation_id) DO NOTHING`,
+		payment.Processor, payment.CorrelationId, payment.Amount.Cents(), payment.RequestedAt.UnixNano(), payment.Strategy,
+	)
+	if err != nil {
+		return false, err
+	}
+
+	inserted, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	if inserted == 0 {
+		return false, nil
+	}
+
+	var transitionErr *domain.TransitionError
+	if err := transition(ctx, tx, payment, domain.PAYMENT_STATUS_SUCCEEDED); errors.As(err, &transitionErr) {
+		slog.Warn("pagamento gravado sem atualizar o registro", "correlationId", payment.CorrelationId, "error", err.Error())
+	} else if err != nil {
+		return false, err
+	}
+
+	return true, tx.Commit()
+}
+
+func (r *paymentsSQLiteRepository) GetSummaryByProcessor(ctx context.Context, typeOfProcessor string, from, to time.Time) (*domain.SummaryItem, error) {
+	var count, cents int64
+	err := r.db.QueryRowContext(ctx,
+		`SELECT COUNT(*), COALESCE(SUM(amount_cents), 0)
+		FROM payments
+		WHERE processor = ? AND requested_at BETWEEN ? AND ?`,
+		typeOfProcessor, from.UnixNano(), to.UnixNano(),
+	).Scan(&count, &cents)
+	if err != nil {
+		return nil, err
+	}
+
+	return &domain.SummaryItem{TotalRequests: count, TotalAmount: domain.Money(cents)}, nil
+}
+
+func (r *paymentsSQLiteRepository) GetRoutingStats(ctx context.Context) (map[string]map[string]domain.SummaryItem, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT strategy, processor, COUNT(*), SUM(amount_cents)
+		FROM payments
+		WHERE strategy <> ''
+		GROUP BY strategy, processor`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stats := map[string]map[string]domain.SummaryItem{}
+	for rows.Next() {
+		var (
+			strategy, processor string
+			count, cents        int64
+		)
+		if err := rows.Scan(&strategy, &processor, &count, &cents); err != nil {
+			return nil, err
+		}
+
+		if stats[strategy] == nil {
+			stats[strategy] = map[string]domain.SummaryItem{}
+		}
+		stats[strategy][processor] = domain.SummaryItem{TotalRequests: count, TotalAmount: domain.Money(cents)}
+	}
+
+	return stats, rows.Err()
+}
+
+func (r *paymentsSQLiteRepository) ResetState(ctx context.Context) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM payments; DELETE FROM payment_records; DELETE FROM payment_transitions;`)
+	return err
+}
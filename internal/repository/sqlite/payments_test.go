@@ -0,0 +1,19 @@
+package sqlite_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/nicolasmmb/go-rinha-backend-2025/internal/repository/repotest"
+	"github.com/nicolasmmb/go-rinha-backend-2025/internal/repository/sqlite"
+)
+
+func TestPaymentsRepository(t *testing.T) {
+	db, err := sqlite.Open(t.Context(), filepath.Join(t.TempDir(), "payments.db"))
+	if err != nil {
+		t.Fatalf("erro ao abrir o SQLite: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	repotest.TestPaymentRepository(t, sqlite.NewPaymentsRepository(db))
+}
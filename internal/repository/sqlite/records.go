@@ -0,0 +1,159 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/nicolasmmb/go-rinha-backend-2025/internal/domain"
+)
+
+// querier é atendido tanto por *sql.DB quanto por *sql.Tx.
+type querier interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// transition leva o registro do pagamento para status dentro da transação, que deve ter começado com a trava de escrita.
+func transition(ctx context.Context, tx *sql.Tx, payment *domain.Payment, status domain.PaymentStatus) error {
+	current, err := getRecord(ctx, tx, payment.CorrelationId)
+	if errors.Is(err, domain.ErrPaymentNotFound) {
+		current = nil
+	} else if err != nil {
+		return err
+	}
+
+	record, err := domain.ApplyTransition(current, payment, status, time.Now())
+	if err != nil {
+		return err
+	}
+
+	var requestedAt sql.NullInt64
+	if record.RequestedAt != nil {
+		requestedAt = sql.NullInt64{Int64: record.RequestedAt.UnixNano(), Valid: true}
+	}
+
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO payment_records
+			(correlation_id, amount_cents, status, processor, strategy, requested_at, attempts, last_error, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (correlation_id) DO UPDATE SET
+			amount_cents = excluded.amount_cents,
+			status       = excluded.status,
+			processor    = excluded.processor,
+			strategy     = excluded.strategy,
+			requested_at = excluded.requested_at,
+			attempts     = excluded.attempts,
+			last_error   = excluded.last_error,
+			updated_at   = excluded.updated_at`,
+		record.CorrelationId, record.Amount.Cents(), string(record.Status), record.Processor, record.Strategy,
+		requestedAt, record.Attempts, record.LastError, record.UpdatedAt.UnixNano(),
+	)
+	if err != nil {
+		return err
+	}
+
+	// Como em AppendTransition, o status atual não se repete no histórico: só o instante da última transição muda.
+	previous := 0
+	if current != nil {
+		previous = len(current.Transitions)
+		if previous > 0 && current.Transitions[previous-1].Status == status {
+			_, err = tx.ExecContext(ctx,
+				`UPDATE payment_transitions SET at = ? WHERE rowid = (
+					SELECT MAX(rowid) FROM payment_transitions WHERE correlation_id = ?
+				)`,
+				record.UpdatedAt.UnixNano(), record.CorrelationId,
+			)
+			return err
+		}
+	}
+
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO payment_transitions (correlation_id, status, at) VALUES (?, ?, ?)`,
+		record.CorrelationId, string(status), record.UpdatedAt.UnixNano(),
+	)
+	if err != nil || previous < domain.MAX_PAYMENT_TRANSITIONS {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx,
+		`DELETE FROM payment_transitions WHERE correlation_id = ? AND rowid NOT IN (
+			SELECT rowid FROM payment_transitions WHERE correlation_id = ? ORDER BY rowid DESC LIMIT ?
+		)`,
+		record.CorrelationId, record.CorrelationId, domain.MAX_PAYMENT_TRANSITIONS,
+	)
+	return err
+}
+
+func (r *paymentsSQLiteRepository) TransitionPayment(ctx context.Context, payment *domain.Payment, status domain.PaymentStatus) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := transition(ctx, tx, payment, status); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *paymentsSQLiteRepository) GetPaymentRecord(ctx context.Context, correlationId string) (*domain.PaymentRecord, error) {
+	return getRecord(ctx, r.db, correlationId)
+}
+
+func getRecord(ctx context.Context, db querier, correlationId string) (*domain.PaymentRecord, error) {
+	var (
+		record      = &domain.PaymentRecord{CorrelationId: correlationId}
+		cents       int64
+		status      string
+		requestedAt sql.NullInt64
+		updatedAt   int64
+	)
+
+	err := db.QueryRowContext(ctx,
+		`SELECT amount_cents, status, processor, strategy, requested_at, attempts, last_error, updated_at
+		FROM payment_records
+		WHERE correlation_id = ?`,
+		correlationId,
+	).Scan(&cents, &status, &record.Processor, &record.Strategy, &requestedAt, &record.Attempts, &record.LastError, &updatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, domain.ErrPaymentNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	record.Amount = domain.Money(cents)
+	record.Status = domain.PaymentStatus(status)
+	record.UpdatedAt = time.Unix(0, updatedAt)
+	if requestedAt.Valid {
+		at := time.Unix(0, requestedAt.Int64)
+		record.RequestedAt = &at
+	}
+
+	rows, err := db.QueryContext(ctx,
+		`SELECT status, at FROM payment_transitions WHERE correlation_id = ? ORDER BY rowid`,
+		correlationId,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			transition domain.PaymentTransition
+			at         int64
+		)
+		if err := rows.Scan(&transition.Status, &at); err != nil {
+			return nil, err
+		}
+		transition.At = time.Unix(0, at)
+		record.Transitions = append(record.Transitions, transition)
+	}
+
+	return record, rows.Err()
+}
@@ -0,0 +1,36 @@
+package router
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+)
+
+// requireAdminToken libera o handler apenas para requisições com o cabeçalho "Authorization: Bearer <token>".
+// Sem ADMIN_TOKEN configurado a rota fica desabilitada.
+func requireAdminToken(token string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if token == "" {
+			writeProblem(w, r, problem{
+				Type:   PROBLEM_FORBIDDEN,
+				Title:  "Admin route disabled",
+				Status: http.StatusForbidden,
+				Detail: "Set ADMIN_TOKEN to enable this route",
+			})
+			return
+		}
+
+		given, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+			writeProblem(w, r, problem{
+				Type:   PROBLEM_UNAUTHORIZED,
+				Title:  "Invalid admin token",
+				Status: http.StatusUnauthorized,
+			})
+			return
+		}
+
+		next(w, r)
+	}
+}
@@ -0,0 +1,125 @@
+package router
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	json "github.com/json-iterator/go"
+
+	"github.com/nicolasmmb/go-rinha-backend-2025/internal/domain"
+)
+
+const (
+	DEAD_LETTERS_DEFAULT_LIMIT = 100
+	DEAD_LETTERS_MAX_LIMIT     = 1000
+)
+
+// queryInt lê um inteiro não negativo da query string, usando o valor padrão quando ausente ou inválido.
+func queryInt(r *http.Request, name string, defaultValue int) int {
+	value, err := strconv.Atoi(r.URL.Query().Get(name))
+	if err != nil || value < 0 {
+		return defaultValue
+	}
+	return value
+}
+
+// queryLimit lê o limite de itens da página: ausente, inválido ou não positivo vira o padrão, e nunca passa do máximo.
+func queryLimit(r *http.Request) int {
+	limit := queryInt(r, "limit", DEAD_LETTERS_DEFAULT_LIMIT)
+	if limit <= 0 {
+		limit = DEAD_LETTERS_DEFAULT_LIMIT
+	}
+	return min(limit, DEAD_LETTERS_MAX_LIMIT)
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
+
+func (h *paymentHandler) ListDeadLetters(w http.ResponseWriter, r *http.Request) {
+	offset := queryInt(r, "offset", 0)
+	limit := queryLimit(r)
+
+	deadLetters, total, err := h.Svc.ListDeadLetters(r.Context(), offset, limit)
+	if err != nil {
+		http.Error(w, "Failed to list dead letters", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"total":       total,
+		"offset":      offset,
+		"limit":       limit,
+		"deadLetters": deadLetters,
+	})
+}
+
+func (h *paymentHandler) GetDeadLetter(w http.ResponseWriter, r *http.Request) {
+	deadLetter, err := h.Svc.GetDeadLetter(r.Context(), r.PathValue("correlationId"))
+	if errors.Is(err, domain.ErrDeadLetterNotFound) {
+		http.Error(w, "Dead letter not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to get dead letter", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, deadLetter)
+}
+
+func (h *paymentHandler) ReplayDeadLetter(w http.ResponseWriter, r *http.Request) {
+	err := h.Svc.ReplayDeadLetter(r.Context(), r.PathValue("correlationId"))
+	if errors.Is(err, domain.ErrDeadLetterNotFound) {
+		http.Error(w, "Dead letter not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to replay dead letter", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusAccepted, map[string]int{"replayed": 1})
+}
+
+func (h *paymentHandler) ReplayDeadLetters(w http.ResponseWriter, r *http.Request) {
+	limit := queryLimit(r)
+
+	replayed, err := h.Svc.ReplayDeadLetters(r.Context(), limit)
+	if err != nil {
+		http.Error(w, "Failed to replay dead letters", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusAccepted, map[string]int{"replayed": replayed})
+}
+
+func (h *paymentHandler) DeleteDeadLetter(w http.ResponseWriter, r *http.Request) {
+	err := h.Svc.DeleteDeadLetter(r.Context(), r.PathValue("correlationId"))
+	if errors.Is(err, domain.ErrDeadLetterNotFound) {
+		http.Error(w, "Dead letter not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to delete dead letter", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *paymentHandler) PurgeDeadLetters(w http.ResponseWriter, r *http.Request) {
+	purged, err := h.Svc.PurgeDeadLetters(r.Context())
+	if err != nil {
+		http.Error(w, "Failed to purge dead letters", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]int64{"purged": purged})
+}
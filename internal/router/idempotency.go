@@ -0,0 +1,68 @@
+package router
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+
+	"github.com/nicolasmmb/go-rinha-backend-2025/internal/domain"
+)
+
+const (
+	HEADER_IDEMPOTENCY_KEY = "Idempotency-Key"
+	// Marca as respostas repetidas de uma requisição anterior com a mesma chave.
+	HEADER_IDEMPOTENT_REPLAYED = "Idempotent-Replayed"
+
+	// Tamanho máximo aceito para a Idempotency-Key.
+	MAX_IDEMPOTENCY_KEY_LENGTH = 255
+	// Maior corpo de resposta guardado para ser repetido; de um maior, só o status é repetido.
+	MAX_IDEMPOTENT_BODY_BYTES = 512
+)
+
+// responseRecorder repassa a resposta ao cliente e guarda uma cópia, para repeti-la nas próximas requisições com a mesma chave.
+// Do corpo guarda no máximo MAX_IDEMPOTENT_BODY_BYTES; acima disso o descarta.
+type responseRecorder struct {
+	http.ResponseWriter
+	status    int
+	body      bytes.Buffer
+	truncated bool
+}
+
+func (rr *responseRecorder) WriteHeader(status int) {
+	rr.status = status
+	rr.ResponseWriter.WriteHeader(status)
+}
+
+func (rr *responseRecorder) Write(b []byte) (int, error) {
+	if rr.status == 0 {
+		rr.status = http.StatusOK
+	}
+	if rr.body.Len()+len(b) <= MAX_IDEMPOTENT_BODY_BYTES {
+		rr.body.Write(b)
+	} else {
+		rr.truncated = true
+	}
+	return rr.ResponseWriter.Write(b)
+}
+
+func (rr *responseRecorder) response() *domain.IdempotentResponse {
+	status := rr.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	response := &domain.IdempotentResponse{Status: status}
+	if !rr.truncated {
+		response.ContentType = rr.Header().Get("Content-Type")
+		response.Body = rr.body.String()
+	}
+	return response
+}
+
+func writeReplay(w http.ResponseWriter, response *domain.IdempotentResponse) {
+	if response.ContentType != "" {
+		w.Header().Set("Content-Type", response.ContentType)
+	}
+	w.Header().Set(HEADER_IDEMPOTENT_REPLAYED, "true")
+	w.WriteHeader(response.Status)
+	io.WriteString(w, response.Body)
+}
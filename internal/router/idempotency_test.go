@@ -0,0 +1,137 @@
+package router
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nicolasmmb/go-rinha-backend-2025/internal/core"
+	"github.com/nicolasmmb/go-rinha-backend-2025/internal/repository/memory"
+	"github.com/nicolasmmb/go-rinha-backend-2025/internal/service"
+)
+
+const (
+	testPayment      = `{"correlationId":"4a7901b8-7d26-4d9d-aa19-4dc1c7cf60b3","amount":19.9}`
+	testOtherPayment = `{"correlationId":"4a7901b8-7d26-4d9d-aa19-4dc1c7cf60b3","amount":29.9}`
+)
+
+// newIdempotencyTestServer sobe as rotas com repositórios em memória e a janela de Idempotency-Key informada.
+func newIdempotencyTestServer(t *testing.T, window time.Duration) (*httptest.Server, core.PaymentQueueInterface) {
+	t.Helper()
+
+	queue := service.NewChannelQueue(10)
+	svc := service.NewPaymentService(service.PaymentServiceOptions{
+		PaymentRepository:     memory.NewPaymentsRepository(),
+		IdempotencyRepository: memory.NewIdempotencyRepository(),
+		IdempotencyWindow:     window,
+		Queue:                 queue,
+	})
+
+	server := httptest.NewServer(Routes(NewPaymentHandler(svc), ""))
+	t.Cleanup(server.Close)
+	return server, queue
+}
+
+func postPayment(t *testing.T, server *httptest.Server, key, body string) *http.Response {
+	t.Helper()
+
+	req, err := http.NewRequestWithContext(t.Context(), http.MethodPost, server.URL+"/payments", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if key != "" {
+		req.Header.Set(HEADER_IDEMPOTENCY_KEY, key)
+	}
+
+	resp, err := server.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	return resp
+}
+
+func TestSavePaymentIdempotency(t *testing.T) {
+	type request struct {
+		key  string
+		body string
+		// Status e cabeçalho Idempotent-Replayed esperados.
+		status   int
+		replayed bool
+	}
+
+	tests := []struct {
+		name     string
+		window   time.Duration
+		requests []request
+		// Pagamentos que devem chegar à fila.
+		queued int64
+	}{
+		{
+			name:     "primeira requisição",
+			window:   time.Minute,
+			requests: []request{{key: "k1", body: testPayment, status: http.StatusCreated}},
+			queued:   1,
+		},
+		{
+			name:   "repetição com a mesma chave e o mesmo corpo",
+			window: time.Minute,
+			requests: []request{
+				{key: "k1", body: testPayment, status: http.StatusCreated},
+				{key: "k1", body: testPayment, status: http.StatusCreated, replayed: true},
+			},
+			queued: 1,
+		},
+		{
+			name:   "repetição sem chave usa o correlationId",
+			window: time.Minute,
+			requests: []request{
+				{body: testPayment, status: http.StatusCreated},
+				{body: testPayment, status: http.StatusCreated, replayed: true},
+			},
+			queued: 1,
+		},
+		{
+			name:   "mesma chave com outro corpo",
+			window: time.Minute,
+			requests: []request{
+				{key: "k1", body: testPayment, status: http.StatusCreated},
+				{key: "k1", body: testOtherPayment, status: http.StatusConflict},
+			},
+			queued: 1,
+		},
+		{
+			name:   "desativado sem janela",
+			window: 0,
+			requests: []request{
+				{key: "k1", body: testPayment, status: http.StatusCreated},
+				{key: "k1", body: testPayment, status: http.StatusCreated},
+				{key: "k1", body: testOtherPayment, status: http.StatusCreated},
+			},
+			queued: 3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, queue := newIdempotencyTestServer(t, tt.window)
+
+			for i, req := range tt.requests {
+				resp := postPayment(t, server, req.key, req.body)
+				if resp.StatusCode != req.status {
+					t.Fatalf("requisição %d: status %d, esperava %d", i, resp.StatusCode, req.status)
+				}
+				if replayed := resp.Header.Get(HEADER_IDEMPOTENT_REPLAYED) == "true"; replayed != req.replayed {
+					t.Fatalf("requisição %d: %s = %v, esperava %v", i, HEADER_IDEMPOTENT_REPLAYED, replayed, req.replayed)
+				}
+			}
+
+			if queued, err := queue.Len(t.Context()); err != nil || queued != tt.queued {
+				t.Fatalf("%d pagamentos na fila (erro %v), esperava %d", queued, err, tt.queued)
+			}
+		})
+	}
+}
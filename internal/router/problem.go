@@ -0,0 +1,44 @@
+package router
+
+import (
+	"net/http"
+
+	json "github.com/json-iterator/go"
+
+	"github.com/nicolasmmb/go-rinha-backend-2025/internal/validation"
+)
+
+const (
+	PROBLEM_CONTENT_TYPE = "application/problem+json"
+
+	PROBLEM_VALIDATION     = "/problems/validation-error"
+	PROBLEM_MALFORMED_BODY = "/problems/malformed-body"
+	PROBLEM_BODY_TOO_LARGE = "/problems/body-too-large"
+	PROBLEM_QUEUE_FULL     = "/problems/queue-full"
+	PROBLEM_UNAVAILABLE    = "/problems/service-unavailable"
+	PROBLEM_NOT_FOUND      = "/problems/not-found"
+	PROBLEM_UNAUTHORIZED   = "/problems/unauthorized"
+	PROBLEM_FORBIDDEN      = "/problems/forbidden"
+	PROBLEM_SUMMARY_WINDOW = "/problems/summary-window-too-large"
+
+	PROBLEM_IDEMPOTENCY_CONFLICT  = "/problems/idempotency-key-reused"
+	PROBLEM_IDEMPOTENCY_IN_FLIGHT = "/problems/idempotency-request-in-progress"
+)
+
+// problem é uma resposta de erro no formato da RFC 7807.
+type problem struct {
+	Type     string                  `json:"type"`
+	Title    string                  `json:"title"`
+	Status   int                     `json:"status"`
+	Detail   string                  `json:"detail,omitempty"`
+	Instance string                  `json:"instance,omitempty"`
+	Errors   []validation.FieldError `json:"errors,omitempty"`
+}
+
+func writeProblem(w http.ResponseWriter, r *http.Request, p problem) {
+	p.Instance = r.URL.Path
+
+	w.Header().Set("Content-Type", PROBLEM_CONTENT_TYPE)
+	w.WriteHeader(p.Status)
+	json.NewEncoder(w).Encode(p)
+}
@@ -1,21 +1,37 @@
 package router
 
 import (
+	"errors"
 	"log/slog"
 	"net/http"
+	"strconv"
 	"time"
 
 	json "github.com/json-iterator/go"
 
+	"github.com/nicolasmmb/go-rinha-backend-2025/internal/core"
 	"github.com/nicolasmmb/go-rinha-backend-2025/internal/domain"
 	"github.com/nicolasmmb/go-rinha-backend-2025/internal/service"
+	"github.com/nicolasmmb/go-rinha-backend-2025/internal/validation"
 )
 
 const (
 	ROUTE_PAYMENT_SUMMARY = "GET /payments-summary"
 	ROUTE_PAYMENT_SAVE    = "POST /payments"
+	ROUTE_PAYMENT_GET     = "GET /payments/{correlationId}"
 	ROUTE_HEALTH_CHECK    = "GET /health"
-	ROUTE_RESET_PAYMENTS  = "GET /reset"
+	ROUTE_RESET_PAYMENTS  = "POST /admin/reset"
+	ROUTE_ROUTING_STATS   = "GET /admin/routing-stats"
+	ROUTE_BREAKERS        = "GET /admin/circuit-breakers"
+	ROUTE_OUTBOX          = "GET /admin/outbox"
+	ROUTE_CLAIMS          = "GET /admin/claims"
+
+	ROUTE_DEAD_LETTERS_LIST   = "GET /admin/dead-letters"
+	ROUTE_DEAD_LETTERS_GET    = "GET /admin/dead-letters/{correlationId}"
+	ROUTE_DEAD_LETTERS_REPLAY = "POST /admin/dead-letters/{correlationId}/replay"
+	ROUTE_DEAD_LETTERS_BULK   = "POST /admin/dead-letters/replay"
+	ROUTE_DEAD_LETTERS_DELETE = "DELETE /admin/dead-letters/{correlationId}"
+	ROUTE_DEAD_LETTERS_PURGE  = "DELETE /admin/dead-letters"
 )
 
 type paymentHandler struct {
@@ -26,21 +42,143 @@ func NewPaymentHandler(svc *service.PaymentService) *paymentHandler {
 	return &paymentHandler{Svc: svc}
 }
 
+// SavePayment admite o pagamento na fila. Com o cabeçalho Idempotency-Key, ou pelo correlationId quando ele falta,
+// a primeira resposta é gravada e repetida para as requisições seguintes com a mesma chave e o mesmo corpo.
 func (h *paymentHandler) SavePayment(w http.ResponseWriter, r *http.Request) {
 
-	var payment *domain.Payment
+	body, err := validation.ReadBody(r.Body, validation.MAX_PAYMENT_BODY_BYTES)
+	if errors.Is(err, validation.ErrBodyTooLarge) {
+		writeProblem(w, r, problem{
+			Type:   PROBLEM_BODY_TOO_LARGE,
+			Title:  "Request body too large",
+			Status: http.StatusRequestEntityTooLarge,
+			Detail: "The payment body must have at most " + strconv.Itoa(validation.MAX_PAYMENT_BODY_BYTES) + " bytes",
+		})
+		return
+	}
+	if err != nil {
+		writeProblem(w, r, problem{Type: PROBLEM_MALFORMED_BODY, Title: "Invalid request body", Status: http.StatusBadRequest})
+		return
+	}
+
+	payment, decodeErr := validation.DecodePayment(body)
+
+	key := r.Header.Get(HEADER_IDEMPOTENCY_KEY)
+	if len(key) > MAX_IDEMPOTENCY_KEY_LENGTH {
+		writeProblem(w, r, problem{
+			Type:   PROBLEM_VALIDATION,
+			Title:  "Invalid Idempotency-Key",
+			Status: http.StatusBadRequest,
+			Detail: "The Idempotency-Key header must have at most " + strconv.Itoa(MAX_IDEMPOTENCY_KEY_LENGTH) + " characters",
+		})
+		return
+	}
+	if key == "" && decodeErr == nil {
+		key = payment.CorrelationId
+	}
+	if key == "" {
+		h.acceptPayment(w, r, payment, decodeErr)
+		return
+	}
 
-	if err := json.NewDecoder(r.Body).Decode(&payment); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	replay, err := h.Svc.BeginIdempotentRequest(r.Context(), key, body)
+	switch {
+	case errors.Is(err, service.ErrIdempotencyKeyReused):
+		writeProblem(w, r, problem{
+			Type:   PROBLEM_IDEMPOTENCY_CONFLICT,
+			Title:  "Idempotency-Key reused",
+			Status: http.StatusConflict,
+			Detail: "The key " + key + " was already used with a different body",
+		})
+		return
+	case errors.Is(err, service.ErrIdempotencyKeyInFlight):
+		w.Header().Set("Retry-After", "1")
+		writeProblem(w, r, problem{
+			Type:   PROBLEM_IDEMPOTENCY_IN_FLIGHT,
+			Title:  "Request with the same Idempotency-Key in progress",
+			Status: http.StatusConflict,
+			Detail: "Retry after the first request with the key " + key + " is answered",
+		})
+		return
+	case replay != nil:
+		writeReplay(w, replay)
 		return
 	}
-	go func(){
-		h.Svc.SendPaymentToQueue(payment)
-	}()
+
+	recorder := &responseRecorder{ResponseWriter: w}
+	h.acceptPayment(recorder, r, payment, decodeErr)
+	h.Svc.FinishIdempotentRequest(r.Context(), key, body, recorder.response())
+}
+
+// acceptPayment responde à validação do corpo e, se ele for válido, enfileira o pagamento.
+func (h *paymentHandler) acceptPayment(w http.ResponseWriter, r *http.Request, payment *domain.Payment, err error) {
+	if err != nil {
+		var fieldErrors validation.FieldErrors
+		if errors.As(err, &fieldErrors) {
+			writeProblem(w, r, problem{
+				Type:   PROBLEM_VALIDATION,
+				Title:  "Invalid payment",
+				Status: http.StatusUnprocessableEntity,
+				Detail: "One or more fields are invalid",
+				Errors: fieldErrors,
+			})
+			return
+		}
+
+		writeProblem(w, r, problem{
+			Type:   PROBLEM_MALFORMED_BODY,
+			Title:  "Invalid request body",
+			Status: http.StatusBadRequest,
+			Detail: "The body must be a JSON object",
+		})
+		return
+	}
+
+	// A admissão é síncrona: com a fila cheia o cliente recebe 503 e um Retry-After baseado na vazão atual.
+	if err := h.Svc.AcceptPayment(r.Context(), payment); err != nil {
+		if errors.Is(err, core.ErrQueueFull) || errors.Is(err, core.ErrQueueClosed) {
+			retryAfter := h.Svc.RetryAfter()
+			w.Header().Set("Retry-After", strconv.Itoa(int(retryAfter.Seconds())))
+			writeProblem(w, r, problem{
+				Type:   PROBLEM_QUEUE_FULL,
+				Title:  "Payment queue is full",
+				Status: http.StatusServiceUnavailable,
+				Detail: "Retry after " + strconv.Itoa(int(retryAfter.Seconds())) + " seconds",
+			})
+			return
+		}
+
+		slog.Error("falha ao enfileirar pagamento", "error", err.Error())
+		writeProblem(w, r, problem{Type: PROBLEM_UNAVAILABLE, Title: "Failed to enqueue payment", Status: http.StatusServiceUnavailable})
+		return
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
 }
 
+func (h *paymentHandler) GetPayment(w http.ResponseWriter, r *http.Request) {
+	correlationId := r.PathValue("correlationId")
+
+	record, err := h.Svc.GetPayment(r.Context(), correlationId)
+	if errors.Is(err, domain.ErrPaymentNotFound) {
+		writeProblem(w, r, problem{
+			Type:   PROBLEM_NOT_FOUND,
+			Title:  "Payment not found",
+			Status: http.StatusNotFound,
+			Detail: "No payment was accepted with correlationId " + correlationId,
+		})
+		return
+	}
+	if err != nil {
+		slog.Error("falha ao consultar pagamento", "correlationId", correlationId, "error", err.Error())
+		writeProblem(w, r, problem{Type: PROBLEM_UNAVAILABLE, Title: "Failed to get payment", Status: http.StatusServiceUnavailable})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, record)
+}
+
 func (h *paymentHandler) GetSummary(w http.ResponseWriter, r *http.Request) {
 	fromQuery := r.URL.Query().Get("from")
 	toQuery := r.URL.Query().Get("to")
@@ -49,6 +187,10 @@ func (h *paymentHandler) GetSummary(w http.ResponseWriter, r *http.Request) {
 	to, _ := time.Parse(time.RFC3339, toQuery)
 
 	summary, err := h.Svc.GetSummary(r.Context(), from, to)
+	if errors.Is(err, service.ErrSummaryWindowTooLarge) {
+		writeProblem(w, r, problem{Type: PROBLEM_SUMMARY_WINDOW, Title: "Summary window too large", Status: http.StatusUnprocessableEntity, Detail: err.Error()})
+		return
+	}
 	if err != nil {
 		http.Error(w, "Failed to get summary", http.StatusInternalServerError)
 		return
@@ -64,28 +206,83 @@ func (h *paymentHandler) GetSummary(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *paymentHandler) ResetPayments(w http.ResponseWriter, r *http.Request) {
-	if err := h.Svc.ResetState(r.Context()); err != nil {
-		http.Error(w, "Failed to reset payments", http.StatusInternalServerError)
+	report, err := h.Svc.ResetState(r.Context())
+	if err != nil {
+		slog.Error("falha ao resetar os pagamentos", "error", err.Error())
+		writeProblem(w, r, problem{Type: PROBLEM_UNAVAILABLE, Title: "Failed to reset payments", Status: http.StatusServiceUnavailable})
+		return
+	}
+
+	slog.Info("Payments reset successfully", "queued", report.Queued, "deadLetters", report.DeadLetters, "parked", report.Parked, "outbox", report.Outbox)
+	writeJSON(w, http.StatusOK, map[string]any{
+		"message":   "Payments reset successfully",
+		"discarded": report,
+	})
+}
+
+func (h *paymentHandler) GetRoutingStats(w http.ResponseWriter, r *http.Request) {
+	stats, err := h.Svc.GetRoutingStats(r.Context())
+	if err != nil {
+		http.Error(w, "Failed to get routing stats", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(stats); err != nil {
+		http.Error(w, "Failed to encode routing stats", http.StatusInternalServerError)
 		return
 	}
+}
 
+func (h *paymentHandler) GetCircuitBreakers(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
-	response := map[string]string{"message": "Payments reset successfully"}
-	if err := json.NewEncoder(w).Encode(response); err != nil {
-		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+
+	if err := json.NewEncoder(w).Encode(h.Svc.GetCircuitBreakers()); err != nil {
+		http.Error(w, "Failed to encode circuit breakers", http.StatusInternalServerError)
 		return
 	}
-	slog.Info("Payments reset successfully")
+}
 
+func (h *paymentHandler) GetOutbox(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, h.Svc.GetOutboxStatus())
 }
 
-func Routes(handler *paymentHandler) *http.ServeMux {
+func (h *paymentHandler) GetClaimStats(w http.ResponseWriter, r *http.Request) {
+	stats, err := h.Svc.GetClaimStats(r.Context())
+	if err != nil {
+		slog.Error("falha ao consultar as reservas de pagamento", "error", err.Error())
+		writeProblem(w, r, problem{Type: PROBLEM_UNAVAILABLE, Title: "Failed to get claim stats", Status: http.StatusServiceUnavailable})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"duplicates": stats})
+}
+
+// Routes registra as rotas da API. adminToken protege todas as rotas /admin: as que alteram o estado e as que
+// expõem pagamentos e a operação interna.
+func Routes(handler *paymentHandler, adminToken string) *http.ServeMux {
+	admin := func(next http.HandlerFunc) http.HandlerFunc {
+		return requireAdminToken(adminToken, next)
+	}
+
 	mux := http.NewServeMux()
 	mux.HandleFunc(ROUTE_PAYMENT_SAVE, handler.SavePayment)
+	mux.HandleFunc(ROUTE_PAYMENT_GET, handler.GetPayment)
 	mux.HandleFunc(ROUTE_PAYMENT_SUMMARY, handler.GetSummary)
 	mux.HandleFunc(ROUTE_HEALTH_CHECK, handler.HealthCheck)
-	mux.HandleFunc(ROUTE_RESET_PAYMENTS, handler.ResetPayments)
+	mux.HandleFunc(ROUTE_RESET_PAYMENTS, admin(handler.ResetPayments))
+	mux.HandleFunc(ROUTE_ROUTING_STATS, admin(handler.GetRoutingStats))
+	mux.HandleFunc(ROUTE_BREAKERS, admin(handler.GetCircuitBreakers))
+	mux.HandleFunc(ROUTE_OUTBOX, admin(handler.GetOutbox))
+	mux.HandleFunc(ROUTE_CLAIMS, admin(handler.GetClaimStats))
+	mux.HandleFunc(ROUTE_DEAD_LETTERS_LIST, admin(handler.ListDeadLetters))
+	mux.HandleFunc(ROUTE_DEAD_LETTERS_GET, admin(handler.GetDeadLetter))
+	mux.HandleFunc(ROUTE_DEAD_LETTERS_REPLAY, admin(handler.ReplayDeadLetter))
+	mux.HandleFunc(ROUTE_DEAD_LETTERS_BULK, admin(handler.ReplayDeadLetters))
+	mux.HandleFunc(ROUTE_DEAD_LETTERS_DELETE, admin(handler.DeleteDeadLetter))
+	mux.HandleFunc(ROUTE_DEAD_LETTERS_PURGE, admin(handler.PurgeDeadLetters))
 
 	return mux
 
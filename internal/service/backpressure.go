@@ -0,0 +1,76 @@
+package service
+
+import (
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	MIN_RETRY_AFTER = 1 * time.Second
+	MAX_RETRY_AFTER = 5 * time.Second
+
+	// Janela da média móvel da vazão: medições mais antigas que isso pesam pouco na taxa.
+	DRAIN_RATE_WINDOW = 5 * time.Second
+)
+
+// drainMeter mede a vazão da fila (pagamentos retirados por segundo) com uma média móvel exponencial no tempo.
+// A taxa é recalculada sob demanda, no máximo uma vez por segundo; intervalos sem retiradas contam como vazão zero,
+// então a taxa cai sozinha quando os workers param.
+type drainMeter struct {
+	drained atomic.Int64
+	// Relógio da medição; nil usa time.Now.
+	now func() time.Time
+
+	mu        sync.Mutex
+	lastAt    time.Time
+	lastCount int64
+	rate      float64
+}
+
+func (m *drainMeter) Mark() {
+	m.drained.Add(1)
+}
+
+func (m *drainMeter) Rate() float64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	if m.now != nil {
+		now = m.now()
+	}
+	count := m.drained.Load()
+
+	if m.lastAt.IsZero() {
+		m.lastAt, m.lastCount = now, count
+		return m.rate
+	}
+
+	elapsed := now.Sub(m.lastAt)
+	if elapsed < time.Second {
+		return m.rate
+	}
+
+	// O peso da taxa anterior depende do tempo decorrido, e não do número de leituras.
+	current := float64(count-m.lastCount) / elapsed.Seconds()
+	weight := math.Exp(-elapsed.Seconds() / DRAIN_RATE_WINDOW.Seconds())
+	m.rate = weight*m.rate + (1-weight)*current
+	m.lastAt, m.lastCount = now, count
+
+	return m.rate
+}
+
+// RetryAfter estima em quanto tempo uma vaga abre na fila cheia: o intervalo médio entre duas retiradas.
+// Sem vazão medida (workers parados) retorna o máximo, que é curto para o cliente voltar a verificar logo.
+func (ps *PaymentService) RetryAfter() time.Duration {
+	rate := ps.drainMeter.Rate()
+	if rate <= 0 {
+		return MAX_RETRY_AFTER
+	}
+
+	// Limita em segundos antes de converter, para uma taxa quase zero não estourar o time.Duration.
+	wait := min(math.Ceil(1/rate), MAX_RETRY_AFTER.Seconds())
+	return max(time.Duration(wait)*time.Second, MIN_RETRY_AFTER)
+}
@@ -0,0 +1,111 @@
+package service
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// testClock é um relógio que só anda quando o teste manda.
+type testClock struct {
+	at time.Time
+}
+
+func (c *testClock) now() time.Time { return c.at }
+
+func (c *testClock) advance(d time.Duration) { c.at = c.at.Add(d) }
+
+func TestDrainMeterDecay(t *testing.T) {
+	clock := &testClock{at: time.Date(2025, 7, 15, 12, 0, 0, 0, time.UTC)}
+	meter := &drainMeter{now: clock.now}
+
+	// A primeira leitura só marca o início da medição.
+	if rate := meter.Rate(); rate != 0 {
+		t.Fatalf("taxa inicial = %v, esperava 0", rate)
+	}
+
+	// 100 retiradas em 1s: a taxa vai para 100/s com o peso do tempo decorrido.
+	for range 100 {
+		meter.Mark()
+	}
+	clock.advance(time.Second)
+	weight := math.Exp(-1 / DRAIN_RATE_WINDOW.Seconds())
+	want := (1 - weight) * 100
+	if rate := meter.Rate(); math.Abs(rate-want) > 1e-9 {
+		t.Fatalf("taxa depois de 1s = %v, esperava %v", rate, want)
+	}
+
+	// Antes de 1s a taxa não é recalculada, mesmo com novas retiradas.
+	meter.Mark()
+	clock.advance(500 * time.Millisecond)
+	if rate := meter.Rate(); math.Abs(rate-want) > 1e-9 {
+		t.Fatalf("taxa recalculada antes de 1s: %v, esperava %v", rate, want)
+	}
+
+	// Sem retiradas a taxa cai sozinha, e o peso depende do tempo, não do número de leituras.
+	idle := &drainMeter{now: clock.now}
+	idle.Rate()
+	idle.rate = 100
+	clock.advance(10 * time.Second)
+	decayed := idle.Rate()
+	if want := 100 * math.Exp(-10/DRAIN_RATE_WINDOW.Seconds()); math.Abs(decayed-want) > 1e-9 {
+		t.Fatalf("taxa depois de 10s parada = %v, esperava %v", decayed, want)
+	}
+
+	stepped := &drainMeter{now: clock.now}
+	stepped.Rate()
+	stepped.rate = 100
+	for range 10 {
+		clock.advance(time.Second)
+		stepped.Rate()
+	}
+	if math.Abs(stepped.rate-decayed) > 1e-9 {
+		t.Fatalf("taxa lida a cada segundo = %v, esperava a mesma de uma leitura só (%v)", stepped.rate, decayed)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	tests := []struct {
+		name string
+		// Retiradas a cada intervalo, durante a medição.
+		drains   int
+		interval time.Duration
+		measured time.Duration
+		// Tempo sem retiradas depois da medição.
+		idle time.Duration
+		want time.Duration
+	}{
+		{name: "fila vazia, sem medição", want: MAX_RETRY_AFTER},
+		{name: "fila parcialmente cheia com workers parados", interval: time.Second, measured: 30 * time.Second, want: MAX_RETRY_AFTER},
+		// 0,4 retiradas por segundo: uma vaga abre a cada 2,5s.
+		{name: "fila parcialmente cheia drenando devagar", drains: 2, interval: 5 * time.Second, measured: 2 * time.Minute, want: 3 * time.Second},
+		{name: "fila saturada drenando rápido", drains: 500, interval: time.Second, measured: time.Minute, want: MIN_RETRY_AFTER},
+		// Uma retirada num único segundo, depois 30s parada: a taxa cai abaixo de uma a cada 5s.
+		{name: "fila saturada depois que os workers param", drains: 1, interval: time.Second, measured: time.Second, idle: 30 * time.Second, want: MAX_RETRY_AFTER},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := &testClock{at: time.Date(2025, 7, 15, 12, 0, 0, 0, time.UTC)}
+			ps := &PaymentService{}
+			ps.drainMeter.now = clock.now
+
+			ps.drainMeter.Rate()
+			for elapsed := time.Duration(0); elapsed < tt.measured; elapsed += tt.interval {
+				for range tt.drains {
+					ps.drainMeter.Mark()
+				}
+				clock.advance(tt.interval)
+				ps.drainMeter.Rate()
+			}
+			if tt.idle > 0 {
+				clock.advance(tt.idle)
+				ps.drainMeter.Rate()
+			}
+
+			if got := ps.RetryAfter(); got != tt.want {
+				t.Fatalf("RetryAfter = %s (taxa %v), esperava %s", got, ps.drainMeter.rate, tt.want)
+			}
+		})
+	}
+}
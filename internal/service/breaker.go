@@ -0,0 +1,238 @@
+package service
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+)
+
+type BreakerState string
+
+const (
+	BREAKER_CLOSED    BreakerState = "closed"
+	BREAKER_OPEN      BreakerState = "open"
+	BREAKER_HALF_OPEN BreakerState = "half-open"
+)
+
+type BreakerConfig struct {
+	// Falhas consecutivas necessárias para abrir o circuito.
+	FailureThreshold int
+	// Tempo que o circuito fica aberto antes de liberar as sondas.
+	OpenDuration time.Duration
+	// Sondas simultâneas permitidas no estado meio-aberto; todas precisam ter sucesso para fechar o circuito.
+	HalfOpenProbes int
+}
+
+// BreakerStatus é a visão pública do estado de um circuit breaker.
+type BreakerStatus struct {
+	Processor           string       `json:"processor"`
+	State               BreakerState `json:"state"`
+	ConsecutiveFailures int          `json:"consecutiveFailures"`
+	ChangedAt           time.Time    `json:"changedAt"`
+	OpenUntil           *time.Time   `json:"openUntil,omitempty"`
+}
+
+// CircuitBreaker protege um processador: após muitas falhas seguidas o circuito abre
+// e os pagamentos deixam de ser enviados a ele até que as sondas confirmem a recuperação.
+type CircuitBreaker struct {
+	processor string
+	cfg       BreakerConfig
+	now       func() time.Time
+
+	mu             sync.Mutex
+	state          BreakerState
+	failures       int
+	changedAt      time.Time
+	probesInFlight int
+	probeSuccesses int
+	// Muda a cada transição, para que chamadas liberadas em um estado anterior não contem no atual.
+	generation uint64
+}
+
+// BreakerToken registra em que estado uma chamada foi liberada por Allow e deve ser devolvido
+// em OnSuccess, OnFailure ou Release.
+type BreakerToken struct {
+	generation uint64
+	probe      bool
+}
+
+func NewCircuitBreaker(processor string, cfg BreakerConfig) *CircuitBreaker {
+	if cfg.FailureThreshold < 1 {
+		cfg.FailureThreshold = 1
+	}
+	if cfg.HalfOpenProbes < 1 {
+		cfg.HalfOpenProbes = 1
+	}
+
+	return &CircuitBreaker{
+		processor: processor,
+		cfg:       cfg,
+		now:       time.Now,
+		state:     BREAKER_CLOSED,
+		changedAt: time.Now(),
+	}
+}
+
+// Allow informa se uma chamada ao processador pode ser feita agora.
+// Toda chamada liberada deve ser concluída com OnSuccess, OnFailure ou Release, usando o token retornado.
+func (cb *CircuitBreaker) Allow() (BreakerToken, bool) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	switch cb.state {
+	case BREAKER_OPEN:
+		if cb.now().Sub(cb.changedAt) < cb.cfg.OpenDuration {
+			return BreakerToken{}, false
+		}
+		cb.transition(BREAKER_HALF_OPEN)
+		fallthrough
+
+	case BREAKER_HALF_OPEN:
+		if cb.probesInFlight >= cb.cfg.HalfOpenProbes {
+			return BreakerToken{}, false
+		}
+		cb.probesInFlight++
+		return BreakerToken{generation: cb.generation, probe: true}, true
+
+	default:
+		return BreakerToken{generation: cb.generation}, true
+	}
+}
+
+// OnSuccess registra que o processador atendeu a chamada.
+func (cb *CircuitBreaker) OnSuccess(token BreakerToken) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if !cb.complete(token) {
+		return
+	}
+
+	switch cb.state {
+	case BREAKER_HALF_OPEN:
+		cb.probeSuccesses++
+		if cb.probeSuccesses >= cb.cfg.HalfOpenProbes {
+			cb.transition(BREAKER_CLOSED)
+		}
+	case BREAKER_CLOSED:
+		cb.failures = 0
+	}
+}
+
+// OnFailure registra que o processador falhou (5xx, timeout ou erro de transporte).
+func (cb *CircuitBreaker) OnFailure(token BreakerToken) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if !cb.complete(token) {
+		return
+	}
+
+	switch cb.state {
+	case BREAKER_HALF_OPEN:
+		cb.failures++
+		cb.transition(BREAKER_OPEN)
+	case BREAKER_CLOSED:
+		cb.failures++
+		if cb.failures >= cb.cfg.FailureThreshold {
+			cb.transition(BREAKER_OPEN)
+		}
+	}
+}
+
+// Release conclui uma chamada que não diz nada sobre a saúde do processador, como um pagamento recusado com 4xx,
+// devolvendo a vaga de sonda sem contar sucesso nem falha.
+func (cb *CircuitBreaker) Release(token BreakerToken) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	cb.complete(token)
+}
+
+// complete devolve a vaga de sonda do token e informa se ele ainda vale para o estado atual.
+// Deve ser chamada com o mutex travado.
+func (cb *CircuitBreaker) complete(token BreakerToken) bool {
+	if token.generation != cb.generation {
+		return false
+	}
+	if token.probe && cb.probesInFlight > 0 {
+		cb.probesInFlight--
+	}
+	return true
+}
+
+// transition deve ser chamada com o mutex travado.
+func (cb *CircuitBreaker) transition(to BreakerState) {
+	from := cb.state
+	cb.state = to
+	cb.changedAt = cb.now()
+	cb.generation++
+	cb.probesInFlight = 0
+	cb.probeSuccesses = 0
+	if to == BREAKER_CLOSED {
+		cb.failures = 0
+	}
+
+	slog.Info("circuit breaker mudou de estado", "processor", cb.processor, "from", from, "to", to, "failures", cb.failures)
+}
+
+func (cb *CircuitBreaker) reset() {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if cb.state != BREAKER_CLOSED {
+		cb.transition(BREAKER_CLOSED)
+	}
+	cb.failures = 0
+}
+
+func (cb *CircuitBreaker) Status() BreakerStatus {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	status := BreakerStatus{
+		Processor:           cb.processor,
+		State:               cb.state,
+		ConsecutiveFailures: cb.failures,
+		ChangedAt:           cb.changedAt,
+	}
+	if cb.state == BREAKER_OPEN {
+		openUntil := cb.changedAt.Add(cb.cfg.OpenDuration)
+		status.OpenUntil = &openUntil
+	}
+	return status
+}
+
+// CircuitBreakers mantém um circuit breaker por processador.
+type CircuitBreakers struct {
+	processors []string
+	breakers   map[string]*CircuitBreaker
+}
+
+func NewCircuitBreakers(processors []string, cfg BreakerConfig) *CircuitBreakers {
+	breakers := make(map[string]*CircuitBreaker, len(processors))
+	for _, processor := range processors {
+		breakers[processor] = NewCircuitBreaker(processor, cfg)
+	}
+	return &CircuitBreakers{processors: processors, breakers: breakers}
+}
+
+// Get retorna o circuit breaker do processador, ou nil se ele não for conhecido.
+func (cbs *CircuitBreakers) Get(processor string) *CircuitBreaker {
+	return cbs.breakers[processor]
+}
+
+func (cbs *CircuitBreakers) Statuses() []BreakerStatus {
+	statuses := make([]BreakerStatus, 0, len(cbs.processors))
+	for _, processor := range cbs.processors {
+		statuses = append(statuses, cbs.breakers[processor].Status())
+	}
+	return statuses
+}
+
+// Reset fecha todos os circuitos e zera as falhas, como na inicialização.
+func (cbs *CircuitBreakers) Reset() {
+	for _, processor := range cbs.processors {
+		cbs.breakers[processor].reset()
+	}
+}
@@ -0,0 +1,170 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// breakerStep é uma operação no circuit breaker. As conclusões (success, failure, release) usam o token
+// do allow de índice token; advance avança o relógio.
+type breakerStep struct {
+	op      string
+	token   int
+	advance time.Duration
+	// Resultado esperado do allow.
+	allowed bool
+	// Estado esperado depois da operação.
+	state BreakerState
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	cfg := BreakerConfig{FailureThreshold: 2, OpenDuration: time.Second}
+
+	tests := []struct {
+		name   string
+		probes int
+		steps  []breakerStep
+	}{
+		{
+			name: "fechado, aberto, meio-aberto e fechado",
+			steps: []breakerStep{
+				{op: "allow", allowed: true, state: BREAKER_CLOSED},
+				{op: "failure", token: 0, state: BREAKER_CLOSED},
+				{op: "allow", allowed: true, state: BREAKER_CLOSED},
+				{op: "failure", token: 1, state: BREAKER_OPEN},
+				{op: "allow", allowed: false, state: BREAKER_OPEN},
+				{op: "advance", advance: time.Second, state: BREAKER_OPEN},
+				{op: "allow", allowed: true, state: BREAKER_HALF_OPEN},
+				{op: "allow", allowed: false, state: BREAKER_HALF_OPEN},
+				{op: "success", token: 2, state: BREAKER_CLOSED},
+			},
+		},
+		{
+			name: "sonda com falha reabre o circuito",
+			steps: []breakerStep{
+				{op: "allow", allowed: true, state: BREAKER_CLOSED},
+				{op: "allow", allowed: true, state: BREAKER_CLOSED},
+				{op: "failure", token: 0, state: BREAKER_CLOSED},
+				{op: "failure", token: 1, state: BREAKER_OPEN},
+				{op: "advance", advance: time.Second, state: BREAKER_OPEN},
+				{op: "allow", allowed: true, state: BREAKER_HALF_OPEN},
+				{op: "failure", token: 2, state: BREAKER_OPEN},
+				{op: "allow", allowed: false, state: BREAKER_OPEN},
+			},
+		},
+		{
+			name: "conclusão de um estado anterior não conta",
+			steps: []breakerStep{
+				{op: "allow", allowed: true, state: BREAKER_CLOSED},
+				{op: "allow", allowed: true, state: BREAKER_CLOSED},
+				{op: "allow", allowed: true, state: BREAKER_CLOSED},
+				{op: "failure", token: 1, state: BREAKER_CLOSED},
+				{op: "failure", token: 2, state: BREAKER_OPEN},
+				{op: "advance", advance: time.Second, state: BREAKER_OPEN},
+				{op: "allow", allowed: true, state: BREAKER_HALF_OPEN},
+				// A chamada liberada com o circuito fechado termina durante a sonda: não fecha nem reabre o circuito
+				// e não libera a vaga da sonda.
+				{op: "success", token: 0, state: BREAKER_HALF_OPEN},
+				{op: "failure", token: 0, state: BREAKER_HALF_OPEN},
+				{op: "allow", allowed: false, state: BREAKER_HALF_OPEN},
+				{op: "success", token: 3, state: BREAKER_CLOSED},
+			},
+		},
+		{
+			name: "recusa com 4xx não abre o circuito",
+			steps: []breakerStep{
+				{op: "allow", allowed: true, state: BREAKER_CLOSED},
+				{op: "release", token: 0, state: BREAKER_CLOSED},
+				{op: "allow", allowed: true, state: BREAKER_CLOSED},
+				{op: "release", token: 1, state: BREAKER_CLOSED},
+				{op: "allow", allowed: true, state: BREAKER_CLOSED},
+				{op: "release", token: 2, state: BREAKER_CLOSED},
+			},
+		},
+		{
+			name:   "sondas em andamento nunca ficam negativas",
+			probes: 2,
+			steps: []breakerStep{
+				{op: "allow", allowed: true, state: BREAKER_CLOSED},
+				{op: "allow", allowed: true, state: BREAKER_CLOSED},
+				{op: "failure", token: 0, state: BREAKER_CLOSED},
+				{op: "failure", token: 1, state: BREAKER_OPEN},
+				{op: "advance", advance: time.Second, state: BREAKER_OPEN},
+				{op: "allow", allowed: true, state: BREAKER_HALF_OPEN},
+				{op: "release", token: 2, state: BREAKER_HALF_OPEN},
+				{op: "release", token: 2, state: BREAKER_HALF_OPEN},
+				{op: "allow", allowed: true, state: BREAKER_HALF_OPEN},
+				{op: "allow", allowed: true, state: BREAKER_HALF_OPEN},
+				{op: "allow", allowed: false, state: BREAKER_HALF_OPEN},
+				{op: "success", token: 3, state: BREAKER_HALF_OPEN},
+				{op: "success", token: 4, state: BREAKER_CLOSED},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := cfg
+			cfg.HalfOpenProbes = tt.probes
+			cb := NewCircuitBreaker("default", cfg)
+
+			now := time.Now()
+			cb.now = func() time.Time { return now }
+
+			var tokens []BreakerToken
+			for i, step := range tt.steps {
+				switch step.op {
+				case "allow":
+					token, allowed := cb.Allow()
+					if allowed != step.allowed {
+						t.Fatalf("passo %d: Allow = %v, esperava %v", i, allowed, step.allowed)
+					}
+					if allowed {
+						tokens = append(tokens, token)
+					}
+				case "success":
+					cb.OnSuccess(tokens[step.token])
+				case "failure":
+					cb.OnFailure(tokens[step.token])
+				case "release":
+					cb.Release(tokens[step.token])
+				case "advance":
+					now = now.Add(step.advance)
+				}
+
+				if cb.probesInFlight < 0 {
+					t.Fatalf("passo %d: %d sondas em andamento", i, cb.probesInFlight)
+				}
+				if state := cb.Status().State; state != step.state {
+					t.Fatalf("passo %d (%s): estado %s, esperava %s", i, step.op, state, step.state)
+				}
+			}
+		})
+	}
+}
+
+func TestProcessorFailed(t *testing.T) {
+	tests := []struct {
+		name    string
+		outcome paymentOutcome
+		err     error
+		want    bool
+	}{
+		{"400", OUTCOME_REJECTED, &processorStatusError{StatusCode: 400}, false},
+		{"404", OUTCOME_REJECTED, &processorStatusError{StatusCode: 404}, false},
+		{"422", OUTCOME_AMBIGUOUS, &processorStatusError{StatusCode: 422}, false},
+		{"500", OUTCOME_REJECTED, &processorStatusError{StatusCode: 500}, true},
+		{"503", OUTCOME_REJECTED, &processorStatusError{StatusCode: 503}, true},
+		{"timeout ou transporte", OUTCOME_AMBIGUOUS, errors.New("connection reset by peer"), true},
+		{"requisição inválida", OUTCOME_REJECTED, errors.New("json: unsupported value"), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := processorFailed(tt.outcome, tt.err); got != tt.want {
+				t.Fatalf("processorFailed(%v) = %v, esperava %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,130 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/nicolasmmb/go-rinha-backend-2025/internal/domain"
+)
+
+// ClaimOwner identifica o worker nas reservas de pagamento: a instância e o número do worker nela.
+func (ps *PaymentService) ClaimOwner(worker int) string {
+	return ps.instanceID + "/" + strconv.Itoa(worker)
+}
+
+// ClaimPayment reserva o pagamento para owner antes do envio aos processadores.
+// Se a reserva não puder ser consultada, o pagamento segue sem ela: perdê-lo é pior que o risco de enviá-lo duas vezes.
+func (ps *PaymentService) ClaimPayment(ctx context.Context, payment *domain.Payment, owner string) *domain.PaymentClaim {
+	acquired := &domain.PaymentClaim{CorrelationId: payment.CorrelationId, Status: domain.CLAIM_STATUS_CLAIMED, Owner: owner, Acquired: true}
+	if ps.repoClaims == nil {
+		ps.forgetRequeues(payment.CorrelationId)
+		return acquired
+	}
+
+	claim, err := ps.repoClaims.ClaimPayment(ctx, payment.CorrelationId, owner, ps.claimTTL)
+	if err != nil {
+		slog.Warn("falha ao reservar pagamento, seguindo sem reserva", "correlationId", payment.CorrelationId, "owner", owner, "error", err.Error())
+		ps.forgetRequeues(payment.CorrelationId)
+		return acquired
+	}
+	if !claim.Acquired {
+		slog.Warn("tentativa duplicada de processar pagamento ignorada", "correlationId", payment.CorrelationId, "owner", owner, "status", claim.Status, "claimedBy", claim.Owner)
+	}
+	if claim.Acquired || claim.Status == domain.CLAIM_STATUS_COMPLETED {
+		ps.forgetRequeues(payment.CorrelationId)
+	}
+	return claim
+}
+
+// CompleteClaim marca o pagamento como aceito por um processador, para que reenvios sejam ignorados.
+func (ps *PaymentService) CompleteClaim(ctx context.Context, payment *domain.Payment, owner string) {
+	if ps.repoClaims == nil {
+		return
+	}
+	if err := ps.repoClaims.CompleteClaim(context.WithoutCancel(ctx), payment.CorrelationId, owner, ps.completedClaimTTL); err != nil {
+		slog.Warn("falha ao concluir a reserva do pagamento", "correlationId", payment.CorrelationId, "owner", owner, "error", err.Error())
+	}
+}
+
+// ReleaseClaim libera a reserva de um pagamento que nenhum processador aceitou, para que ele possa ser enviado de novo.
+// Se a liberação falhar, a reserva expira sozinha.
+func (ps *PaymentService) ReleaseClaim(ctx context.Context, payment *domain.Payment, owner string) {
+	if ps.repoClaims == nil {
+		return
+	}
+	if err := ps.repoClaims.ReleaseClaim(context.WithoutCancel(ctx), payment.CorrelationId, owner); err != nil {
+		slog.Warn("falha ao liberar a reserva do pagamento", "correlationId", payment.CorrelationId, "owner", owner, "error", err.Error())
+	}
+}
+
+const (
+	// Primeira espera antes de devolver à fila um pagamento reservado por outro worker, para que a reserva seja concluída ou expire.
+	// A espera dobra a cada vez que o mesmo pagamento volta a encontrar a reserva, até a duração da reserva.
+	CLAIMED_REQUEUE_MIN_DELAY = 100 * time.Millisecond
+	// Maior espera quando as reservas não expiram.
+	CLAIMED_REQUEUE_MAX_DELAY = 5 * time.Second
+)
+
+// claimedRequeueDelay retorna a espera da próxima devolução do pagamento à fila e conta mais uma devolução.
+// Depois de claimTTL a reserva com certeza foi concluída ou expirou, então a espera nunca passa disso.
+func (ps *PaymentService) claimedRequeueDelay(correlationId string) time.Duration {
+	ps.requeueMu.Lock()
+	defer ps.requeueMu.Unlock()
+
+	if ps.requeues == nil {
+		ps.requeues = make(map[string]int)
+	}
+	attempt := ps.requeues[correlationId]
+	ps.requeues[correlationId] = attempt + 1
+
+	limit := CLAIMED_REQUEUE_MAX_DELAY
+	if ps.claimTTL > 0 {
+		limit = ps.claimTTL
+	}
+	delay := CLAIMED_REQUEUE_MIN_DELAY
+	for range attempt {
+		if delay >= limit {
+			break
+		}
+		delay *= 2
+	}
+	return min(delay, limit)
+}
+
+// forgetRequeues zera as devoluções do pagamento, quando a reserva é obtida ou o pagamento deixa a fila.
+func (ps *PaymentService) forgetRequeues(correlationId string) {
+	ps.requeueMu.Lock()
+	defer ps.requeueMu.Unlock()
+
+	delete(ps.requeues, correlationId)
+}
+
+// RequeueClaimedPayment devolve à fila, depois de uma espera crescente, um pagamento reservado por outro worker,
+// para filas que não o entregariam de novo. Se a fila o recusar, ele vai para a dead-letter em vez de se perder.
+func (ps *PaymentService) RequeueClaimedPayment(ctx context.Context, payment *domain.Payment) {
+	ctx = context.WithoutCancel(ctx)
+	requeued := *payment
+
+	time.AfterFunc(ps.claimedRequeueDelay(requeued.CorrelationId), func() {
+		err := ps.paymentQueue.Enqueue(ctx, &requeued)
+		if err == nil {
+			return
+		}
+		ps.forgetRequeues(requeued.CorrelationId)
+		slog.Warn("falha ao devolver pagamento reservado à fila", "correlationId", requeued.CorrelationId, "error", err.Error())
+		if err := ps.DeadLetterPayment(ctx, &requeued, fmt.Errorf("pagamento reservado não voltou à fila: %w", err)); err != nil {
+			slog.Error("falha ao salvar pagamento na dead-letter", "correlationId", requeued.CorrelationId, "error", err.Error())
+		}
+	})
+}
+
+// GetClaimStats retorna as tentativas duplicadas de processar um pagamento, somadas entre as instâncias.
+func (ps *PaymentService) GetClaimStats(ctx context.Context) (domain.ClaimStats, error) {
+	if ps.repoClaims == nil {
+		return domain.ClaimStats{}, nil
+	}
+	return ps.repoClaims.GetClaimStats(ctx)
+}
@@ -0,0 +1,72 @@
+package service
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/nicolasmmb/go-rinha-backend-2025/internal/domain"
+	"github.com/nicolasmmb/go-rinha-backend-2025/internal/repository/memory"
+)
+
+func TestClaimedRequeueDelay(t *testing.T) {
+	tests := []struct {
+		name     string
+		claimTTL time.Duration
+		want     []time.Duration
+	}{
+		{
+			name:     "dobra até a duração da reserva",
+			claimTTL: 500 * time.Millisecond,
+			want:     []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond, 500 * time.Millisecond, 500 * time.Millisecond},
+		},
+		{
+			name: "sem duração da reserva para no máximo",
+			want: []time.Duration{
+				100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond, 800 * time.Millisecond, 1600 * time.Millisecond,
+				3200 * time.Millisecond, CLAIMED_REQUEUE_MAX_DELAY, CLAIMED_REQUEUE_MAX_DELAY,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ps := &PaymentService{claimTTL: tt.claimTTL}
+
+			var delays []time.Duration
+			for range tt.want {
+				delays = append(delays, ps.claimedRequeueDelay("payment"))
+			}
+			if !slices.Equal(delays, tt.want) {
+				t.Fatalf("esperas %v, esperava %v", delays, tt.want)
+			}
+		})
+	}
+}
+
+func TestClaimedRequeueDelayResets(t *testing.T) {
+	ps := &PaymentService{repoClaims: memory.NewClaimRepository(), claimTTL: time.Minute, instanceID: "test"}
+	payment := &domain.Payment{CorrelationId: "4a7901b8-7d26-4d9d-aa19-4dc1c7cf60b3"}
+
+	if claim := ps.ClaimPayment(t.Context(), payment, ps.ClaimOwner(1)); !claim.Acquired {
+		t.Fatal("primeira reserva recusada")
+	}
+
+	// Enquanto o outro worker tem a reserva, cada devolução espera mais.
+	ps.claimedRequeueDelay(payment.CorrelationId)
+	if claim := ps.ClaimPayment(t.Context(), payment, ps.ClaimOwner(2)); claim.Acquired {
+		t.Fatal("reserva de outro worker concedida")
+	}
+	if delay := ps.claimedRequeueDelay(payment.CorrelationId); delay != 2*CLAIMED_REQUEUE_MIN_DELAY {
+		t.Fatalf("segunda espera %s, esperava %s", delay, 2*CLAIMED_REQUEUE_MIN_DELAY)
+	}
+
+	// Quando a reserva é liberada e obtida, a próxima devolução volta à espera mínima.
+	ps.ReleaseClaim(t.Context(), payment, ps.ClaimOwner(1))
+	if claim := ps.ClaimPayment(t.Context(), payment, ps.ClaimOwner(2)); !claim.Acquired {
+		t.Fatal("reserva liberada não foi concedida")
+	}
+	if delay := ps.claimedRequeueDelay(payment.CorrelationId); delay != CLAIMED_REQUEUE_MIN_DELAY {
+		t.Fatalf("espera depois de obter a reserva %s, esperava %s", delay, CLAIMED_REQUEUE_MIN_DELAY)
+	}
+}
@@ -0,0 +1,121 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/nicolasmmb/go-rinha-backend-2025/internal/domain"
+)
+
+var ErrCircuitOpen = errors.New("circuit breaker aberto")
+
+// ProcessingError indica que nenhum processador aceitou o pagamento e carrega o histórico das tentativas.
+type ProcessingError struct {
+	Attempts []domain.PaymentAttempt
+}
+
+func (e *ProcessingError) Error() string {
+	return "all processors failed"
+}
+
+// DeadLetterPayment registra na dead-letter um pagamento que falhou em todos os processadores,
+// para que ele possa ser inspecionado e reprocessado depois em vez de ser perdido.
+func (ps *PaymentService) DeadLetterPayment(ctx context.Context, payment *domain.Payment, cause error) error {
+	if ps.repoDeadLetter == nil {
+		slog.Error("pagamento perdido: dead-letter não configurada", "correlationId", payment.CorrelationId, "error", cause.Error())
+		return nil
+	}
+
+	now := time.Now()
+	deadLetter := &domain.DeadLetter{
+		Payment:       *payment,
+		Reason:        cause.Error(),
+		FirstFailedAt: now,
+		LastFailedAt:  now,
+	}
+
+	var processingErr *ProcessingError
+	if errors.As(cause, &processingErr) {
+		deadLetter.Attempts = processingErr.Attempts
+	}
+
+	// Um pagamento que volta a falhar mantém o histórico das falhas anteriores.
+	previous, err := ps.repoDeadLetter.GetDeadLetter(ctx, payment.CorrelationId)
+	if err == nil {
+		deadLetter.FirstFailedAt = previous.FirstFailedAt
+		deadLetter.Attempts = append(previous.Attempts, deadLetter.Attempts...)
+	} else if !errors.Is(err, domain.ErrDeadLetterNotFound) {
+		return err
+	}
+
+	slog.Warn("pagamento enviado para a dead-letter", "correlationId", payment.CorrelationId, "reason", deadLetter.Reason, "attempts", len(deadLetter.Attempts))
+	if err := ps.repoDeadLetter.SaveDeadLetter(ctx, deadLetter); err != nil {
+		return err
+	}
+
+	ps.TransitionPayment(ctx, payment, domain.PAYMENT_STATUS_DEAD_LETTERED)
+	return nil
+}
+
+func (ps *PaymentService) GetDeadLetter(ctx context.Context, correlationId string) (*domain.DeadLetter, error) {
+	return ps.repoDeadLetter.GetDeadLetter(ctx, correlationId)
+}
+
+func (ps *PaymentService) ListDeadLetters(ctx context.Context, offset, limit int) ([]domain.DeadLetter, int64, error) {
+	return ps.repoDeadLetter.ListDeadLetters(ctx, offset, limit)
+}
+
+// ReplayDeadLetter devolve o pagamento para a fila e o remove da dead-letter.
+func (ps *PaymentService) ReplayDeadLetter(ctx context.Context, correlationId string) error {
+	deadLetter, err := ps.repoDeadLetter.GetDeadLetter(ctx, correlationId)
+	if err != nil {
+		return err
+	}
+
+	return ps.replay(ctx, deadLetter)
+}
+
+// ReplayDeadLetters devolve para a fila até limit pagamentos da dead-letter, dos mais antigos para os mais recentes.
+func (ps *PaymentService) ReplayDeadLetters(ctx context.Context, limit int) (int, error) {
+	deadLetters, _, err := ps.repoDeadLetter.ListDeadLetters(ctx, 0, limit)
+	if err != nil {
+		return 0, err
+	}
+
+	replayed := 0
+	for i := range deadLetters {
+		if err := ps.replay(ctx, &deadLetters[i]); err != nil {
+			return replayed, err
+		}
+		replayed++
+	}
+	return replayed, nil
+}
+
+func (ps *PaymentService) replay(ctx context.Context, deadLetter *domain.DeadLetter) error {
+	payment := deadLetter.Payment
+	payment.Processor = ""
+	payment.Strategy = ""
+
+	if err := ps.SendPaymentToQueue(ctx, &payment); err != nil {
+		// O pagamento continua na dead-letter.
+		ps.TransitionPayment(ctx, &deadLetter.Payment, domain.PAYMENT_STATUS_DEAD_LETTERED)
+		return err
+	}
+
+	slog.Info("pagamento da dead-letter reenfileirado", "correlationId", payment.CorrelationId)
+	return ps.repoDeadLetter.DeleteDeadLetter(ctx, payment.CorrelationId)
+}
+
+func (ps *PaymentService) DeleteDeadLetter(ctx context.Context, correlationId string) error {
+	if _, err := ps.repoDeadLetter.GetDeadLetter(ctx, correlationId); err != nil {
+		return err
+	}
+	return ps.repoDeadLetter.DeleteDeadLetter(ctx, correlationId)
+}
+
+func (ps *PaymentService) PurgeDeadLetters(ctx context.Context) (int64, error) {
+	return ps.repoDeadLetter.PurgeDeadLetters(ctx)
+}
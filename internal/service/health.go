@@ -0,0 +1,218 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/nicolasmmb/go-rinha-backend-2025/internal/core"
+	"github.com/nicolasmmb/go-rinha-backend-2025/internal/domain"
+	"github.com/nicolasmmb/go-rinha-backend-2025/internal/model"
+)
+
+// O endpoint /payments/service-health dos processadores aceita apenas uma chamada a cada 5 segundos,
+// chamadas acima desse limite retornam 429.
+const MIN_HEALTH_CHECK_INTERVAL = 5 * time.Second
+
+// HealthMonitor consulta periodicamente o health-check de cada processador
+// e mantém em memória o último snapshot para a lógica de roteamento.
+//
+// Com várias instâncias, apenas o líder consulta os processadores e publica o snapshot
+// no repositório; as demais instâncias apenas leem o estado compartilhado.
+// Sem líder ou repositório configurados, a instância consulta e mantém o estado sozinha.
+type HealthMonitor struct {
+	httpClient *http.Client
+	urls       map[string]string
+	interval   time.Duration
+
+	leader core.LeaderElectorInterface
+	repo   core.HealthRepositoryInterface
+
+	mu       sync.RWMutex
+	statuses map[string]domain.ProcessorHealth
+}
+
+func NewHealthMonitor(urls map[string]string, interval time.Duration, leader core.LeaderElectorInterface, repo core.HealthRepositoryInterface) *HealthMonitor {
+	if interval < MIN_HEALTH_CHECK_INTERVAL {
+		interval = MIN_HEALTH_CHECK_INTERVAL
+	}
+
+	return &HealthMonitor{
+		httpClient: &http.Client{Timeout: 2 * time.Second},
+		urls:       urls,
+		interval:   interval,
+		leader:     leader,
+		repo:       repo,
+		statuses:   make(map[string]domain.ProcessorHealth, len(urls)),
+	}
+}
+
+func (hm *HealthMonitor) isLeader() bool {
+	return hm.leader == nil || hm.leader.IsLeader()
+}
+
+// Run executa uma verificação assim que a liderança é conhecida e depois uma a cada intervalo, até o contexto ser cancelado.
+func (hm *HealthMonitor) Run(ctx context.Context) {
+	if hm.repo != nil {
+		go hm.followUpdates(ctx)
+	}
+
+	// Antes da primeira disputa pelo lease a instância ainda não sabe se deve consultar os processadores.
+	if hm.leader != nil {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hm.leader.Known():
+		}
+	}
+
+	ticker := time.NewTicker(hm.interval)
+	defer ticker.Stop()
+
+	for {
+		if hm.isLeader() {
+			hm.checkAll(ctx)
+		} else {
+			hm.loadShared(ctx)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// followUpdates aplica os snapshots publicados pelo líder assim que chegam.
+func (hm *HealthMonitor) followUpdates(ctx context.Context) {
+	for statuses := range hm.repo.SubscribeHealth(ctx) {
+		hm.mergeStatuses(statuses)
+	}
+}
+
+// loadShared lê o snapshot salvo pelo líder, caso alguma publicação tenha sido perdida.
+func (hm *HealthMonitor) loadShared(ctx context.Context) {
+	if hm.repo == nil {
+		return
+	}
+
+	statuses, err := hm.repo.GetHealth(ctx)
+	if err != nil {
+		slog.Warn("falha ao ler o health compartilhado", "error", err.Error())
+		return
+	}
+	hm.mergeStatuses(statuses)
+}
+
+func (hm *HealthMonitor) checkAll(ctx context.Context) {
+	var wg sync.WaitGroup
+	for name, url := range hm.urls {
+		// Um líder recém-eleito respeita a última consulta feita pelo líder anterior.
+		if current, ok := hm.lastStatus(name); ok && time.Since(current.CheckedAt) < hm.interval-100*time.Millisecond {
+			continue
+		}
+
+		wg.Add(1)
+		go func(name, url string) {
+			defer wg.Done()
+
+			checkedAt := time.Now()
+			status, err := hm.fetchHealth(ctx, url)
+			if err != nil {
+				slog.Warn("falha ao consultar health-check do processador", "processor", name, "error", err.Error())
+				return
+			}
+			hm.mergeStatuses(map[string]domain.ProcessorHealth{
+				name: {Failing: status.Failing, MinResponseTime: status.MinResponseTime, CheckedAt: checkedAt},
+			})
+		}(name, url)
+	}
+	wg.Wait()
+
+	if hm.repo == nil {
+		return
+	}
+
+	hm.mu.RLock()
+	snapshot := maps.Clone(hm.statuses)
+	hm.mu.RUnlock()
+
+	if err := hm.repo.SaveHealth(ctx, snapshot); err != nil {
+		slog.Warn("falha ao publicar o health compartilhado", "error", err.Error())
+	}
+}
+
+func (hm *HealthMonitor) fetchHealth(ctx context.Context, url string) (model.HealthStatus, error) {
+	var status model.HealthStatus
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return status, err
+	}
+
+	resp, err := hm.httpClient.Do(req)
+	if err != nil {
+		return status, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		io.Copy(io.Discard, resp.Body)
+		return status, fmt.Errorf("status inesperado: %d", resp.StatusCode)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+		return status, err
+	}
+
+	return status, nil
+}
+
+// mergeStatuses aplica os estados recebidos, mantendo sempre a consulta mais recente de cada processador.
+func (hm *HealthMonitor) mergeStatuses(statuses map[string]domain.ProcessorHealth) {
+	hm.mu.Lock()
+	defer hm.mu.Unlock()
+
+	for name, status := range statuses {
+		previous, known := hm.statuses[name]
+		if known && !status.CheckedAt.After(previous.CheckedAt) {
+			continue
+		}
+		hm.statuses[name] = status
+
+		if !known || previous.Failing != status.Failing {
+			slog.Info("estado do processador atualizado", "processor", name, "failing", status.Failing, "minResponseTime", status.MinResponseTime)
+		}
+	}
+}
+
+func (hm *HealthMonitor) lastStatus(name string) (domain.ProcessorHealth, bool) {
+	hm.mu.RLock()
+	defer hm.mu.RUnlock()
+
+	status, ok := hm.statuses[name]
+	return status, ok
+}
+
+// Snapshot retorna o último estado conhecido do processador. O estado é descartado
+// quando fica mais velho que três intervalos, já que não reflete mais a realidade.
+func (hm *HealthMonitor) Snapshot(name string) (domain.ProcessorHealth, bool) {
+	status, ok := hm.lastStatus(name)
+	if !ok || time.Since(status.CheckedAt) > 3*hm.interval {
+		return domain.ProcessorHealth{}, false
+	}
+	return status, true
+}
+
+// IsFailing informa se o processador é sabidamente falho. Processadores sem estado conhecido não são considerados falhos.
+func (hm *HealthMonitor) IsFailing(name string) bool {
+	status, ok := hm.Snapshot(name)
+	return ok && status.Failing
+}
@@ -0,0 +1,55 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// pendingLeader é um líder cuja primeira disputa pelo lease só termina quando o teste manda.
+type pendingLeader struct {
+	leader atomic.Bool
+	known  chan struct{}
+}
+
+func (l *pendingLeader) IsLeader() bool { return l.leader.Load() }
+
+func (l *pendingLeader) Known() <-chan struct{} { return l.known }
+
+func TestHealthMonitorWaitsForLease(t *testing.T) {
+	var probes atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		probes.Add(1)
+		w.Write([]byte(`{"failing":false,"minResponseTime":10}`))
+	}))
+	defer server.Close()
+
+	leader := &pendingLeader{known: make(chan struct{})}
+	monitor := NewHealthMonitor(map[string]string{"default": server.URL}, MIN_HEALTH_CHECK_INTERVAL, leader, nil)
+
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+	go monitor.Run(ctx)
+
+	// A instância vai ganhar o lease, mas enquanto o resultado não é conhecido não consulta ninguém.
+	leader.leader.Store(true)
+	time.Sleep(50 * time.Millisecond)
+	if n := probes.Load(); n != 0 {
+		t.Fatalf("%d consultas antes de conhecer o lease, esperava nenhuma", n)
+	}
+
+	close(leader.known)
+	deadline := time.Now().Add(time.Second)
+	for probes.Load() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("o líder não consultou o processador depois de conhecer o lease")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if _, ok := monitor.Snapshot("default"); !ok {
+		t.Fatal("health do processador não registrado depois da consulta")
+	}
+}
@@ -0,0 +1,74 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/nicolasmmb/go-rinha-backend-2025/internal/domain"
+)
+
+// Tempo que a reserva de uma Idempotency-Key dura enquanto a primeira requisição é tratada;
+// se a instância cair antes de responder, a chave fica livre de novo depois disso.
+const IDEMPOTENCY_PENDING_TTL = 10 * time.Second
+
+var (
+	ErrIdempotencyKeyReused   = errors.New("Idempotency-Key já usada com outro corpo")
+	ErrIdempotencyKeyInFlight = errors.New("requisição com a mesma Idempotency-Key ainda em andamento")
+)
+
+func idempotencyHash(body []byte) string {
+	hash := sha256.Sum256(body)
+	return hex.EncodeToString(hash[:])
+}
+
+// BeginIdempotentRequest reserva a chave para a requisição com body. Retorna a resposta original quando a chave
+// já foi respondida para o mesmo corpo, ErrIdempotencyKeyReused quando foi usada com outro corpo
+// e ErrIdempotencyKeyInFlight quando a primeira requisição ainda não terminou.
+// Retorna nil, sem erro, quando a requisição deve ser tratada e respondida com FinishIdempotentRequest.
+func (ps *PaymentService) BeginIdempotentRequest(ctx context.Context, key string, body []byte) (*domain.IdempotentResponse, error) {
+	if ps.repoIdempotency == nil {
+		return nil, nil
+	}
+
+	requestHash := idempotencyHash(body)
+	record, err := ps.repoIdempotency.ReserveIdempotencyKey(ctx, key, requestHash, IDEMPOTENCY_PENDING_TTL)
+	switch {
+	case err != nil:
+		// Sem o repositório a requisição é tratada como nova: a reserva do correlationId ainda impede o envio duplicado.
+		slog.Warn("falha ao reservar Idempotency-Key, seguindo sem ela", "key", key, "error", err.Error())
+		return nil, nil
+	case record == nil:
+		return nil, nil
+	case record.RequestHash != requestHash:
+		return nil, ErrIdempotencyKeyReused
+	case record.Response == nil:
+		return nil, ErrIdempotencyKeyInFlight
+	}
+	return record.Response, nil
+}
+
+// FinishIdempotentRequest grava a resposta da chave reservada por BeginIdempotentRequest, para ser repetida até o fim da janela.
+// Respostas 5xx não são gravadas: a chave é liberada para que o cliente possa tentar de novo.
+func (ps *PaymentService) FinishIdempotentRequest(ctx context.Context, key string, body []byte, response *domain.IdempotentResponse) {
+	if ps.repoIdempotency == nil {
+		return
+	}
+	ctx = context.WithoutCancel(ctx)
+
+	if response.Status >= http.StatusInternalServerError {
+		if err := ps.repoIdempotency.ReleaseIdempotencyKey(ctx, key); err != nil {
+			slog.Warn("falha ao liberar Idempotency-Key", "key", key, "error", err.Error())
+		}
+		return
+	}
+
+	record := &domain.IdempotencyRecord{RequestHash: idempotencyHash(body), Response: response}
+	if err := ps.repoIdempotency.SaveIdempotentResponse(ctx, key, record, ps.idempotencyWindow); err != nil {
+		slog.Warn("falha ao gravar a resposta da Idempotency-Key", "key", key, "error", err.Error())
+	}
+}
@@ -0,0 +1,308 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/nicolasmmb/go-rinha-backend-2025/internal/core"
+	"github.com/nicolasmmb/go-rinha-backend-2025/internal/domain"
+)
+
+const (
+	OUTBOX_MIN_BACKOFF = 100 * time.Millisecond
+	OUTBOX_MAX_BACKOFF = 5 * time.Second
+)
+
+var (
+	ErrSaveDeferred = errors.New("pagamento guardado no outbox até o repositório aceitá-lo")
+	ErrOutboxFull   = errors.New("outbox cheio")
+)
+
+// OutboxStatus mostra as gravações pendentes no outbox.
+type OutboxStatus struct {
+	Pending     int    `json:"pending"`
+	Spilled     int64  `json:"spilled"`
+	Capacity    int    `json:"capacity"`
+	OldestAgeMs int64  `json:"oldestAgeMs"`
+	LastError   string `json:"lastError,omitempty"`
+}
+
+type outboxEntry struct {
+	payment domain.Payment
+	addedAt time.Time
+}
+
+// Outbox guarda os pagamentos já aceitos por um processador que o repositório recusou gravar,
+// e os regrava em segundo plano, com backoff, até o repositório aceitá-los.
+// O buffer em memória é limitado; o excedente vai para o arquivo local, quando configurado.
+// Gravar de novo é seguro: o repositório ignora pagamentos já gravados.
+type Outbox struct {
+	repo     core.PaymentRepositoryInterface
+	spill    core.QueueSpillRepositoryInterface
+	capacity int
+
+	mu        sync.Mutex
+	entries   []outboxEntry
+	spilled   int64
+	spilledAt time.Time
+	lastError string
+
+	// Serializa o uso do arquivo, que é lido e reescrito por inteiro.
+	spillMu sync.Mutex
+	wake    chan struct{}
+}
+
+// NewOutbox cria o outbox; spill pode ser nil, e então os pagamentos que não couberem no buffer são recusados.
+func NewOutbox(repo core.PaymentRepositoryInterface, spill core.QueueSpillRepositoryInterface, capacity int) *Outbox {
+	return &Outbox{
+		repo:     repo,
+		spill:    spill,
+		capacity: max(capacity, 1),
+		wake:     make(chan struct{}, 1),
+	}
+}
+
+// Add guarda o pagamento para uma nova tentativa. cause é o erro da gravação que falhou.
+// Retorna ErrOutboxFull se o buffer estiver cheio e não houver arquivo para o excedente.
+func (o *Outbox) Add(ctx context.Context, payment *domain.Payment, cause error) error {
+	o.mu.Lock()
+	o.lastError = cause.Error()
+	if len(o.entries) < o.capacity {
+		o.entries = append(o.entries, outboxEntry{payment: *payment, addedAt: time.Now()})
+		o.mu.Unlock()
+		o.notify()
+		return nil
+	}
+	o.mu.Unlock()
+
+	if o.spill == nil {
+		return ErrOutboxFull
+	}
+
+	o.spillMu.Lock()
+	defer o.spillMu.Unlock()
+	if err := o.spill.SaveSpilled(ctx, []domain.Payment{*payment}); err != nil {
+		return errors.Join(ErrOutboxFull, err)
+	}
+
+	o.mu.Lock()
+	if o.spilled == 0 {
+		o.spilledAt = time.Now()
+	}
+	o.spilled++
+	o.mu.Unlock()
+	return nil
+}
+
+func (o *Outbox) notify() {
+	select {
+	case o.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Load retoma os pagamentos que ficaram no arquivo no último desligamento e retorna quantos eram.
+func (o *Outbox) Load(ctx context.Context) (int, error) {
+	if o.spill == nil {
+		return 0, nil
+	}
+
+	o.spillMu.Lock()
+	payments, err := o.spill.TakeSpilled(ctx)
+	if err != nil {
+		o.spillMu.Unlock()
+		return 0, err
+	}
+	if len(payments) > 0 {
+		if err := o.spill.SaveSpilled(ctx, payments); err != nil {
+			o.spillMu.Unlock()
+			return 0, err
+		}
+	}
+	o.spillMu.Unlock()
+
+	o.mu.Lock()
+	o.spilled, o.spilledAt = int64(len(payments)), time.Now()
+	o.mu.Unlock()
+
+	o.notify()
+	return len(payments), nil
+}
+
+// Run regrava os pagamentos pendentes até o contexto ser cancelado.
+// Enquanto o repositório falhar, o intervalo entre as tentativas dobra, até OUTBOX_MAX_BACKOFF.
+func (o *Outbox) Run(ctx context.Context) {
+	backoff := OUTBOX_MIN_BACKOFF
+	var retry <-chan time.Time
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-o.wake:
+		case <-retry:
+		}
+
+		if err := o.flush(ctx); err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			slog.Warn("falha ao regravar pagamentos do outbox", "pending", o.Status().Pending, "retryIn", backoff.String(), "error", err.Error())
+			retry = time.After(backoff)
+			backoff = min(2*backoff, OUTBOX_MAX_BACKOFF)
+			continue
+		}
+		backoff, retry = OUTBOX_MIN_BACKOFF, nil
+	}
+}
+
+// flush grava os pagamentos pendentes, do mais antigo ao mais novo, e para no primeiro erro.
+func (o *Outbox) flush(ctx context.Context) error {
+	for {
+		if err := o.refill(ctx); err != nil {
+			return err
+		}
+
+		o.mu.Lock()
+		if len(o.entries) == 0 {
+			o.lastError = ""
+			o.mu.Unlock()
+			return nil
+		}
+		entry := o.entries[0]
+		o.mu.Unlock()
+
+		if _, err := o.repo.SavePayment(ctx, &entry.payment); err != nil {
+			o.mu.Lock()
+			o.lastError = err.Error()
+			o.mu.Unlock()
+			return err
+		}
+
+		o.mu.Lock()
+		// O outbox pode ter sido esvaziado pelo reset durante a gravação.
+		if len(o.entries) > 0 && o.entries[0] == entry {
+			o.entries = o.entries[1:]
+		}
+		o.mu.Unlock()
+
+		slog.Info("pagamento do outbox gravado", "correlationId", entry.payment.CorrelationId, "processor", entry.payment.Processor, "age", time.Since(entry.addedAt).String())
+	}
+}
+
+// refill traz do arquivo para o buffer os pagamentos que couberem.
+func (o *Outbox) refill(ctx context.Context) error {
+	o.mu.Lock()
+	room := o.capacity - len(o.entries)
+	pending := o.spilled
+	o.mu.Unlock()
+	if o.spill == nil || pending == 0 || room <= 0 {
+		return nil
+	}
+
+	o.spillMu.Lock()
+	defer o.spillMu.Unlock()
+
+	payments, err := o.spill.TakeSpilled(ctx)
+	if err != nil {
+		return err
+	}
+	taken := payments[:min(room, len(payments))]
+	if rest := payments[len(taken):]; len(rest) > 0 {
+		if err := o.spill.SaveSpilled(ctx, rest); err != nil {
+			return err
+		}
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for _, payment := range taken {
+		o.entries = append(o.entries, outboxEntry{payment: payment, addedAt: o.spilledAt})
+	}
+	o.spilled = int64(len(payments) - len(taken))
+	return nil
+}
+
+// Close tenta gravar os pagamentos pendentes até o prazo do contexto e guarda no arquivo os que sobrarem.
+// Retorna quantos continuam pendentes no arquivo e quantos foram perdidos por não haver arquivo.
+func (o *Outbox) Close(ctx context.Context) (spilled, lost int64) {
+	if err := o.flush(ctx); err == nil {
+		return 0, 0
+	}
+
+	o.mu.Lock()
+	remaining := make([]domain.Payment, len(o.entries))
+	for i, entry := range o.entries {
+		remaining[i] = entry.payment
+	}
+	o.entries = nil
+	spilled = o.spilled
+	o.mu.Unlock()
+
+	if len(remaining) == 0 {
+		return spilled, 0
+	}
+	if o.spill == nil {
+		return spilled, int64(len(remaining))
+	}
+
+	o.spillMu.Lock()
+	defer o.spillMu.Unlock()
+	if err := o.spill.SaveSpilled(context.WithoutCancel(ctx), remaining); err != nil {
+		slog.Error("falha ao guardar os pagamentos pendentes do outbox", "count", len(remaining), "error", err.Error())
+		return spilled, int64(len(remaining))
+	}
+	return spilled + int64(len(remaining)), 0
+}
+
+// Purge descarta os pagamentos pendentes, inclusive os do arquivo, e retorna quantos foram descartados.
+func (o *Outbox) Purge(ctx context.Context) (int64, error) {
+	o.mu.Lock()
+	discarded := int64(len(o.entries))
+	o.entries = nil
+	o.mu.Unlock()
+
+	if o.spill != nil {
+		o.spillMu.Lock()
+		payments, err := o.spill.TakeSpilled(ctx)
+		o.spillMu.Unlock()
+		if err != nil {
+			return discarded, err
+		}
+		discarded += int64(len(payments))
+	}
+
+	o.mu.Lock()
+	o.spilled, o.lastError = 0, ""
+	o.mu.Unlock()
+	return discarded, nil
+}
+
+func (o *Outbox) Status() OutboxStatus {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	status := OutboxStatus{
+		Pending:   len(o.entries),
+		Spilled:   o.spilled,
+		Capacity:  o.capacity,
+		LastError: o.lastError,
+	}
+
+	var oldest time.Time
+	if o.spilled > 0 {
+		oldest = o.spilledAt
+	}
+	for _, entry := range o.entries {
+		if oldest.IsZero() || entry.addedAt.Before(oldest) {
+			oldest = entry.addedAt
+		}
+	}
+	if !oldest.IsZero() {
+		status.OldestAgeMs = time.Since(oldest).Milliseconds()
+	}
+	return status
+}
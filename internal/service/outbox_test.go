@@ -0,0 +1,178 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/nicolasmmb/go-rinha-backend-2025/internal/core"
+	"github.com/nicolasmmb/go-rinha-backend-2025/internal/domain"
+	"github.com/nicolasmmb/go-rinha-backend-2025/internal/repository/file"
+	"github.com/nicolasmmb/go-rinha-backend-2025/internal/repository/memory"
+)
+
+var errRepositoryDown = errors.New("repositório fora do ar")
+
+// flakyPaymentRepository recusa as primeiras failures gravações e guarda a ordem das que aceita.
+type flakyPaymentRepository struct {
+	core.PaymentRepositoryInterface
+
+	mu       sync.Mutex
+	failures int
+	saved    []string
+}
+
+func (r *flakyPaymentRepository) SavePayment(ctx context.Context, payment *domain.Payment) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.failures > 0 {
+		r.failures--
+		return false, errRepositoryDown
+	}
+	r.saved = append(r.saved, payment.CorrelationId)
+	return r.PaymentRepositoryInterface.SavePayment(ctx, payment)
+}
+
+func (r *flakyPaymentRepository) savedIds() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return slices.Clone(r.saved)
+}
+
+func newFlakyRepository(failures int) *flakyPaymentRepository {
+	return &flakyPaymentRepository{PaymentRepositoryInterface: memory.NewPaymentsRepository(), failures: failures}
+}
+
+func outboxPayments(n int) []domain.Payment {
+	payments := make([]domain.Payment, n)
+	for i := range payments {
+		payments[i] = domain.Payment{CorrelationId: uuid.NewString(), Amount: 1990, Processor: domain.PROCESSOR_DEFAULT, RequestedAt: time.Now()}
+	}
+	return payments
+}
+
+func correlationIds(payments []domain.Payment) []string {
+	ids := make([]string, len(payments))
+	for i, payment := range payments {
+		ids[i] = payment.CorrelationId
+	}
+	return ids
+}
+
+func TestOutboxFlushRetries(t *testing.T) {
+	repo := newFlakyRepository(2)
+	outbox := NewOutbox(repo, nil, 10)
+
+	payments := outboxPayments(3)
+	for i := range payments {
+		if err := outbox.Add(t.Context(), &payments[i], errRepositoryDown); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+	go outbox.Run(ctx)
+
+	// Duas falhas seguidas: o outbox espera OUTBOX_MIN_BACKOFF e depois o dobro antes de gravar.
+	deadline := time.Now().Add(OUTBOX_MAX_BACKOFF)
+	for outbox.Status().Pending > 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("outbox não esvaziou depois das falhas: %+v", outbox.Status())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if saved := repo.savedIds(); !slices.Equal(saved, correlationIds(payments)) {
+		t.Fatalf("gravados %v, esperava %v na ordem de chegada", saved, correlationIds(payments))
+	}
+	if status := outbox.Status(); status.LastError != "" {
+		t.Fatalf("último erro %q depois de esvaziar, esperava nenhum", status.LastError)
+	}
+}
+
+func TestOutboxSpill(t *testing.T) {
+	payments := outboxPayments(5)
+
+	t.Run("sem arquivo recusa o excedente", func(t *testing.T) {
+		outbox := NewOutbox(newFlakyRepository(0), nil, 2)
+		for i := range payments {
+			err := outbox.Add(t.Context(), &payments[i], errRepositoryDown)
+			if want := i >= 2; errors.Is(err, ErrOutboxFull) != want {
+				t.Fatalf("Add %d = %v, esperava outbox cheio = %v", i, err, want)
+			}
+		}
+	})
+
+	t.Run("com arquivo guarda o excedente", func(t *testing.T) {
+		spill := file.NewQueueSpillRepository(filepath.Join(t.TempDir(), "outbox.jsonl"))
+		repo := newFlakyRepository(0)
+		outbox := NewOutbox(repo, spill, 2)
+		for i := range payments {
+			if err := outbox.Add(t.Context(), &payments[i], errRepositoryDown); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if status := outbox.Status(); status.Pending != 2 || status.Spilled != 3 {
+			t.Fatalf("status %+v, esperava 2 em memória e 3 no arquivo", status)
+		}
+
+		// O flush grava a memória e traz o arquivo aos poucos, sem passar da capacidade.
+		if err := outbox.flush(t.Context()); err != nil {
+			t.Fatal(err)
+		}
+		if saved := repo.savedIds(); !slices.Equal(saved, correlationIds(payments)) {
+			t.Fatalf("gravados %v, esperava %v", saved, correlationIds(payments))
+		}
+		if status := outbox.Status(); status.Pending != 0 || status.Spilled != 0 {
+			t.Fatalf("status %+v depois do flush, esperava vazio", status)
+		}
+	})
+}
+
+func TestOutboxReloadOnBoot(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "outbox.jsonl")
+	payments := outboxPayments(3)
+
+	// A instância anterior desliga com o repositório fora do ar e guarda tudo no arquivo.
+	previous := NewOutbox(newFlakyRepository(len(payments)+1), file.NewQueueSpillRepository(path), 10)
+	for i := range payments {
+		if err := previous.Add(t.Context(), &payments[i], errRepositoryDown); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if spilled, lost := previous.Close(t.Context()); spilled != int64(len(payments)) || lost != 0 {
+		t.Fatalf("Close = %d guardados e %d perdidos, esperava %d e 0", spilled, lost, len(payments))
+	}
+
+	// No boot seguinte o outbox retoma o arquivo e grava os pagamentos.
+	repo := newFlakyRepository(0)
+	outbox := NewOutbox(repo, file.NewQueueSpillRepository(path), 10)
+	loaded, err := outbox.Load(t.Context())
+	if err != nil || loaded != len(payments) {
+		t.Fatalf("Load = %d, %v, esperava %d", loaded, err, len(payments))
+	}
+	if status := outbox.Status(); status.Spilled != int64(len(payments)) {
+		t.Fatalf("status %+v, esperava %d no arquivo", status, len(payments))
+	}
+
+	if err := outbox.flush(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+	if saved := repo.savedIds(); !slices.Equal(saved, correlationIds(payments)) {
+		t.Fatalf("gravados %v, esperava %v", saved, correlationIds(payments))
+	}
+
+	// O arquivo foi consumido: um novo boot não tem nada para retomar.
+	if loaded, err := NewOutbox(repo, file.NewQueueSpillRepository(path), 10).Load(t.Context()); err != nil || loaded != 0 {
+		t.Fatalf("segundo Load = %d, %v, esperava nada", loaded, err)
+	}
+}
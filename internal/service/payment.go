@@ -15,17 +15,46 @@ import (
 
 	"github.com/nicolasmmb/go-rinha-backend-2025/internal/core"
 	"github.com/nicolasmmb/go-rinha-backend-2025/internal/domain"
+	"github.com/nicolasmmb/go-rinha-backend-2025/internal/model"
 )
 
 type PaymentService struct {
-	repoPayment core.PaymentRepositoryInterface
-	httpClient  *http.Client
-
-	paymentQueue chan domain.Payment
-	queueSize    int
-
-	URL_DEFAULT_PROCESSOR  string
-	URL_FALLBACK_PROCESSOR string
+	repoPayment    core.PaymentRepositoryInterface
+	repoParked     core.ReconciliationRepositoryInterface
+	repoDeadLetter core.DeadLetterRepositoryInterface
+	repoSpill      core.QueueSpillRepositoryInterface
+	repoClaims     core.ClaimRepositoryInterface
+	httpClient     *http.Client
+	health         *HealthMonitor
+	routing        core.RoutingStrategy
+	breakers       *CircuitBreakers
+	// Pagamentos processados que o repositório recusou gravar; nil para devolver o erro ao chamador.
+	outbox *Outbox
+	// Anuncia os resets às outras instâncias; nil quando só existe esta.
+	resets core.ResetNotifierInterface
+
+	// Respostas de POST /payments por Idempotency-Key, repetidas por idempotencyWindow; nil desativa.
+	repoIdempotency   core.IdempotencyRepositoryInterface
+	idempotencyWindow time.Duration
+
+	// Identifica esta instância nas reservas de pagamento, que duram claimTTL enquanto o pagamento é enviado
+	// e completedClaimTTL depois que um processador o aceita.
+	instanceID        string
+	claimTTL          time.Duration
+	completedClaimTTL time.Duration
+
+	// Devoluções à fila de cada pagamento reservado por outro worker, que definem a espera da próxima.
+	requeueMu sync.Mutex
+	requeues  map[string]int
+
+	paymentQueue core.PaymentQueueInterface
+	drainMeter   drainMeter
+
+	processors *ProcessorRegistry
+	// Com o modo de compatibilidade o resumo mantém apenas as chaves "default" e "fallback".
+	summaryCompatMode bool
+	// Maior janela aceita pelo resumo (zero para não limitar).
+	maxSummaryWindow time.Duration
 }
 
 var HackBufferPool = sync.Pool{
@@ -34,7 +63,33 @@ var HackBufferPool = sync.Pool{
 	},
 }
 
-func NewPaymentService(paymentRepository core.PaymentRepositoryInterface, URL_DEFAULT_PROCESSOR string, URL_FALLBACK_PROCESSOR string, queueSize int) *PaymentService {
+// PaymentServiceOptions reúne as dependências e configurações do PaymentService.
+type PaymentServiceOptions struct {
+	PaymentRepository        core.PaymentRepositoryInterface
+	ReconciliationRepository core.ReconciliationRepositoryInterface
+	DeadLetterRepository     core.DeadLetterRepositoryInterface
+	QueueSpillRepository     core.QueueSpillRepositoryInterface
+	ClaimRepository          core.ClaimRepositoryInterface
+	IdempotencyRepository    core.IdempotencyRepositoryInterface
+	ResetNotifier            core.ResetNotifierInterface
+
+	Processors *ProcessorRegistry
+	Health     *HealthMonitor
+	Routing    core.RoutingStrategy
+	Breakers   *CircuitBreakers
+	Outbox     *Outbox
+
+	InstanceID        string
+	ClaimTTL          time.Duration
+	CompletedClaimTTL time.Duration
+	IdempotencyWindow time.Duration
+
+	Queue             core.PaymentQueueInterface
+	SummaryCompatMode bool
+	MaxSummaryWindow  time.Duration
+}
+
+func NewPaymentService(opts PaymentServiceOptions) *PaymentService {
 	tr := &http.Transport{
 		DialContext: (&net.Dialer{
 			Timeout:   500 * time.Millisecond,
@@ -63,103 +118,402 @@ func NewPaymentService(paymentRepository core.PaymentRepositoryInterface, URL_DE
 		ForceAttemptHTTP2: false,
 	}
 
-	c := &http.Client{Transport: tr, Timeout: 5 * time.Second}
+	// O timeout de cada chamada é aplicado por processador, conforme a configuração do registro.
+	c := &http.Client{Transport: tr}
+
+	// Sem janela as respostas de POST /payments não são guardadas por Idempotency-Key.
+	idempotency := opts.IdempotencyRepository
+	if opts.IdempotencyWindow <= 0 {
+		idempotency = nil
+	}
 
 	return &PaymentService{
-		repoPayment:            paymentRepository,
-		httpClient:             c,
-		URL_DEFAULT_PROCESSOR:  URL_DEFAULT_PROCESSOR,
-		URL_FALLBACK_PROCESSOR: URL_FALLBACK_PROCESSOR,
-		paymentQueue:           make(chan domain.Payment, queueSize),
-		queueSize:              queueSize,
+		repoPayment:       opts.PaymentRepository,
+		repoParked:        opts.ReconciliationRepository,
+		repoDeadLetter:    opts.DeadLetterRepository,
+		repoSpill:         opts.QueueSpillRepository,
+		repoClaims:        opts.ClaimRepository,
+		repoIdempotency:   idempotency,
+		idempotencyWindow: opts.IdempotencyWindow,
+		httpClient:        c,
+		health:            opts.Health,
+		routing:           opts.Routing,
+		breakers:          opts.Breakers,
+		outbox:            opts.Outbox,
+		resets:            opts.ResetNotifier,
+		instanceID:        opts.InstanceID,
+		claimTTL:          opts.ClaimTTL,
+		completedClaimTTL: opts.CompletedClaimTTL,
+		processors:        opts.Processors,
+		summaryCompatMode: opts.SummaryCompatMode,
+		maxSummaryWindow:  opts.MaxSummaryWindow,
+		paymentQueue:      opts.Queue,
 	}
 }
 
-func (ps *PaymentService) SendPaymentToQueue(payment *domain.Payment) error {
-	select {
-	case ps.paymentQueue <- *payment:
-		return nil
-	default:
-		return errors.New("O Galo tá cansado")
-	}
+// SendPaymentToQueue enfileira o pagamento. O registro vai para queued antes do envio,
+// para que um worker rápido já o encontre enfileirado ao passá-lo para processing.
+func (ps *PaymentService) SendPaymentToQueue(ctx context.Context, payment *domain.Payment) error {
+	ps.TransitionPayment(ctx, payment, domain.PAYMENT_STATUS_QUEUED)
+	return ps.paymentQueue.Enqueue(ctx, payment)
 }
 
-func (ps *PaymentService) GetPaymentQueue() <-chan domain.Payment {
+func (ps *PaymentService) GetPaymentQueue() core.PaymentQueueInterface {
 	return ps.paymentQueue
 }
 
-func (ps *PaymentService) sendPaymentRequest(ctx context.Context, payment *domain.Payment, url string) bool {
+// paymentOutcome é o resultado de um envio de pagamento a um processador.
+type paymentOutcome int
+
+const (
+	// O processador confirmou o pagamento.
+	OUTCOME_PROCESSED paymentOutcome = iota
+	// O processador respondeu que não processou o pagamento.
+	OUTCOME_REJECTED
+	// Não há como saber se o processador aceitou o pagamento (timeout, conexão perdida, etc).
+	OUTCOME_AMBIGUOUS
+)
+
+func (ps *PaymentService) sendPaymentRequest(ctx context.Context, payment *domain.Payment, processor domain.Processor) (paymentOutcome, error) {
 
 	buf := HackBufferPool.Get().(*bytes.Buffer)
 	buf.Reset()
 	defer HackBufferPool.Put(buf)
 
-	if err := json.NewEncoder(buf).Encode(payment); err != nil {
+	body := model.ProcessorPaymentRequest{
+		CorrelationID: payment.CorrelationId,
+		Amount:        payment.Amount,
+		RequestedAt:   payment.RequestedAt,
+	}
+
+	if err := json.NewEncoder(buf).Encode(body); err != nil {
 		slog.Warn("falha ao encodar pagamento para JSON", "error", err.Error())
-		return false
+		return OUTCOME_REJECTED, err
 	}
 
-	req, err := http.NewRequestWithContext(ctx, "POST", url, buf)
+	if processor.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, processor.Timeout)
+		defer cancel()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", processor.PaymentURL, buf)
 	if err != nil {
-		return false
+		return OUTCOME_REJECTED, err
 	}
 
 	req.Header.Set("Content-Type", "application/json")
 
+	// A partir daqui o pagamento pode ter chegado ao processador: qualquer erro de transporte é ambíguo.
 	resp, err := ps.httpClient.Do(req)
 	if err != nil {
-		return false
+		return OUTCOME_AMBIGUOUS, err
 	}
 
-	io.Copy(io.Discard, resp.Body)
+	_, err = io.Copy(io.Discard, resp.Body)
 	defer resp.Body.Close()
 
-	return http.StatusOK == resp.StatusCode
+	switch {
+	case resp.StatusCode == http.StatusOK:
+		return OUTCOME_PROCESSED, nil
+	case resp.StatusCode == http.StatusUnprocessableEntity:
+		// O processador recusa correlationIds repetidos, então o pagamento pode já estar lá.
+		return OUTCOME_AMBIGUOUS, &processorStatusError{StatusCode: resp.StatusCode}
+	case err != nil:
+		return OUTCOME_AMBIGUOUS, err
+	default:
+		return OUTCOME_REJECTED, &processorStatusError{StatusCode: resp.StatusCode}
+	}
+}
+
+// processorStatusError é a resposta do processador com um status diferente de 200.
+type processorStatusError struct {
+	StatusCode int
 }
 
-func (ps *PaymentService) ProcessPayment(ctx context.Context, p *domain.Payment) (*domain.Payment, error) {
-	p.Processor = "default"
-	p.RequestedAt = time.Now()
+func (e *processorStatusError) Error() string {
+	return fmt.Sprintf("processador respondeu %d", e.StatusCode)
+}
 
-	for i := 0; i < 5; i++ {
-		processed := ps.sendPaymentRequest(ctx, p, ps.URL_DEFAULT_PROCESSOR)
-		if processed {
-			return p, nil
+// processorFailed informa se um envio sem confirmação é falha do processador para o circuit breaker:
+// 5xx, timeout ou erro de transporte. Respostas 4xx e erros ao montar a requisição não contam.
+func processorFailed(outcome paymentOutcome, err error) bool {
+	var statusErr *processorStatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode >= http.StatusInternalServerError
+	}
+	return outcome == OUTCOME_AMBIGUOUS
+}
+
+// Número de tentativas no primeiro processador do plano de roteamento; os demais são tentados uma única vez.
+const PRIMARY_PROCESSOR_ATTEMPTS = 5
+
+// candidates monta a lista de processadores, em ordem de prioridade, com o último health conhecido de cada um.
+func (ps *PaymentService) candidates() []core.ProcessorCandidate {
+	processors := ps.processors.All()
+	candidates := make([]core.ProcessorCandidate, 0, len(processors))
+
+	for _, p := range processors {
+		candidate := core.ProcessorCandidate{Name: p.Name, Fee: p.Fee}
+		if ps.health != nil {
+			candidate.Health, candidate.HealthKnown = ps.health.Snapshot(p.Name)
 		}
-		time.Sleep(5 * time.Millisecond)
+		candidates = append(candidates, candidate)
+	}
+	return candidates
+}
+
+// ProcessPayment envia o pagamento aos processadores na ordem da estratégia de roteamento.
+// owner é o worker que tem a reserva do pagamento, guardado com ele se o pagamento for estacionado.
+func (ps *PaymentService) ProcessPayment(ctx context.Context, p *domain.Payment, owner string) (*domain.Payment, error) {
+	ps.drainMeter.Mark()
+
+	p.RequestedAt = time.Now()
+	p.Strategy = ps.routing.Name()
+
+	var history []domain.PaymentAttempt
+	recordAttempt := func(processor string, err error) {
+		history = append(history, domain.PaymentAttempt{Processor: processor, Error: err.Error(), At: time.Now()})
+		p.LastError = err.Error()
 	}
 
-	p.Processor = "fallback"
-	processed := ps.sendPaymentRequest(ctx, p, ps.URL_FALLBACK_PROCESSOR)
-	if processed {
-		return p, nil
+	for i, name := range ps.routing.Plan(p, ps.candidates()) {
+		processor, ok := ps.processors.Get(name)
+		if !ok {
+			slog.Warn("estratégia de roteamento escolheu um processador desconhecido", "strategy", p.Strategy, "processor", name)
+			continue
+		}
+
+		attempts := 1
+		if i == 0 {
+			attempts = PRIMARY_PROCESSOR_ATTEMPTS
+		}
+
+		breaker := ps.breakers.Get(name)
+
+		p.Processor = name
+		for attempt := 0; attempt < attempts; attempt++ {
+			// Com o circuito aberto o processador é pulado sem gastar tentativas.
+			var token BreakerToken
+			if breaker != nil {
+				var allowed bool
+				if token, allowed = breaker.Allow(); !allowed {
+					recordAttempt(name, ErrCircuitOpen)
+					break
+				}
+			}
+
+			outcome, err := ps.sendPaymentRequest(ctx, p, processor)
+			p.Attempts++
+
+			// Antes de tentar de novo é preciso saber se o processador ficou com o pagamento,
+			// senão ele pode ser cobrado duas vezes.
+			status, requestedAt := PAYMENT_STATUS_NOT_FOUND, time.Time{}
+			switch outcome {
+			case OUTCOME_PROCESSED:
+				status = PAYMENT_STATUS_FOUND
+			case OUTCOME_AMBIGUOUS:
+				if status, requestedAt = ps.confirmPayment(ctx, p, processor); status == PAYMENT_STATUS_FOUND {
+					p.RequestedAt = requestedAt
+				}
+			}
+
+			// Um pagamento ambíguo que o processador confirma conta como sucesso do processador;
+			// uma recusa com 4xx não diz nada sobre a saúde dele.
+			if breaker != nil {
+				switch {
+				case status == PAYMENT_STATUS_FOUND:
+					breaker.OnSuccess(token)
+				case processorFailed(outcome, err):
+					breaker.OnFailure(token)
+				default:
+					breaker.Release(token)
+				}
+			}
+
+			if status == PAYMENT_STATUS_FOUND {
+				return p, nil
+			}
+			recordAttempt(name, err)
+
+			// Logo depois de um resultado ambíguo, um 404 não prova nada: o envio original ainda pode chegar ao processador.
+			// O pagamento fica estacionado e a reconciliação só confia no 404 depois de PARKED_NOT_FOUND_GRACE.
+			if outcome == OUTCOME_AMBIGUOUS {
+				if err := ps.parkPayment(ctx, p, owner, processor.Name, "resultado ambíguo sem confirmação"); err != nil {
+					return nil, fmt.Errorf("falha ao estacionar pagamento ambíguo: %w", err)
+				}
+				return nil, ErrPaymentParked
+			}
+
+			if attempt < attempts-1 {
+				time.Sleep(5 * time.Millisecond)
+			}
+		}
 	}
 
-	return nil, fmt.Errorf("all processors failed")
+	return nil, &ProcessingError{Attempts: history}
 }
 
-func (ps *PaymentService) GetSummary(ctx context.Context, from, to time.Time) (*domain.Summary, error) {
-	dSummaryItems, err := ps.repoPayment.GetSummaryByProcessor(ctx, "default", from, to)
+var ErrSummaryWindowTooLarge = errors.New("janela do resumo maior que a permitida")
+
+func (ps *PaymentService) GetSummary(ctx context.Context, from, to time.Time) (domain.Summary, error) {
+	if ps.maxSummaryWindow > 0 && to.Sub(from) > ps.maxSummaryWindow {
+		return nil, fmt.Errorf("%w: %s (máximo %s)", ErrSummaryWindowTooLarge, to.Sub(from), ps.maxSummaryWindow)
+	}
+
+	summary, err := ps.summaryByProcessor(ctx, from, to)
 	if err != nil {
 		return nil, err
 	}
 
-	fSummaryItems, err := ps.repoPayment.GetSummaryByProcessor(ctx, "fallback", from, to)
+	if ps.summaryCompatMode {
+		return ps.compatSummary(summary), nil
+	}
+	return summary, nil
+}
+
+// summaryByProcessor consulta o resumo de todos os processadores, numa única consulta quando o repositório permite.
+func (ps *PaymentService) summaryByProcessor(ctx context.Context, from, to time.Time) (domain.Summary, error) {
+	processors := ps.processors.All()
+
+	if batch, ok := ps.repoPayment.(core.BatchSummaryRepositoryInterface); ok {
+		names := make([]string, len(processors))
+		for i, p := range processors {
+			names[i] = p.Name
+		}
+		return batch.GetSummaryByProcessors(ctx, names, from, to)
+	}
+
+	summary := make(domain.Summary, len(processors))
+	for _, p := range processors {
+		item, err := ps.repoPayment.GetSummaryByProcessor(ctx, p.Name, from, to)
+		if err != nil {
+			return nil, err
+		}
+		summary[p.Name] = *item
+	}
+	return summary, nil
+}
+
+// compatSummary converte o resumo para o formato com apenas "default" e "fallback":
+// o processador de maior prioridade vira o default e os demais são somados no fallback.
+func (ps *PaymentService) compatSummary(summary domain.Summary) domain.Summary {
+	primary := ps.processors.Primary().Name
+	compat := domain.Summary{
+		domain.PROCESSOR_DEFAULT:  summary[primary],
+		domain.PROCESSOR_FALLBACK: {},
+	}
+
+	for name, item := range summary {
+		if name == primary {
+			continue
+		}
+		fallback := compat[domain.PROCESSOR_FALLBACK]
+		fallback.TotalRequests += item.TotalRequests
+		fallback.TotalAmount += item.TotalAmount
+		compat[domain.PROCESSOR_FALLBACK] = fallback
+	}
+
+	return compat
+}
+
+func (ps *PaymentService) GetRoutingStats(ctx context.Context) (map[string]map[string]domain.SummaryItem, error) {
+	return ps.repoPayment.GetRoutingStats(ctx)
+}
+
+func (ps *PaymentService) GetCircuitBreakers() []BreakerStatus {
+	return ps.breakers.Statuses()
+}
+
+// ResetReport resume o que foi descartado pelo reset, além dos pagamentos gravados.
+type ResetReport struct {
+	Queued      int64 `json:"queued"`
+	DeadLetters int64 `json:"deadLetters"`
+	Parked      int64 `json:"parked"`
+	Outbox      int64 `json:"outbox"`
+}
+
+// ResetState apaga os pagamentos, seus registros, suas reservas e as respostas por Idempotency-Key, descarta a fila, a dead-letter, os pagamentos estacionados
+// e o outbox e fecha os circuit breakers, deixando o estado como o de uma instância recém-iniciada.
+// Com um ResetNotifier as outras instâncias são avisadas e descartam o estado local delas (veja RunResetListener).
+// Pagamentos que já estavam com um worker ainda podem ser gravados depois do reset.
+func (ps *PaymentService) ResetState(ctx context.Context) (ResetReport, error) {
+	var (
+		report ResetReport
+		err    error
+	)
+
+	// Os outros armazenamentos são esvaziados antes do repositório: no Valkey o reset dele apaga todas as chaves
+	// de transações, inclusive a fila, a dead-letter e os estacionados, e as contagens do relatório sairiam zeradas.
+	if report.Queued, err = ps.paymentQueue.Purge(ctx); err != nil {
+		return report, fmt.Errorf("falha ao esvaziar a fila: %w", err)
+	}
+	if ps.repoDeadLetter != nil {
+		if report.DeadLetters, err = ps.repoDeadLetter.PurgeDeadLetters(ctx); err != nil {
+			return report, fmt.Errorf("falha ao esvaziar a dead-letter: %w", err)
+		}
+	}
+	if ps.repoParked != nil {
+		if report.Parked, err = ps.repoParked.PurgeParkedPayments(ctx); err != nil {
+			return report, fmt.Errorf("falha ao esvaziar os pagamentos estacionados: %w", err)
+		}
+	}
+	if ps.repoClaims != nil {
+		if err := ps.repoClaims.ResetClaims(ctx); err != nil {
+			return report, fmt.Errorf("falha ao apagar as reservas de pagamento: %w", err)
+		}
+	}
+	if ps.repoIdempotency != nil {
+		if err := ps.repoIdempotency.ResetIdempotencyKeys(ctx); err != nil {
+			return report, fmt.Errorf("falha ao apagar as respostas por Idempotency-Key: %w", err)
+		}
+	}
+	if ps.outbox != nil {
+		if report.Outbox, err = ps.outbox.Purge(ctx); err != nil {
+			return report, fmt.Errorf("falha ao esvaziar o outbox: %w", err)
+		}
+	}
+	if err := ps.repoPayment.ResetState(ctx); err != nil {
+		return report, err
+	}
+	// No Valkey o reset do repositório também apaga o stream da fila; Purge o recria vazio,
+	// descartando junto o que foi enfileirado durante o reset.
+	queued, err := ps.paymentQueue.Purge(ctx)
 	if err != nil {
-		return nil, err
+		return report, fmt.Errorf("falha ao recriar a fila: %w", err)
 	}
+	report.Queued += queued
+	ps.breakers.Reset()
 
-	return &domain.Summary{Default: *dSummaryItems, Fallback: *fSummaryItems}, nil
+	// As outras instâncias ainda têm a própria fila em memória, o outbox e os circuit breakers.
+	if ps.resets != nil {
+		if err := ps.resets.PublishReset(ctx, ps.instanceID); err != nil {
+			slog.Warn("falha ao avisar as outras instâncias do reset", "error", err.Error())
+		}
+	}
 
+	return report, nil
 }
 
-func (ps *PaymentService) ResetState(ctx context.Context) error {
-	return ps.repoPayment.ResetState(ctx)
+// SavePayment grava o pagamento processado; created é false quando ele já estava gravado.
+// Se o repositório falhar, o pagamento vai para o outbox e o erro retornado contém ErrSaveDeferred:
+// o pagamento será gravado depois e não precisa ser reenviado.
+func (ps *PaymentService) SavePayment(ctx context.Context, payment *domain.Payment) (created bool, err error) {
+	created, err = ps.repoPayment.SavePayment(ctx, payment)
+	if err == nil || ps.outbox == nil {
+		return created, err
+	}
+
+	if outboxErr := ps.outbox.Add(ctx, payment, err); outboxErr != nil {
+		return false, errors.Join(err, outboxErr)
+	}
+	return false, fmt.Errorf("%w: %w", ErrSaveDeferred, err)
 }
 
-func (ps *PaymentService) SavePayment(ctx context.Context, payment *domain.Payment) error {
-	if err := ps.repoPayment.SavePayment(ctx, payment); err != nil {
-		return err
+// GetOutboxStatus mostra os pagamentos que aguardam para ser gravados; sem outbox tudo fica zerado.
+func (ps *PaymentService) GetOutboxStatus() OutboxStatus {
+	if ps.outbox == nil {
+		return OutboxStatus{}
 	}
-	return nil
+	return ps.outbox.Status()
 }
@@ -0,0 +1,218 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nicolasmmb/go-rinha-backend-2025/internal/core"
+	"github.com/nicolasmmb/go-rinha-backend-2025/internal/domain"
+	"github.com/nicolasmmb/go-rinha-backend-2025/internal/model"
+	"github.com/nicolasmmb/go-rinha-backend-2025/internal/repository/memory"
+)
+
+// Timeout dos envios ao processador falso; o envio que o ultrapassa é ambíguo.
+const TEST_PROCESSOR_TIMEOUT = 50 * time.Millisecond
+
+// STATUS_HANG faz o processador falso segurar a resposta até o envio estourar o timeout.
+const STATUS_HANG = -1
+
+// fakeProcessor responde POST /payments com postStatus e GET /payments/{id} com getStatus,
+// devolvendo requestedAt quando o pagamento é encontrado.
+type fakeProcessor struct {
+	postStatus  int
+	getStatus   int
+	requestedAt time.Time
+
+	posts atomic.Int64
+}
+
+func (f *fakeProcessor) start(t *testing.T) domain.Processor {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /payments", func(w http.ResponseWriter, r *http.Request) {
+		f.posts.Add(1)
+		// Com o corpo lido o servidor percebe quando o cliente desiste e cancela o contexto.
+		io.Copy(io.Discard, r.Body)
+		if f.postStatus == STATUS_HANG {
+			<-r.Context().Done()
+			return
+		}
+		w.WriteHeader(f.postStatus)
+	})
+	mux.HandleFunc("GET /payments/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if f.getStatus != http.StatusOK {
+			w.WriteHeader(f.getStatus)
+			return
+		}
+		json.NewEncoder(w).Encode(model.ProcessorPaymentRequest{CorrelationID: r.PathValue("id"), Amount: 1990, RequestedAt: f.requestedAt})
+	})
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	return domain.Processor{Name: domain.PROCESSOR_DEFAULT, PaymentURL: server.URL + "/payments", Timeout: TEST_PROCESSOR_TIMEOUT}
+}
+
+// newTestPaymentService monta o serviço com repositórios em memória e um único processador.
+func newTestPaymentService(t *testing.T, processor domain.Processor) (*PaymentService, core.ReconciliationRepositoryInterface) {
+	t.Helper()
+
+	processors, err := NewProcessorRegistry([]domain.Processor{processor})
+	if err != nil {
+		t.Fatal(err)
+	}
+	routing, err := NewRoutingStrategy(ROUTING_DEFAULT_FIRST, RoutingOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parked := memory.NewReconciliationRepository()
+	svc := NewPaymentService(PaymentServiceOptions{
+		PaymentRepository:        memory.NewPaymentsRepository(),
+		ReconciliationRepository: parked,
+		Processors:               processors,
+		Routing:                  routing,
+		Breakers:                 NewCircuitBreakers([]string{processor.Name}, BreakerConfig{FailureThreshold: 100, OpenDuration: time.Second}),
+		InstanceID:               "test",
+	})
+	return svc, parked
+}
+
+func TestProcessPayment(t *testing.T) {
+	processorRequestedAt := time.Date(2025, 7, 15, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		processor *fakeProcessor
+		// Erro esperado: nil, ErrPaymentParked ou um *ProcessingError.
+		err   error
+		posts int64
+		// requestedAt esperado no pagamento confirmado; zero para não conferir.
+		requestedAt time.Time
+	}{
+		{
+			name:      "confirmado",
+			processor: &fakeProcessor{postStatus: http.StatusOK},
+			posts:     1,
+		},
+		{
+			name:        "resposta ambígua confirmada pelo processador",
+			processor:   &fakeProcessor{postStatus: http.StatusUnprocessableEntity, getStatus: http.StatusOK, requestedAt: processorRequestedAt},
+			posts:       1,
+			requestedAt: processorRequestedAt,
+		},
+		{
+			name:        "timeout confirmado pelo processador",
+			processor:   &fakeProcessor{postStatus: STATUS_HANG, getStatus: http.StatusOK, requestedAt: processorRequestedAt},
+			posts:       1,
+			requestedAt: processorRequestedAt,
+		},
+		{
+			name:      "resposta ambígua não encontrada é estacionada",
+			processor: &fakeProcessor{postStatus: http.StatusUnprocessableEntity, getStatus: http.StatusNotFound},
+			err:       ErrPaymentParked,
+			posts:     1,
+		},
+		{
+			name:      "erro na confirmação é estacionado",
+			processor: &fakeProcessor{postStatus: STATUS_HANG, getStatus: http.StatusInternalServerError},
+			err:       ErrPaymentParked,
+			posts:     1,
+		},
+		{
+			name:      "recusado esgota as tentativas",
+			processor: &fakeProcessor{postStatus: http.StatusInternalServerError},
+			err:       &ProcessingError{},
+			posts:     PRIMARY_PROCESSOR_ATTEMPTS,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			processor := tt.processor.start(t)
+			svc, parked := newTestPaymentService(t, processor)
+
+			payment := &domain.Payment{CorrelationId: "4a7901b8-7d26-4d9d-aa19-4dc1c7cf60b3", Amount: 1990}
+			processed, err := svc.ProcessPayment(t.Context(), payment, "test/1")
+
+			var processingErr *ProcessingError
+			switch {
+			case tt.err == nil:
+				if err != nil {
+					t.Fatalf("erro inesperado: %v", err)
+				}
+				if processed.Processor != processor.Name {
+					t.Fatalf("processado por %q, esperava %q", processed.Processor, processor.Name)
+				}
+				if !tt.requestedAt.IsZero() && !processed.RequestedAt.Equal(tt.requestedAt) {
+					t.Fatalf("requestedAt = %s, esperava o do processador %s", processed.RequestedAt, tt.requestedAt)
+				}
+			case errors.As(tt.err, &processingErr):
+				if !errors.As(err, &processingErr) || len(processingErr.Attempts) != int(tt.posts) {
+					t.Fatalf("erro = %v, esperava ProcessingError com %d tentativas", err, tt.posts)
+				}
+			default:
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("erro = %v, esperava %v", err, tt.err)
+				}
+			}
+
+			if posts := tt.processor.posts.Load(); posts != tt.posts {
+				t.Fatalf("%d envios ao processador, esperava %d", posts, tt.posts)
+			}
+
+			list, err := parked.ListParkedPayments(t.Context())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if errors.Is(tt.err, ErrPaymentParked) {
+				if len(list) != 1 || list[0].ClaimOwner != "test/1" || list[0].Payment.CorrelationId != payment.CorrelationId {
+					t.Fatalf("estacionados = %+v, esperava o pagamento com a reserva de test/1", list)
+				}
+			} else if len(list) != 0 {
+				t.Fatalf("estacionados = %+v, esperava nenhum", list)
+			}
+		})
+	}
+}
+
+func TestConfirmPayment(t *testing.T) {
+	processorRequestedAt := time.Date(2025, 7, 15, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name        string
+		getStatus   int
+		closed      bool
+		status      paymentStatus
+		requestedAt time.Time
+	}{
+		{name: "encontrado", getStatus: http.StatusOK, status: PAYMENT_STATUS_FOUND, requestedAt: processorRequestedAt},
+		{name: "não encontrado", getStatus: http.StatusNotFound, status: PAYMENT_STATUS_NOT_FOUND},
+		{name: "erro do processador", getStatus: http.StatusInternalServerError, status: PAYMENT_STATUS_UNKNOWN},
+		{name: "processador fora do ar", closed: true, status: PAYMENT_STATUS_UNKNOWN},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &fakeProcessor{getStatus: tt.getStatus, requestedAt: processorRequestedAt}
+			processor := fake.start(t)
+			svc, _ := newTestPaymentService(t, processor)
+			if tt.closed {
+				processor.PaymentURL = "http://127.0.0.1:1/payments"
+			}
+
+			payment := &domain.Payment{CorrelationId: "4a7901b8-7d26-4d9d-aa19-4dc1c7cf60b3", Amount: 1990, RequestedAt: time.Now()}
+			status, requestedAt := svc.confirmPayment(t.Context(), payment, processor)
+			if status != tt.status || !requestedAt.Equal(tt.requestedAt) {
+				t.Fatalf("confirmPayment = %v, %s, esperava %v, %s", status, requestedAt, tt.status, tt.requestedAt)
+			}
+		})
+	}
+}
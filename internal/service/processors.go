@@ -0,0 +1,75 @@
+package service
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/nicolasmmb/go-rinha-backend-2025/internal/domain"
+)
+
+// ProcessorRegistry mantém os processadores configurados, ordenados por prioridade.
+type ProcessorRegistry struct {
+	processors []domain.Processor
+	byName     map[string]domain.Processor
+}
+
+func NewProcessorRegistry(processors []domain.Processor) (*ProcessorRegistry, error) {
+	if len(processors) == 0 {
+		return nil, fmt.Errorf("nenhum processador de pagamentos configurado")
+	}
+
+	byName := make(map[string]domain.Processor, len(processors))
+	for _, p := range processors {
+		if p.Name == "" {
+			return nil, fmt.Errorf("processador sem nome configurado")
+		}
+		if p.PaymentURL == "" {
+			return nil, fmt.Errorf("processador %q sem URL de pagamento", p.Name)
+		}
+		if _, exists := byName[p.Name]; exists {
+			return nil, fmt.Errorf("processador %q configurado mais de uma vez", p.Name)
+		}
+		byName[p.Name] = p
+	}
+
+	ordered := slices.Clone(processors)
+	slices.SortStableFunc(ordered, func(a, b domain.Processor) int {
+		return a.Priority - b.Priority
+	})
+
+	return &ProcessorRegistry{processors: ordered, byName: byName}, nil
+}
+
+// All retorna os processadores em ordem de prioridade.
+func (r *ProcessorRegistry) All() []domain.Processor {
+	return r.processors
+}
+
+func (r *ProcessorRegistry) Get(name string) (domain.Processor, bool) {
+	p, ok := r.byName[name]
+	return p, ok
+}
+
+// Primary retorna o processador de maior prioridade.
+func (r *ProcessorRegistry) Primary() domain.Processor {
+	return r.processors[0]
+}
+
+func (r *ProcessorRegistry) Names() []string {
+	names := make([]string, 0, len(r.processors))
+	for _, p := range r.processors {
+		names = append(names, p.Name)
+	}
+	return names
+}
+
+// HealthURLs retorna a URL de health-check de cada processador que possui uma.
+func (r *ProcessorRegistry) HealthURLs() map[string]string {
+	urls := make(map[string]string, len(r.processors))
+	for _, p := range r.processors {
+		if p.HealthURL != "" {
+			urls[p.Name] = p.HealthURL
+		}
+	}
+	return urls
+}
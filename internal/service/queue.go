@@ -0,0 +1,93 @@
+package service
+
+import (
+	"context"
+	"sync"
+
+	"github.com/nicolasmmb/go-rinha-backend-2025/internal/core"
+	"github.com/nicolasmmb/go-rinha-backend-2025/internal/domain"
+)
+
+// channelQueue é a fila em memória, baseada em um canal com buffer.
+// Os pagamentos não sobrevivem a um restart da instância.
+type channelQueue struct {
+	payments chan domain.Payment
+
+	// Protege o envio no canal contra o fechamento concorrente em Close.
+	mu     sync.RWMutex
+	closed bool
+}
+
+func NewChannelQueue(size int) core.PaymentQueueInterface {
+	return &channelQueue{payments: make(chan domain.Payment, size)}
+}
+
+func (q *channelQueue) Enqueue(_ context.Context, payment *domain.Payment) error {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	if q.closed {
+		return core.ErrQueueClosed
+	}
+
+	select {
+	case q.payments <- *payment:
+		return nil
+	default:
+		return core.ErrQueueFull
+	}
+}
+
+func (q *channelQueue) Dequeue(ctx context.Context) (*core.QueueMessage, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case payment, ok := <-q.payments:
+		if !ok {
+			return nil, core.ErrQueueClosed
+		}
+		return &core.QueueMessage{ID: payment.CorrelationId, Payment: payment}, nil
+	}
+}
+
+func (q *channelQueue) Ack(context.Context, *core.QueueMessage) error {
+	return nil
+}
+
+func (q *channelQueue) Len(context.Context) (int64, error) {
+	return int64(len(q.payments)), nil
+}
+
+func (q *channelQueue) Close() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if !q.closed {
+		q.closed = true
+		close(q.payments)
+	}
+}
+
+func (q *channelQueue) TakeRemaining() []domain.Payment {
+	var remaining []domain.Payment
+
+	for {
+		select {
+		case payment, ok := <-q.payments:
+			if !ok {
+				return remaining
+			}
+			remaining = append(remaining, payment)
+		default:
+			return remaining
+		}
+	}
+}
+
+func (q *channelQueue) Redelivers() bool {
+	return false
+}
+
+func (q *channelQueue) Purge(context.Context) (int64, error) {
+	return int64(len(q.TakeRemaining())), nil
+}
@@ -0,0 +1,165 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/nicolasmmb/go-rinha-backend-2025/internal/domain"
+	"github.com/nicolasmmb/go-rinha-backend-2025/internal/model"
+)
+
+// ErrPaymentParked indica que o pagamento foi estacionado para reconciliação posterior.
+var ErrPaymentParked = errors.New("pagamento estacionado para reconciliação")
+
+// Tempo máximo da consulta de status de um pagamento no processador.
+const PAYMENT_STATUS_TIMEOUT = 2 * time.Second
+
+// Tempo, a partir do estacionamento, antes do qual um 404 do processador não basta para reenviar o pagamento:
+// até lá o envio ambíguo ainda pode estar chegando ao processador.
+const PARKED_NOT_FOUND_GRACE = 10 * time.Second
+
+// paymentStatus é o resultado da consulta GET /payments/{correlationId} em um processador.
+type paymentStatus int
+
+const (
+	PAYMENT_STATUS_FOUND paymentStatus = iota
+	PAYMENT_STATUS_NOT_FOUND
+	PAYMENT_STATUS_UNKNOWN
+)
+
+// confirmPayment consulta o processador para descobrir se ele ficou com o pagamento.
+// Quando encontrado, retorna também o requestedAt registrado pelo processador.
+func (ps *PaymentService) confirmPayment(ctx context.Context, payment *domain.Payment, processor domain.Processor) (paymentStatus, time.Time) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), PAYMENT_STATUS_TIMEOUT)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, processor.PaymentURL+"/"+payment.CorrelationId, nil)
+	if err != nil {
+		return PAYMENT_STATUS_UNKNOWN, time.Time{}
+	}
+
+	resp, err := ps.httpClient.Do(req)
+	if err != nil {
+		slog.Warn("falha ao consultar status do pagamento", "processor", processor.Name, "correlationId", payment.CorrelationId, "error", err.Error())
+		return PAYMENT_STATUS_UNKNOWN, time.Time{}
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		var status model.ProcessorPaymentRequest
+		if err := json.NewDecoder(resp.Body).Decode(&status); err != nil || status.RequestedAt.IsZero() {
+			return PAYMENT_STATUS_FOUND, payment.RequestedAt
+		}
+		return PAYMENT_STATUS_FOUND, status.RequestedAt
+
+	case http.StatusNotFound:
+		io.Copy(io.Discard, resp.Body)
+		return PAYMENT_STATUS_NOT_FOUND, time.Time{}
+
+	default:
+		io.Copy(io.Discard, resp.Body)
+		return PAYMENT_STATUS_UNKNOWN, time.Time{}
+	}
+}
+
+// parkPayment estaciona o pagamento junto com o dono da reserva, que a reconciliação libera antes de reenfileirá-lo.
+func (ps *PaymentService) parkPayment(ctx context.Context, payment *domain.Payment, owner, processor, reason string) error {
+	if ps.repoParked == nil {
+		return fmt.Errorf("repositório de reconciliação não configurado")
+	}
+
+	parked := &domain.ParkedPayment{
+		Payment:    *payment,
+		Processors: []string{processor},
+		ParkedAt:   time.Now(),
+		LastError:  reason,
+		ClaimOwner: owner,
+	}
+
+	slog.Warn("pagamento estacionado para reconciliação", "correlationId", payment.CorrelationId, "processor", processor, "reason", reason)
+	if err := ps.repoParked.ParkPayment(context.WithoutCancel(ctx), parked); err != nil {
+		return err
+	}
+
+	ps.TransitionPayment(ctx, payment, domain.PAYMENT_STATUS_PARKED)
+	return nil
+}
+
+// ReconcileParked tenta resolver cada pagamento estacionado: se algum processador confirmar o pagamento
+// ele é salvo sob esse processador; se todos negarem, ele volta para a fila; caso contrário continua estacionado.
+func (ps *PaymentService) ReconcileParked(ctx context.Context) error {
+	if ps.repoParked == nil {
+		return nil
+	}
+
+	parkedPayments, err := ps.repoParked.ListParkedPayments(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, parked := range parkedPayments {
+		if err := ps.reconcile(ctx, parked); err != nil {
+			slog.Warn("falha ao reconciliar pagamento", "correlationId", parked.Payment.CorrelationId, "error", err.Error())
+		}
+	}
+	return nil
+}
+
+func (ps *PaymentService) reconcile(ctx context.Context, parked domain.ParkedPayment) error {
+	payment := parked.Payment
+	unknown := false
+
+	for _, name := range parked.Processors {
+		processor, ok := ps.processors.Get(name)
+		if !ok {
+			continue
+		}
+
+		status, requestedAt := ps.confirmPayment(ctx, &payment, processor)
+		switch status {
+		case PAYMENT_STATUS_FOUND:
+			payment.Processor = name
+			payment.RequestedAt = requestedAt
+			// Guardado no outbox, o pagamento será gravado depois e pode sair dos estacionados.
+			if _, err := ps.SavePayment(ctx, &payment); err != nil && !errors.Is(err, ErrSaveDeferred) {
+				return err
+			}
+			ps.CompleteClaim(ctx, &payment, ps.instanceID+"/reconcile")
+			slog.Info("pagamento reconciliado", "correlationId", payment.CorrelationId, "processor", name)
+			return ps.repoParked.RemoveParkedPayment(ctx, payment.CorrelationId)
+
+		case PAYMENT_STATUS_NOT_FOUND:
+			if time.Since(parked.ParkedAt) < PARKED_NOT_FOUND_GRACE {
+				unknown = true
+			}
+
+		case PAYMENT_STATUS_UNKNOWN:
+			unknown = true
+		}
+	}
+
+	if unknown {
+		parked.Attempts++
+		parked.LastError = "status ainda não confirmado"
+		return ps.repoParked.ParkPayment(ctx, &parked)
+	}
+
+	// Nenhum processador ficou com o pagamento: é seguro enviá-lo novamente. A reserva do worker que o estacionou
+	// é liberada antes, senão o worker que o receber da fila o encontraria reservado por outro dono.
+	payment.Processor = ""
+	ps.ReleaseClaim(ctx, &payment, parked.ClaimOwner)
+	if err := ps.SendPaymentToQueue(ctx, &payment); err != nil {
+		// O pagamento continua estacionado.
+		ps.TransitionPayment(ctx, &parked.Payment, domain.PAYMENT_STATUS_PARKED)
+		return err
+	}
+	slog.Info("pagamento não encontrado nos processadores, reenfileirado", "correlationId", payment.CorrelationId, "processors", parked.Processors)
+	return ps.repoParked.RemoveParkedPayment(ctx, payment.CorrelationId)
+}
@@ -0,0 +1,40 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"github.com/nicolasmmb/go-rinha-backend-2025/internal/domain"
+)
+
+// TransitionPayment leva o registro consultável do pagamento para status. O registro é informativo:
+// transições recusadas pela máquina de estados e falhas ao gravar são registradas no log e retornadas,
+// cabendo a quem chama decidir se o processamento continua.
+func (ps *PaymentService) TransitionPayment(ctx context.Context, payment *domain.Payment, status domain.PaymentStatus) error {
+	err := ps.repoPayment.TransitionPayment(context.WithoutCancel(ctx), payment, status)
+
+	var transitionErr *domain.TransitionError
+	switch {
+	case errors.As(err, &transitionErr):
+		slog.Warn("transição de status inválida recusada", "correlationId", payment.CorrelationId, "from", transitionErr.From, "to", transitionErr.To)
+	case err != nil:
+		slog.Warn("falha ao gravar o registro do pagamento", "correlationId", payment.CorrelationId, "status", status, "error", err.Error())
+	}
+	return err
+}
+
+// AcceptPayment admite um pagamento recebido pela API na fila.
+// Se a fila recusar, o registro passa a indicar que o cliente precisa reenviá-lo.
+func (ps *PaymentService) AcceptPayment(ctx context.Context, payment *domain.Payment) error {
+	if err := ps.SendPaymentToQueue(ctx, payment); err != nil {
+		ps.TransitionPayment(ctx, payment, domain.PAYMENT_STATUS_REJECTED)
+		return err
+	}
+	return nil
+}
+
+// GetPayment retorna o registro do pagamento; domain.ErrPaymentNotFound se ele nunca foi aceito.
+func (ps *PaymentService) GetPayment(ctx context.Context, correlationId string) (*domain.PaymentRecord, error) {
+	return ps.repoPayment.GetPaymentRecord(ctx, correlationId)
+}
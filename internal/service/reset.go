@@ -0,0 +1,50 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+)
+
+// RunResetListener descarta o estado local desta instância sempre que outra anuncia um reset, até o contexto acabar.
+func (ps *PaymentService) RunResetListener(ctx context.Context) {
+	if ps.resets == nil {
+		return
+	}
+
+	for origin := range ps.resets.SubscribeReset(ctx) {
+		if origin == ps.instanceID {
+			continue
+		}
+
+		report, err := ps.resetLocalState(ctx)
+		if err != nil {
+			slog.Error("falha ao aplicar o reset de outra instância", "origin", origin, "error", err.Error())
+			continue
+		}
+		slog.Info("estado local descartado pelo reset de outra instância", "origin", origin, "queued", report.Queued, "outbox", report.Outbox)
+	}
+}
+
+// resetLocalState descarta o que só esta instância guarda: a fila em memória, o outbox e os circuit breakers.
+// A fila compartilhada e os repositórios já foram esvaziados pela instância que fez o reset.
+func (ps *PaymentService) resetLocalState(ctx context.Context) (ResetReport, error) {
+	var (
+		report ResetReport
+		err    error
+	)
+
+	if !ps.paymentQueue.Redelivers() {
+		if report.Queued, err = ps.paymentQueue.Purge(ctx); err != nil {
+			return report, fmt.Errorf("falha ao esvaziar a fila: %w", err)
+		}
+	}
+	if ps.outbox != nil {
+		if report.Outbox, err = ps.outbox.Purge(ctx); err != nil {
+			return report, fmt.Errorf("falha ao esvaziar o outbox: %w", err)
+		}
+	}
+	ps.breakers.Reset()
+
+	return report, nil
+}
@@ -0,0 +1,163 @@
+package service
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math"
+	"slices"
+
+	"github.com/nicolasmmb/go-rinha-backend-2025/internal/core"
+	"github.com/nicolasmmb/go-rinha-backend-2025/internal/domain"
+)
+
+const (
+	ROUTING_DEFAULT_FIRST  = "default-first"
+	ROUTING_HEALTH_AWARE   = "health-aware"
+	ROUTING_LOWEST_LATENCY = "lowest-latency"
+	ROUTING_COST_WEIGHTED  = "cost-weighted"
+	ROUTING_PERCENT_SPLIT  = "percent-split"
+)
+
+type RoutingOptions struct {
+	// Percentual (0-100) de pagamentos enviados primeiro ao processador de maior prioridade na estratégia percent-split.
+	SplitPrimaryPercent int
+}
+
+// NewRoutingStrategy cria uma das estratégias de roteamento embutidas a partir do nome configurado.
+func NewRoutingStrategy(name string, opts RoutingOptions) (core.RoutingStrategy, error) {
+	switch name {
+	case ROUTING_DEFAULT_FIRST:
+		return defaultFirstStrategy{}, nil
+	case ROUTING_HEALTH_AWARE:
+		return healthAwareStrategy{}, nil
+	case ROUTING_LOWEST_LATENCY:
+		return lowestLatencyStrategy{}, nil
+	case ROUTING_COST_WEIGHTED:
+		return costWeightedStrategy{}, nil
+	case ROUTING_PERCENT_SPLIT:
+		if opts.SplitPrimaryPercent < 0 || opts.SplitPrimaryPercent > 100 {
+			return nil, fmt.Errorf("percentual de split inválido: %d", opts.SplitPrimaryPercent)
+		}
+		return percentSplitStrategy{primaryPercent: opts.SplitPrimaryPercent}, nil
+	default:
+		return nil, fmt.Errorf("estratégia de roteamento desconhecida: %q", name)
+	}
+}
+
+func candidateNames(candidates []core.ProcessorCandidate) []string {
+	names := make([]string, 0, len(candidates))
+	for _, c := range candidates {
+		names = append(names, c.Name)
+	}
+	return names
+}
+
+// sortCandidates ordena os candidatos pela chave informada, sempre deixando os sabidamente falhos para o final.
+func sortCandidates(candidates []core.ProcessorCandidate, key func(core.ProcessorCandidate) float64) []core.ProcessorCandidate {
+	ordered := slices.Clone(candidates)
+	slices.SortStableFunc(ordered, func(a, b core.ProcessorCandidate) int {
+		if a.IsFailing() != b.IsFailing() {
+			if a.IsFailing() {
+				return 1
+			}
+			return -1
+		}
+		return compareFloat(key(a), key(b))
+	})
+	return ordered
+}
+
+// defaultFirstStrategy sempre tenta os processadores em ordem de prioridade, ignorando o health.
+type defaultFirstStrategy struct{}
+
+func (defaultFirstStrategy) Name() string { return ROUTING_DEFAULT_FIRST }
+
+func (defaultFirstStrategy) Plan(_ *domain.Payment, candidates []core.ProcessorCandidate) []string {
+	return candidateNames(candidates)
+}
+
+// healthAwareStrategy pula processadores sabidamente falhos, a menos que todos estejam falhando.
+type healthAwareStrategy struct{}
+
+func (healthAwareStrategy) Name() string { return ROUTING_HEALTH_AWARE }
+
+func (healthAwareStrategy) Plan(_ *domain.Payment, candidates []core.ProcessorCandidate) []string {
+	var healthy []string
+	for _, c := range candidates {
+		if !c.IsFailing() {
+			healthy = append(healthy, c.Name)
+		}
+	}
+
+	if len(healthy) == 0 {
+		return candidateNames(candidates)
+	}
+	return healthy
+}
+
+// lowestLatencyStrategy prioriza o processador saudável com o menor minResponseTime.
+// Processadores sem health conhecido vão depois dos conhecidos.
+type lowestLatencyStrategy struct{}
+
+func (lowestLatencyStrategy) Name() string { return ROUTING_LOWEST_LATENCY }
+
+func (lowestLatencyStrategy) Plan(_ *domain.Payment, candidates []core.ProcessorCandidate) []string {
+	return candidateNames(sortCandidates(candidates, latencyOf))
+}
+
+func latencyOf(c core.ProcessorCandidate) float64 {
+	if !c.HealthKnown {
+		return math.Inf(1)
+	}
+	return float64(c.Health.MinResponseTime)
+}
+
+// costWeightedStrategy prioriza o menor custo efetivo: a taxa do processador
+// acrescida de 100% a cada segundo de minResponseTime.
+type costWeightedStrategy struct{}
+
+func (costWeightedStrategy) Name() string { return ROUTING_COST_WEIGHTED }
+
+func (costWeightedStrategy) Plan(_ *domain.Payment, candidates []core.ProcessorCandidate) []string {
+	return candidateNames(sortCandidates(candidates, costOf))
+}
+
+func costOf(c core.ProcessorCandidate) float64 {
+	cost := c.Fee
+	if c.HealthKnown {
+		cost *= 1 + float64(c.Health.MinResponseTime)/1000
+	}
+	return cost
+}
+
+// percentSplitStrategy envia um percentual fixo dos pagamentos primeiro ao processador de maior prioridade
+// e o restante primeiro aos demais, deixando o de maior prioridade por último.
+// A divisão usa o hash do correlationId, então um mesmo pagamento sempre cai no mesmo lado.
+type percentSplitStrategy struct {
+	primaryPercent int
+}
+
+func (percentSplitStrategy) Name() string { return ROUTING_PERCENT_SPLIT }
+
+func (s percentSplitStrategy) Plan(payment *domain.Payment, candidates []core.ProcessorCandidate) []string {
+	names := candidateNames(candidates)
+
+	h := fnv.New32a()
+	h.Write([]byte(payment.CorrelationId))
+	if int(h.Sum32()%100) < s.primaryPercent || len(names) < 2 {
+		return names
+	}
+
+	return append(names[1:], names[0])
+}
+
+func compareFloat(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,83 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+)
+
+// DrainReport resume o destino dos pagamentos que estavam na fila no desligamento.
+type DrainReport struct {
+	Drained   int64
+	Persisted int64
+	Lost      int64
+}
+
+// StopAccepting fecha a fila para novos pagamentos e retorna quantos ainda aguardam processamento.
+func (ps *PaymentService) StopAccepting(ctx context.Context) int64 {
+	pending, err := ps.paymentQueue.Len(ctx)
+	if err != nil {
+		slog.Warn("falha ao obter o tamanho da fila", "error", err.Error())
+	}
+
+	ps.paymentQueue.Close()
+	return pending
+}
+
+// PersistPending guarda os pagamentos que os workers não conseguiram drenar a tempo,
+// para que sejam retomados no próximo boot. pendingAtClose é o valor retornado por StopAccepting.
+func (ps *PaymentService) PersistPending(ctx context.Context, pendingAtClose int64) DrainReport {
+	remaining := ps.paymentQueue.TakeRemaining()
+
+	// Filas duráveis mantêm os pagamentos não processados por conta própria.
+	durable, err := ps.paymentQueue.Len(ctx)
+	if err != nil || len(remaining) > 0 {
+		durable = 0
+	}
+
+	report := DrainReport{
+		Drained:   max(pendingAtClose-int64(len(remaining))-durable, 0),
+		Persisted: durable,
+	}
+
+	if len(remaining) == 0 {
+		return report
+	}
+
+	if ps.repoSpill == nil {
+		report.Lost = int64(len(remaining))
+		return report
+	}
+
+	if err := ps.repoSpill.SaveSpilled(ctx, remaining); err != nil {
+		slog.Error("falha ao guardar os pagamentos restantes da fila", "count", len(remaining), "error", err.Error())
+		report.Lost = int64(len(remaining))
+		return report
+	}
+
+	report.Persisted += int64(len(remaining))
+	return report
+}
+
+// ResumeSpilled devolve para a fila os pagamentos guardados no último desligamento.
+// Pagamentos que não couberem na fila voltam a ser guardados.
+func (ps *PaymentService) ResumeSpilled(ctx context.Context) (int, error) {
+	if ps.repoSpill == nil {
+		return 0, nil
+	}
+
+	payments, err := ps.repoSpill.TakeSpilled(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	for i := range payments {
+		if err := ps.SendPaymentToQueue(ctx, &payments[i]); err != nil {
+			if saveErr := ps.repoSpill.SaveSpilled(ctx, payments[i:]); saveErr != nil {
+				return i, saveErr
+			}
+			return i, err
+		}
+	}
+
+	return len(payments), nil
+}
@@ -0,0 +1,163 @@
+package validation
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/nicolasmmb/go-rinha-backend-2025/internal/domain"
+)
+
+const (
+	// Tamanho máximo do corpo de um pagamento.
+	MAX_PAYMENT_BODY_BYTES = 1 << 10
+	// Casas decimais aceitas no valor de um pagamento.
+	MAX_AMOUNT_DECIMALS = domain.MONEY_DECIMALS
+)
+
+var (
+	ErrBodyTooLarge  = errors.New("request body too large")
+	ErrMalformedBody = errors.New("malformed JSON body")
+)
+
+// FieldError descreve um problema em um campo da requisição.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// FieldErrors é retornado quando uma ou mais regras de validação falham.
+type FieldErrors []FieldError
+
+func (e FieldErrors) Error() string {
+	messages := make([]string, 0, len(e))
+	for _, fe := range e {
+		messages = append(messages, fe.Field+": "+fe.Message)
+	}
+	return "invalid payment: " + strings.Join(messages, "; ")
+}
+
+func (e FieldErrors) has(field string) bool {
+	for _, fe := range e {
+		if fe.Field == field {
+			return true
+		}
+	}
+	return false
+}
+
+// PaymentInput é o pagamento como recebido do cliente, antes de ser validado.
+// Os ponteiros permitem diferenciar campos ausentes de campos com valor zero.
+type PaymentInput struct {
+	CorrelationId *string      `json:"correlationId"`
+	Amount        *json.Number `json:"amount"`
+}
+
+var paymentFields = map[string]bool{"correlationId": true, "amount": true}
+
+// ReadBody lê o corpo respeitando o limite de tamanho, retornando ErrBodyTooLarge se ele for excedido.
+func ReadBody(r io.Reader, limit int64) ([]byte, error) {
+	body, err := io.ReadAll(io.LimitReader(r, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(body)) > limit {
+		return nil, ErrBodyTooLarge
+	}
+	return body, nil
+}
+
+// DecodePayment decodifica e valida um único pagamento em JSON.
+// Retorna ErrMalformedBody para JSON inválido e FieldErrors quando alguma regra falha.
+func DecodePayment(body []byte) (*domain.Payment, error) {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(body, &raw); err != nil || raw == nil {
+		return nil, ErrMalformedBody
+	}
+
+	var fieldErrors FieldErrors
+	for _, field := range slices.Sorted(maps.Keys(raw)) {
+		if !paymentFields[field] {
+			fieldErrors = append(fieldErrors, FieldError{Field: field, Message: "unknown field"})
+		}
+	}
+
+	var input PaymentInput
+	if value, ok := raw["correlationId"]; ok && !isNull(value) {
+		var correlationId string
+		if err := json.Unmarshal(value, &correlationId); err != nil {
+			fieldErrors = append(fieldErrors, FieldError{Field: "correlationId", Message: "must be a string"})
+		} else {
+			input.CorrelationId = &correlationId
+		}
+	}
+	if value, ok := raw["amount"]; ok && !isNull(value) {
+		// Apenas números JSON são aceitos; o texto original é mantido para validar a precisão.
+		amount := json.Number(bytes.TrimSpace(value))
+		if len(amount) == 0 || (amount[0] != '-' && (amount[0] < '0' || amount[0] > '9')) {
+			fieldErrors = append(fieldErrors, FieldError{Field: "amount", Message: "must be a number"})
+		} else {
+			input.Amount = &amount
+		}
+	}
+
+	// Campos com tipo inválido já foram reportados e não devem aparecer também como ausentes.
+	payment, errs := ValidatePayment(input)
+	for _, fe := range errs {
+		if !fieldErrors.has(fe.Field) {
+			fieldErrors = append(fieldErrors, fe)
+		}
+	}
+	if len(fieldErrors) > 0 {
+		return nil, fieldErrors
+	}
+
+	return payment, nil
+}
+
+func isNull(value json.RawMessage) bool {
+	return string(bytes.TrimSpace(value)) == "null"
+}
+
+// ValidatePayment aplica as regras de um pagamento e o converte para o domínio.
+// É usada tanto na rota de pagamento quanto por caminhos de lote e importação.
+func ValidatePayment(input PaymentInput) (*domain.Payment, FieldErrors) {
+	var fieldErrors FieldErrors
+	payment := &domain.Payment{}
+
+	if input.CorrelationId == nil || *input.CorrelationId == "" {
+		fieldErrors = append(fieldErrors, FieldError{Field: "correlationId", Message: "is required"})
+	} else {
+		payment.CorrelationId = *input.CorrelationId
+		if !payment.ValidateCorrelationId() {
+			fieldErrors = append(fieldErrors, FieldError{Field: "correlationId", Message: "must be a valid UUID"})
+		}
+	}
+
+	if input.Amount == nil {
+		fieldErrors = append(fieldErrors, FieldError{Field: "amount", Message: "is required"})
+	} else if amount, err := validateAmount(string(*input.Amount)); err != nil {
+		fieldErrors = append(fieldErrors, FieldError{Field: "amount", Message: err.Error()})
+	} else {
+		payment.Amount = amount
+	}
+
+	return payment, fieldErrors
+}
+
+func validateAmount(value string) (domain.Money, error) {
+	amount, err := domain.ParseMoney(value)
+	// Valores negativos são recusados pelo sinal, mesmo quando também violam a precisão.
+	if (err == nil && amount <= 0) || (err != nil && strings.HasPrefix(value, "-")) {
+		return 0, errors.New("must be greater than zero")
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	return amount, nil
+}
@@ -0,0 +1,131 @@
+package validation
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/nicolasmmb/go-rinha-backend-2025/internal/domain"
+)
+
+const validCorrelationId = "4a7901b8-7d26-4d9d-aa19-4dc1c7cf60b3"
+
+func TestDecodePayment(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		// Valor esperado, em centavos, quando o pagamento é válido.
+		amount domain.Money
+		// Erro esperado de corpo malformado.
+		err error
+		// Campos esperados nos FieldErrors.
+		fields []string
+	}{
+		{name: "válido", body: `{"correlationId":"` + validCorrelationId + `","amount":19.9}`, amount: 1990},
+		{name: "valor inteiro", body: `{"correlationId":"` + validCorrelationId + `","amount":7}`, amount: 700},
+		{name: "casas decimais demais", body: `{"correlationId":"` + validCorrelationId + `","amount":19.901}`, fields: []string{"amount"}},
+		{name: "valor negativo", body: `{"correlationId":"` + validCorrelationId + `","amount":-1}`, fields: []string{"amount"}},
+		{name: "valor negativo com decimais demais", body: `{"correlationId":"` + validCorrelationId + `","amount":-1.001}`, fields: []string{"amount"}},
+		{name: "valor zero", body: `{"correlationId":"` + validCorrelationId + `","amount":0}`, fields: []string{"amount"}},
+		{name: "valor fora do intervalo", body: `{"correlationId":"` + validCorrelationId + `","amount":1e400}`, fields: []string{"amount"}},
+		{name: "valor em string", body: `{"correlationId":"` + validCorrelationId + `","amount":"19.90"}`, fields: []string{"amount"}},
+		{name: "valor ausente", body: `{"correlationId":"` + validCorrelationId + `"}`, fields: []string{"amount"}},
+		{name: "campo desconhecido", body: `{"correlationId":"` + validCorrelationId + `","amount":19.9,"currency":"BRL"}`, fields: []string{"currency"}},
+		{name: "UUID malformado", body: `{"correlationId":"4a7901b8-7d26-4d9d","amount":19.9}`, fields: []string{"correlationId"}},
+		{name: "correlationId numérico", body: `{"correlationId":42,"amount":19.9}`, fields: []string{"correlationId"}},
+		{name: "correlationId ausente", body: `{"amount":19.9}`, fields: []string{"correlationId"}},
+		{name: "vários problemas", body: `{"correlationId":"x","amount":0,"extra":true}`, fields: []string{"extra", "correlationId", "amount"}},
+		{name: "JSON inválido", body: `{"correlationId":`, err: ErrMalformedBody},
+		{name: "null", body: `null`, err: ErrMalformedBody},
+		{name: "lista", body: `[]`, err: ErrMalformedBody},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payment, err := DecodePayment([]byte(tt.body))
+
+			switch {
+			case tt.err != nil:
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("erro = %v, esperava %v", err, tt.err)
+				}
+			case len(tt.fields) > 0:
+				var fieldErrors FieldErrors
+				if !errors.As(err, &fieldErrors) {
+					t.Fatalf("erro = %v, esperava FieldErrors", err)
+				}
+				var fields []string
+				for _, fe := range fieldErrors {
+					fields = append(fields, fe.Field)
+				}
+				if !slices.Equal(fields, tt.fields) {
+					t.Fatalf("campos com erro = %v, esperava %v (%v)", fields, tt.fields, err)
+				}
+			default:
+				if err != nil {
+					t.Fatalf("erro inesperado: %v", err)
+				}
+				if payment.CorrelationId != validCorrelationId || payment.Amount != tt.amount {
+					t.Fatalf("pagamento = %+v, esperava %s com %d centavos", *payment, validCorrelationId, tt.amount)
+				}
+			}
+		})
+	}
+}
+
+func TestValidatePayment(t *testing.T) {
+	ptr := func(s string) *string { return &s }
+	number := func(s string) *json.Number { n := json.Number(s); return &n }
+
+	tests := []struct {
+		name   string
+		input  PaymentInput
+		fields []string
+	}{
+		{name: "válido", input: PaymentInput{CorrelationId: ptr(validCorrelationId), Amount: number("0.01")}},
+		{name: "vazio", input: PaymentInput{}, fields: []string{"correlationId", "amount"}},
+		{name: "correlationId vazio", input: PaymentInput{CorrelationId: ptr(""), Amount: number("1")}, fields: []string{"correlationId"}},
+		{name: "casas decimais demais", input: PaymentInput{CorrelationId: ptr(validCorrelationId), Amount: number("0.001")}, fields: []string{"amount"}},
+		{name: "valor fora do intervalo", input: PaymentInput{CorrelationId: ptr(validCorrelationId), Amount: number("1e400")}, fields: []string{"amount"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, fieldErrors := ValidatePayment(tt.input)
+			var fields []string
+			for _, fe := range fieldErrors {
+				fields = append(fields, fe.Field)
+			}
+			if !slices.Equal(fields, tt.fields) {
+				t.Fatalf("campos com erro = %v, esperava %v (%v)", fields, tt.fields, fieldErrors)
+			}
+		})
+	}
+}
+
+func TestReadBody(t *testing.T) {
+	tests := []struct {
+		name string
+		size int
+		err  error
+	}{
+		{name: "no limite", size: MAX_PAYMENT_BODY_BYTES},
+		{name: "acima do limite", size: MAX_PAYMENT_BODY_BYTES + 1, err: ErrBodyTooLarge},
+		{name: "muito acima do limite", size: 64 * MAX_PAYMENT_BODY_BYTES, err: ErrBodyTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, err := ReadBody(strings.NewReader(strings.Repeat(" ", tt.size)), MAX_PAYMENT_BODY_BYTES)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("erro = %v, esperava %v", err, tt.err)
+			}
+			if err == nil && !bytes.Equal(body, bytes.Repeat([]byte(" "), tt.size)) {
+				t.Fatalf("corpo com %d bytes, esperava %d", len(body), tt.size)
+			}
+		})
+	}
+}
@@ -0,0 +1,41 @@
+package worker
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/nicolasmmb/go-rinha-backend-2025/internal/core"
+	"github.com/nicolasmmb/go-rinha-backend-2025/internal/service"
+)
+
+type reconcileWorker struct {
+	svc      *service.PaymentService
+	leader   core.LeaderElectorInterface
+	INTERVAL time.Duration
+}
+
+func NewReconcileWorker(svc *service.PaymentService, leader core.LeaderElectorInterface, INTERVAL time.Duration) *reconcileWorker {
+	return &reconcileWorker{svc: svc, leader: leader, INTERVAL: INTERVAL}
+}
+
+// RunReconciler tenta resolver periodicamente os pagamentos estacionados com resultado ambíguo.
+// Com várias instâncias, apenas o líder reconcilia, evitando que o mesmo pagamento seja reenviado duas vezes.
+func (w *reconcileWorker) RunReconciler(ctx context.Context) {
+	ticker := time.NewTicker(w.INTERVAL)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if w.leader != nil && !w.leader.IsLeader() {
+				continue
+			}
+			if err := w.svc.ReconcileParked(ctx); err != nil {
+				slog.Warn("falha ao reconciliar pagamentos estacionados", "error", err.Error())
+			}
+		}
+	}
+}
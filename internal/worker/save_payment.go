@@ -2,7 +2,11 @@ package worker
 
 import (
 	"context"
+	"errors"
+	"log/slog"
+	"sync"
 
+	"github.com/nicolasmmb/go-rinha-backend-2025/internal/core"
 	"github.com/nicolasmmb/go-rinha-backend-2025/internal/domain"
 	"github.com/nicolasmmb/go-rinha-backend-2025/internal/service"
 )
@@ -10,6 +14,9 @@ import (
 type savePaymentWorker struct {
 	svc     *service.PaymentService
 	WORKERS int
+
+	wg     sync.WaitGroup
+	cancel context.CancelFunc
 }
 
 func NewSavePaymentWorker(svc *service.PaymentService, WORKERS int) *savePaymentWorker {
@@ -17,23 +24,111 @@ func NewSavePaymentWorker(svc *service.PaymentService, WORKERS int) *savePayment
 }
 
 func (w *savePaymentWorker) RunPaymentProcessor(ctx context.Context) {
+	ctx, w.cancel = context.WithCancel(ctx)
+
 	queue := w.svc.GetPaymentQueue()
 	for i := 0; i < w.WORKERS; i++ {
-		go w.processPayments(ctx, queue)
+		w.wg.Add(1)
+		go w.processPayments(ctx, queue, w.svc.ClaimOwner(i))
 	}
 }
 
-func (w *savePaymentWorker) processPayments(ctx context.Context, queue <-chan domain.Payment) {
+// Drain espera os workers esvaziarem a fila (que já deve estar fechada) até o prazo do contexto.
+// Ao fim do prazo os workers são interrompidos; retorna true se a fila foi esvaziada a tempo.
+func (w *savePaymentWorker) Drain(ctx context.Context) bool {
+	done := make(chan struct{})
+	go func() {
+		w.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return true
+	case <-ctx.Done():
+		w.cancel()
+		<-done
+		return false
+	}
+}
 
-	var payment domain.Payment
-	var p *domain.Payment
-	var err error
+func (w *savePaymentWorker) processPayments(ctx context.Context, queue core.PaymentQueueInterface, owner string) {
+	defer w.wg.Done()
 
-	for payment = range queue {
-		p, err = w.svc.ProcessPayment(ctx, &payment)
+	for {
+		msg, err := queue.Dequeue(ctx)
 		if err != nil {
-			continue
+			return
+		}
+
+		if w.handlePayment(ctx, queue, msg, owner) {
+			if err := queue.Ack(ctx, msg); err != nil {
+				slog.Warn("falha ao confirmar pagamento na fila", "correlationId", msg.Payment.CorrelationId, "error", err.Error())
+			}
+		}
+	}
+}
+
+// handlePayment processa e salva o pagamento, informando se ele já pode ser confirmado na fila.
+// Pagamentos que não puderam ser salvos não são confirmados e voltam a ser entregues por filas duráveis.
+// owner identifica o worker na reserva do correlationId, que impede dois workers de enviarem o mesmo pagamento.
+func (w *savePaymentWorker) handlePayment(ctx context.Context, queue core.PaymentQueueInterface, msg *core.QueueMessage, owner string) bool {
+	payment := msg.Payment
+
+	// Um pagamento concluído pode sair da fila. Um reservado por outro worker fica pendente:
+	// filas duráveis o entregam de novo, quando a reserva já tiver sido concluída ou expirado;
+	// as demais o recebem de volta depois de uma espera.
+	if claim := w.svc.ClaimPayment(ctx, &payment, owner); !claim.Acquired {
+		if claim.Status == domain.CLAIM_STATUS_CLAIMED && !queue.Redelivers() {
+			w.svc.RequeueClaimedPayment(ctx, &payment)
+			return true
 		}
-		w.svc.SavePayment(ctx, p)
+		return claim.Status == domain.CLAIM_STATUS_COMPLETED
+	}
+
+	// Um pagamento já concluído que volta a ser entregue não é enviado de novo, para não ser cobrado duas vezes.
+	// As demais recusas e falhas ao gravar o registro só ficam no log: o registro é informativo.
+	var transitionErr *domain.TransitionError
+	if err := w.svc.TransitionPayment(ctx, &payment, domain.PAYMENT_STATUS_PROCESSING); errors.As(err, &transitionErr) &&
+		transitionErr.From == domain.PAYMENT_STATUS_SUCCEEDED {
+		w.svc.CompleteClaim(ctx, &payment, owner)
+		return true
+	}
+
+	p, err := w.svc.ProcessPayment(ctx, &payment, owner)
+
+	// O resultado precisa ser registrado mesmo se os workers estiverem sendo interrompidos.
+	ctx = context.WithoutCancel(ctx)
+
+	// A reserva do pagamento estacionado fica com este worker até expirar ou até a reconciliação liberá-la ao reenfileirá-lo.
+	if errors.Is(err, service.ErrPaymentParked) {
+		return true
+	}
+	if err != nil {
+		w.svc.ReleaseClaim(ctx, &payment, owner)
+		w.svc.TransitionPayment(ctx, &payment, domain.PAYMENT_STATUS_FAILED)
+		if dlqErr := w.svc.DeadLetterPayment(ctx, &payment, err); dlqErr != nil {
+			slog.Error("falha ao salvar pagamento na dead-letter", "correlationId", payment.CorrelationId, "error", dlqErr.Error())
+			return false
+		}
+		return true
+	}
+
+	// Se a gravação falhar de vez, a reserva expira e a reentrega confirma o pagamento no processador antes de gravá-lo.
+	created, err := w.svc.SavePayment(ctx, p)
+	if errors.Is(err, service.ErrSaveDeferred) {
+		// O processador já aceitou o pagamento: o outbox o grava quando o repositório voltar.
+		slog.Warn("pagamento processado guardado no outbox", "correlationId", p.CorrelationId, "error", err.Error())
+		w.svc.CompleteClaim(ctx, p, owner)
+		return true
+	}
+	if err != nil {
+		slog.Error("falha ao salvar pagamento processado", "correlationId", p.CorrelationId, "error", err.Error())
+		return false
+	}
+	w.svc.CompleteClaim(ctx, p, owner)
+	if !created {
+		slog.Warn("pagamento já estava gravado, duplicata ignorada", "correlationId", p.CorrelationId, "processor", p.Processor)
 	}
+	return true
 }
@@ -13,7 +13,8 @@ import (
 // GracefulShutdown inicia o servidor HTTP e gerencia seu desligamento gracioso.
 // Ele escuta por sinais de interrupção (SIGINT, SIGTERM) e, quando recebidos,
 // tenta desligar o servidor de forma segura em um tempo limite.
-func GracefulShutdown(server *http.Server, timeout time.Duration) {
+// Após o servidor parar de aceitar requisições, os hooks são executados em ordem.
+func GracefulShutdown(server *http.Server, timeout time.Duration, hooks ...func()) {
 	// Inicia o servidor em uma goroutine para não bloquear o fluxo principal.
 	go func() {
 		log.Printf("🚀 Servidor HTTP escutando em: %s", server.Addr)
@@ -35,10 +36,16 @@ func GracefulShutdown(server *http.Server, timeout time.Duration) {
 	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
 
-	// Tenta desligar o servidor de forma graciosa.
+	// Tenta desligar o servidor de forma graciosa. Mesmo se falhar (por exemplo, requisições que não terminaram
+	// no tempo limite), os hooks ainda rodam, para drenar a fila e gravar os pagamentos pendentes.
 	if err := server.Shutdown(ctx); err != nil {
-		log.Fatalf("Erro no desligamento do servidor: %v", err)
+		log.Printf("❌ Erro no desligamento do servidor: %v", err)
+		server.Close()
+	} else {
+		log.Println("✅ Servidor desligado com sucesso.")
 	}
 
-	log.Println("✅ Servidor desligado com sucesso.")
+	for _, hook := range hooks {
+		hook()
+	}
 }